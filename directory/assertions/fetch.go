@@ -0,0 +1,72 @@
+//go:build ignore
+// +build ignore
+
+// Fetch copies the assertion catalogs of a commit or tag of wot-discovery into a snapshot of a spec version,
+// to be embedded by the test suite. From the directory of the module:
+//
+//	go run assertions/fetch.go -version 1.0 -ref <tag or commit of wot-discovery>
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const sourceURL = "https://raw.githubusercontent.com/w3c/wot-discovery/%s/testing/%s"
+
+func main() {
+	version := flag.String("version", "", "Spec version of the snapshot, e.g. 1.0")
+	ref := flag.String("ref", "", "Tag or commit of wot-discovery to copy the catalogs from")
+	flag.Parse()
+	if *version == "" || *ref == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// both catalogs are downloaded before writing, to not leave a partial snapshot
+	files := make(map[string][]byte)
+	source := fmt.Sprintf("Copied unchanged on %s from:\n", time.Now().UTC().Format("2006-01-02"))
+	for _, name := range []string{"template.csv", "manual.csv"} {
+		u := fmt.Sprintf(sourceURL, *ref, name)
+		b, err := download(u)
+		if err != nil {
+			fmt.Printf("Error copying %s: %s\n", u, err)
+			os.Exit(1)
+		}
+		files[name] = b
+		source += u + "\n"
+	}
+	files["SOURCE"] = []byte(source)
+
+	dir := filepath.Join("assertions", *version)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		fmt.Printf("Error creating %s: %s\n", dir, err)
+		os.Exit(1)
+	}
+	for name, b := range files {
+		err = os.WriteFile(filepath.Join(dir, name), b, 0644)
+		if err != nil {
+			fmt.Printf("Error writing %s: %s\n", name, err)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("Copied the catalogs of %s into %s\n", *ref, dir)
+}
+
+func download(url string) ([]byte, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got status %s", res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
@@ -0,0 +1,182 @@
+package directory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// authentication schemes
+const (
+	authNone   = "none"
+	authBasic  = "basic"
+	authBearer = "bearer"
+	authOAuth2 = "oauth2"
+)
+
+type authConfig struct {
+	scheme   string
+	username string
+	password string
+	token    string
+	// OAuth2 client credentials grant
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+}
+
+// authenticator adds credentials to a request
+type authenticator interface {
+	authorize(req *http.Request) error
+}
+
+// newAuthenticator returns the authenticator for the configured scheme, or nil for no authentication
+func newAuthenticator(config authConfig) (authenticator, error) {
+	switch config.scheme {
+	case "", authNone:
+		return nil, nil
+	case authBasic:
+		if config.username == "" {
+			return nil, fmt.Errorf("basic authentication requires a username")
+		}
+		return &basicAuth{config.username, config.password}, nil
+	case authBearer:
+		if config.token == "" {
+			return nil, fmt.Errorf("bearer authentication requires a token")
+		}
+		return &bearerAuth{config.token}, nil
+	case authOAuth2:
+		if config.tokenURL == "" || config.clientID == "" {
+			return nil, fmt.Errorf("oauth2 authentication requires a token URL and a client ID")
+		}
+		return &oauth2ClientCredentials{
+			tokenURL:     config.tokenURL,
+			clientID:     config.clientID,
+			clientSecret: config.clientSecret,
+			scopes:       config.scopes,
+			client:       &http.Client{},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown authentication scheme: %s", config.scheme)
+	}
+}
+
+// authTransport is an http.RoundTripper that authorizes requests before sending them
+type authTransport struct {
+	base http.RoundTripper
+	auth authenticator
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a round tripper must not modify the given request
+	req = req.Clone(req.Context())
+	err := t.auth.authorize(req)
+	if err != nil {
+		return nil, fmt.Errorf("error authorizing request: %s", err)
+	}
+	return t.base.RoundTrip(req)
+}
+
+type basicAuth struct {
+	username, password string
+}
+
+func (a *basicAuth) authorize(req *http.Request) error {
+	req.SetBasicAuth(a.username, a.password)
+	return nil
+}
+
+type bearerAuth struct {
+	token string
+}
+
+func (a *bearerAuth) authorize(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+// oauth2ClientCredentials gets an access token using the OAuth2 client credentials grant (RFC 6749, Section 4.4)
+// and gets a new one when it expires
+type oauth2ClientCredentials struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	client       *http.Client
+
+	sync.Mutex
+	token  string
+	expiry time.Time // zero if the token does not expire
+}
+
+// tokens are renewed this long before they expire, to not send one that expires on the way
+const tokenExpiryDelta = 10 * time.Second
+
+func (a *oauth2ClientCredentials) authorize(req *http.Request) error {
+	token, err := a.accessToken()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// accessToken returns the cached token or fetches a new one if there is none or it has expired
+func (a *oauth2ClientCredentials) accessToken() (string, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.token != "" && (a.expiry.IsZero() || time.Now().Add(tokenExpiryDelta).Before(a.expiry)) {
+		return a.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(a.scopes) > 0 {
+		form.Set("scope", strings.Join(a.scopes, " "))
+	}
+	req, err := http.NewRequest(http.MethodPost, a.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", MediaTypeJSON)
+	req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret))
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting token: %s", err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Error       string `json:"error"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %d: %s", res.StatusCode, body.Error)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error decoding token response: %s", err)
+	}
+	if body.AccessToken == "" {
+		return "", fmt.Errorf("token endpoint returned no access token")
+	}
+	if body.TokenType != "" && !strings.EqualFold(body.TokenType, "bearer") {
+		return "", fmt.Errorf("unsupported token type: %s", body.TokenType)
+	}
+
+	a.token = body.AccessToken
+	a.expiry = time.Time{}
+	if body.ExpiresIn > 0 {
+		a.expiry = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return a.token, nil
+}
@@ -0,0 +1,109 @@
+//go:build !conformance
+// +build !conformance
+
+package directory
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestAuthentication checks the authenticators against a local stand-in directory and token server.
+func TestAuthentication(t *testing.T) {
+	directory := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer directory.Close()
+
+	authorization := func(t *testing.T, auth authenticator) string {
+		t.Helper()
+		client := &http.Client{Transport: &authTransport{base: http.DefaultTransport, auth: auth}}
+		res, err := client.Get(directory.URL)
+		if err != nil {
+			t.Fatalf("Error getting: %s", err)
+		}
+		defer res.Body.Close()
+		return string(httpReadBody(res, t))
+	}
+
+	t.Run("basic", func(t *testing.T) {
+		auth, err := newAuthenticator(authConfig{scheme: authBasic, username: "user", password: "pass"})
+		if err != nil {
+			t.Fatalf("Error creating authenticator: %s", err)
+		}
+		if got := authorization(t, auth); got != "Basic dXNlcjpwYXNz" {
+			t.Fatalf("Expected basic authorization header, got: %s", got)
+		}
+	})
+
+	t.Run("bearer", func(t *testing.T) {
+		auth, err := newAuthenticator(authConfig{scheme: authBearer, token: "abc"})
+		if err != nil {
+			t.Fatalf("Error creating authenticator: %s", err)
+		}
+		if got := authorization(t, auth); got != "Bearer abc" {
+			t.Fatalf("Expected bearer authorization header, got: %s", got)
+		}
+	})
+
+	t.Run("oauth2 client credentials", func(t *testing.T) {
+		var issued int
+		tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, secret, _ := r.BasicAuth()
+			if r.FormValue("grant_type") != "client_credentials" || id != "client" || secret != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(mapAny{"error": "invalid_client"})
+				return
+			}
+			if r.FormValue("scope") != "read write" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(mapAny{"error": "invalid_scope"})
+				return
+			}
+			issued++
+			w.Header().Set("Content-Type", MediaTypeJSON)
+			json.NewEncoder(w).Encode(mapAny{
+				"access_token": "token" + string(rune('0'+issued)),
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+			})
+		}))
+		defer tokenServer.Close()
+
+		config := authConfig{
+			scheme:       authOAuth2,
+			tokenURL:     tokenServer.URL,
+			clientID:     "client",
+			clientSecret: "secret",
+			scopes:       []string{"read", "write"},
+		}
+		auth, err := newAuthenticator(config)
+		if err != nil {
+			t.Fatalf("Error creating authenticator: %s", err)
+		}
+
+		if got := authorization(t, auth); got != "Bearer token1" {
+			t.Fatalf("Expected the first token, got: %s", got)
+		}
+		if got := authorization(t, auth); got != "Bearer token1" {
+			t.Fatalf("Expected the cached token, got: %s", got)
+		}
+
+		// expire the token
+		auth.(*oauth2ClientCredentials).expiry = time.Now()
+		if got := authorization(t, auth); got != "Bearer token2" {
+			t.Fatalf("Expected a refreshed token, got: %s", got)
+		}
+
+		config.clientSecret = "wrong"
+		auth, _ = newAuthenticator(config)
+		client := &http.Client{Transport: &authTransport{base: http.DefaultTransport, auth: auth}}
+		_, err = client.Get(directory.URL)
+		if err == nil {
+			t.Fatalf("Expected an error with invalid client credentials")
+		}
+	})
+}
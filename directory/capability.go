@@ -0,0 +1,435 @@
+package directory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Capabilities are the optional features of a directory. The tests of absent capabilities are skipped.
+const (
+	capabilityThingsCRUD        = "things-crud"
+	capabilityPagination        = "pagination"
+	capabilityJSONPath          = "jsonpath"
+	capabilityXPath             = "xpath"
+	capabilitySPARQL            = "sparql"
+	capabilitySPARQLFederation  = "sparql-federation"
+	capabilityNotifications     = "notifications"
+	capabilityNotificationsDiff = "notifications-diff"
+	capabilityExpiry            = "expiry"
+	capabilitiesAuto            = "auto"
+)
+
+// capability is an optional feature of a directory and how to detect it
+type capability struct {
+	name      string
+	requires  string // capability without which it is absent, if any
+	mandatory bool   // required by the specification: its tests fail instead of being skipped if it is detected as absent
+	// assertions are covered only by the tests of the capability, including the tests nested in them,
+	// and are reported as not tested if it is absent
+	assertions []string
+	// detect tells whether the directory supports the capability, and why
+	detect func(serverURL string) (bool, string)
+}
+
+var (
+	paginationAssertions = []string{
+		"tdd-things-list-pagination",
+		"tdd-things-list-pagination-limit",
+		"tdd-things-list-pagination-header-nextlink",
+	}
+	sparqlFederationAssertions  = []string{"tdd-search-sparql-federation"}
+	notificationsDiffAssertions = []string{
+		"tdd-notification-data-create-full",
+		"tdd-notification-data-update-diff",
+		"tdd-notification-data-delete-diff",
+	}
+)
+
+var capabilities = []capability{
+	{
+		name:      capabilityThingsCRUD,
+		mandatory: true,
+		assertions: append([]string{
+			"tdd-anonymous-td-identifier",
+			"tdd-anonymous-td-local-uuid",
+			"tdd-registrationinfo-vocab-created",
+			"tdd-registrationinfo-vocab-modified",
+			"tdd-things-create-anonymous-contenttype",
+			"tdd-things-create-anonymous-td",
+			"tdd-things-create-anonymous-td-resp",
+			"tdd-things-create-known-td",
+			"tdd-things-create-known-td-resp",
+			"tdd-things-create-known-vs-anonymous",
+			"tdd-things-crud",
+			"tdd-things-crudl",
+			"tdd-things-default-representation",
+			"tdd-things-delete",
+			"tdd-things-delete-resp",
+			"tdd-things-list-method",
+			"tdd-things-list-only",
+			"tdd-things-list-resp",
+			"tdd-things-retrieve",
+			"tdd-things-retrieve-resp",
+			"tdd-things-update",
+			"tdd-things-update-partial",
+			"tdd-things-update-partial-contenttype",
+			"tdd-things-update-partial-mergepatch",
+			"tdd-things-update-partial-partialtd",
+			"tdd-things-update-partial-resp",
+			"tdd-things-update-resp",
+			"tdd-validation-response",
+			"tdd-validation-result",
+			"tdd-validation-syntactic",
+		}, paginationAssertions...),
+		detect: detectThingsCRUD,
+	},
+	{
+		name:       capabilityPagination,
+		requires:   capabilityThingsCRUD,
+		assertions: paginationAssertions,
+		detect:     detectPagination,
+	},
+	{
+		name:     capabilityJSONPath,
+		requires: capabilityThingsCRUD,
+		assertions: []string{
+			"tdd-search-jsonpath",
+			"tdd-search-jsonpath-method",
+			"tdd-search-jsonpath-parameter",
+			"tdd-search-jsonpath-response",
+		},
+		detect: detectSearch("jsonpath", "$"),
+	},
+	{
+		name:     capabilityXPath,
+		requires: capabilityThingsCRUD,
+		assertions: []string{
+			"tdd-search-xpath",
+			"tdd-search-xpath-method",
+			"tdd-search-xpath-parameter",
+			"tdd-search-xpath-response",
+		},
+		detect: detectSearch("xpath", "/"),
+	},
+	{
+		name: capabilitySPARQL,
+		assertions: append([]string{
+			"tdd-search-sparql",
+			"tdd-search-sparql-method-get",
+			"tdd-search-sparql-method-post",
+			"tdd-search-sparql-resp-select-ask",
+		}, sparqlFederationAssertions...),
+		detect: detectSearch("sparql", "ASK {}"),
+	},
+	{
+		name:       capabilitySPARQLFederation,
+		requires:   capabilitySPARQL,
+		assertions: sparqlFederationAssertions,
+		detect: func(string) (bool, string) {
+			// a federated query needs an external endpoint
+			return true, "not detectable, assumed with " + capabilitySPARQL
+		},
+	},
+	{
+		name:     capabilityNotifications,
+		requires: capabilityThingsCRUD,
+		assertions: append([]string{
+			"tdd-notification",
+			"tdd-notification-data",
+			"tdd-notification-data-diff-unsupported",
+			"tdd-notification-data-td-id",
+			"tdd-notification-data-update-id",
+			"tdd-notification-event-id",
+			"tdd-notification-event-types",
+			"tdd-notification-filter-type",
+			"tdd-notification-sse",
+		}, notificationsDiffAssertions...),
+		detect: detectNotifications,
+	},
+	{
+		name:       capabilityNotificationsDiff,
+		requires:   capabilityNotifications,
+		assertions: notificationsDiffAssertions,
+		detect:     detectNotificationsDiff,
+	},
+	{
+		name:     capabilityExpiry,
+		requires: capabilityThingsCRUD,
+		assertions: []string{
+			"tdd-registrationinfo-vocab-ttl",
+			"tdd-registrationinfo-vocab-expires",
+		},
+		detect: detectExpiry,
+	},
+}
+
+// supportedCapabilities tells whether the directory under test has each capability, and why, keyed by name
+var supportedCapabilities = make(map[string]capabilitySupport)
+
+type capabilitySupport struct {
+	supported bool
+	reason    string
+	detected  bool // false if selected, not selected or absent with the capability it requires
+}
+
+// selectCapabilities sets the capabilities of the directory from a comma-separated list of names.
+// Those not listed are absent, unless the list includes auto to detect them.
+func selectCapabilities(list, serverURL string) error {
+	selected := make(map[string]bool)
+	auto := false
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == capabilitiesAuto {
+			auto = true
+			continue
+		}
+		if _, found := findCapability(name); !found {
+			var names []string
+			for _, c := range capabilities {
+				names = append(names, c.name)
+			}
+			return fmt.Errorf("unknown capability %s, expected auto or some of: %s", name, strings.Join(names, ", "))
+		}
+		selected[name] = true
+	}
+
+	// the capabilities that others require precede them
+	for _, c := range capabilities {
+		switch {
+		case c.requires != "" && !supportedCapabilities[c.requires].supported:
+			supportedCapabilities[c.name] = capabilitySupport{false, "requires " + c.requires, false}
+		case selected[c.name]:
+			supportedCapabilities[c.name] = capabilitySupport{true, "selected", false}
+		case auto:
+			supported, reason := c.detect(serverURL)
+			supportedCapabilities[c.name] = capabilitySupport{supported, reason, true}
+		default:
+			supportedCapabilities[c.name] = capabilitySupport{false, "not selected", false}
+		}
+	}
+	return nil
+}
+
+// withDeprecatedCapabilities adds the capabilities enabled by the deprecated --testJSONPath and --testXPath flags to a list
+func withDeprecatedCapabilities(list string) string {
+	deprecated := []struct {
+		flag       string
+		enabled    bool
+		capability string
+	}{
+		{"testJSONPath", testJSONPath, capabilityJSONPath},
+		{"testXPath", testXPath, capabilityXPath},
+	}
+	for _, d := range deprecated {
+		if d.enabled {
+			fmt.Printf("Warning: --%s is deprecated, use --capabilities=%s,%s\n", d.flag, capabilitiesAuto, d.capability)
+			list += "," + d.capability
+		}
+	}
+	return list
+}
+
+func findCapability(name string) (capability, bool) {
+	for _, c := range capabilities {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return capability{}, false
+}
+
+// printCapabilities prints whether each capability is supported and why
+func printCapabilities() {
+	fmt.Println("Capabilities:")
+	for _, c := range capabilities {
+		s := supportedCapabilities[c.name]
+		status := "absent"
+		if s.supported {
+			status = "supported"
+		}
+		fmt.Printf("  %-18s %-9s (%s)\n", c.name, status, s.reason)
+	}
+	for _, c := range capabilities {
+		if s := supportedCapabilities[c.name]; c.mandatory && s.detected && !s.supported {
+			fmt.Printf("Warning: The mandatory capability %s was detected as absent, its tests will fail\n", c.name)
+		}
+	}
+}
+
+// requireCapability skips a test of a capability that the directory does not support,
+// reporting the assertions of the capability as not tested.
+// The test fails instead if the capability is mandatory and was detected as absent, since the directory does not conform.
+func requireCapability(t *testing.T, name string) {
+	t.Helper()
+	s := supportedCapabilities[name]
+	if s.supported {
+		return
+	}
+	c, _ := findCapability(name)
+	defer report(t, c.assertions...)
+	if c.mandatory && s.detected {
+		fatalf(t, "The directory does not support %s, which is mandatory: %s", name, s.reason)
+	}
+	skipf(t, "The directory does not support %s: %s", name, s.reason)
+}
+
+// describedAffordance returns the affordance of the given name in the directory TD, if any
+func describedAffordance(name string) (mapAny, bool) {
+	for _, kind := range []string{"properties", "actions", "events"} {
+		affordances, _ := selfDescription.td[kind].(mapAny)
+		if a, ok := affordances[name].(mapAny); ok {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// probe sends a request for detecting a capability and returns the response status, without reading the body
+func probe(method, url, contentType string, b []byte) (int, http.Header, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(b))
+	if err != nil {
+		return 0, nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	res.Body.Close()
+	return res.StatusCode, res.Header, nil
+}
+
+func detectThingsCRUD(string) (bool, string) {
+	if selfDescription.td == nil {
+		return true, "assumed"
+	}
+	for _, name := range []string{affordanceCreateThing, affordanceCreateAnonymous} {
+		if _, found := describedAffordance(name); found {
+			return true, "described by " + name
+		}
+	}
+	return false, "neither createThing nor createAnonymousThing is described"
+}
+
+// detectPagination registers two TDs and checks whether listing with limit=1 gets only one of them
+func detectPagination(serverURL string) (bool, string) {
+	if things, found := describedAffordance(affordanceThings); found {
+		variables, _ := things["uriVariables"].(mapAny)
+		if _, found := variables["limit"]; found {
+			return true, "limit described by things"
+		}
+		return false, "limit not described by things"
+	}
+
+	for i := 0; i < 2; i++ {
+		id := "urn:uuid:" + newUUID()
+		b, _ := json.Marshal(mockedTD(id))
+		status, _, err := probe(http.MethodPut, thingURL(serverURL, id, affordanceCreateThing), MediaTypeThingDescription, b)
+		if err != nil {
+			return false, err.Error()
+		}
+		if status != http.StatusCreated && status != http.StatusNoContent {
+			return false, fmt.Sprintf("registration of a TD to list got status %d", status)
+		}
+		defer probe(http.MethodDelete, thingURL(serverURL, id, affordanceDeleteThing), "", nil)
+	}
+
+	res, err := httpClient.Get(thingsPageURL(serverURL, 1))
+	if err != nil {
+		return false, err.Error()
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Sprintf("listing with limit=1 got status %d", res.StatusCode)
+	}
+	var collection []any
+	body, _ := io.ReadAll(res.Body)
+	if json.Unmarshal(body, &collection) != nil || len(collection) != 1 {
+		return false, fmt.Sprintf("listing two TDs with limit=1 got %d TDs", len(collection))
+	}
+	return true, "listing two TDs with limit=1 got one TD"
+}
+
+func detectSearch(kind, query string) func(string) (bool, string) {
+	return func(serverURL string) (bool, string) {
+		if _, found := describedAffordance(searchAffordances[kind]); found {
+			return true, "described by " + searchAffordances[kind]
+		}
+		status, _, err := probe(http.MethodGet, searchURL(serverURL, kind, http.MethodGet, query), "", nil)
+		if err != nil {
+			return false, err.Error()
+		}
+		// a directory that rejects the probing query still has the search API
+		if status == http.StatusOK || status == http.StatusBadRequest {
+			return true, fmt.Sprintf("%s search got status %d", kind, status)
+		}
+		return false, fmt.Sprintf("%s search got status %d", kind, status)
+	}
+}
+
+func detectNotifications(serverURL string) (bool, string) {
+	for _, name := range []string{affordanceThingCreated, affordanceThingUpdated, affordanceThingDeleted} {
+		if _, found := describedAffordance(name); found {
+			return true, "described by " + name
+		}
+	}
+	status, header, err := probe(http.MethodGet, eventsURL(serverURL, "", false), "", nil)
+	if err != nil {
+		return false, err.Error()
+	}
+	if status == http.StatusOK && strings.HasPrefix(header.Get("Content-Type"), "text/event-stream") {
+		return true, "subscription got an event stream"
+	}
+	return false, fmt.Sprintf("subscription got status %d", status)
+}
+
+func detectNotificationsDiff(serverURL string) (bool, string) {
+	status, _, err := probe(http.MethodGet, eventsURL(serverURL, EventTypeCreate, true), "", nil)
+	if err != nil {
+		return false, err.Error()
+	}
+	if status == http.StatusOK {
+		return true, "subscription with diff got status 200"
+	}
+	return false, fmt.Sprintf("subscription with diff got status %d", status)
+}
+
+// detectExpiry registers a TD with a time-to-live and checks whether its expiry time is set
+func detectExpiry(serverURL string) (bool, string) {
+	id := "urn:uuid:" + newUUID()
+	td := mockedTD(id)
+	td["registration"] = mapAny{"ttl": 3600}
+	b, _ := json.Marshal(td)
+	status, _, err := probe(http.MethodPut, thingURL(serverURL, id, affordanceCreateThing), MediaTypeThingDescription, b)
+	if err != nil {
+		return false, err.Error()
+	}
+	if status != http.StatusCreated && status != http.StatusNoContent {
+		return false, fmt.Sprintf("registration with ttl got status %d", status)
+	}
+	defer probe(http.MethodDelete, thingURL(serverURL, id, affordanceDeleteThing), "", nil)
+
+	res, err := httpClient.Get(thingURL(serverURL, id, affordanceRetrieveThing))
+	if err != nil {
+		return false, err.Error()
+	}
+	defer res.Body.Close()
+	var retrieved mapAny
+	body, _ := io.ReadAll(res.Body)
+	json.Unmarshal(body, &retrieved)
+	registration, _ := retrieved["registration"].(mapAny)
+	if expires, ok := registration["expires"].(string); ok {
+		if _, err := time.Parse(time.RFC3339, expires); err == nil {
+			return true, "registration with ttl got an expiry time"
+		}
+	}
+	return false, "registration with ttl got no expiry time"
+}
@@ -0,0 +1,101 @@
+package directory
+
+import (
+	"embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+)
+
+// Snapshots of the assertion catalogs from https://github.com/w3c/wot-discovery/tree/main/testing
+// stored as assertions/<spec version>/template.csv and manual.csv
+//
+//go:embed assertions
+var catalogs embed.FS
+
+const (
+	catalogTemplateFile = "template.csv"
+	catalogManualFile   = "manual.csv"
+	defaultTemplateURL  = "https://raw.githubusercontent.com/w3c/wot-discovery/main/testing/template.csv"
+	defaultManualURL    = "https://raw.githubusercontent.com/w3c/wot-discovery/main/testing/manual.csv"
+	// catalogNone is the source of the assertions when no catalog could be loaded
+	catalogNone = "none"
+)
+
+// suiteAssertions are checked by this test suite in addition to the assertions of the catalogs.
+// They are not assertions of the specification and are reported separately.
+var suiteAssertions = []string{
+	"tdd-self-description",
+	"tdd-self-description-type",
+	"tdd-self-description-affordances",
+	"tdd-self-description-behavior",
+	"tdd-security-enforced",
+	"tdd-security-challenge",
+	"tdd-security-credentials",
+}
+
+// splitSuiteResults separates the results of the suite assertions from those of the assertions of the specification
+func splitSuiteResults(results map[string]result) (spec, suite map[string]result) {
+	spec, suite = make(map[string]result), make(map[string]result)
+	for id, r := range results {
+		if inSlice(suiteAssertions, id) {
+			suite[id] = r
+		} else {
+			spec[id] = r
+		}
+	}
+	return spec, suite
+}
+
+// specVersions returns the spec versions with an embedded assertion catalog
+func specVersions() []string {
+	entries, err := catalogs.ReadDir("assertions")
+	if err != nil {
+		panic(err)
+	}
+	var versions []string
+	for _, e := range entries {
+		if e.IsDir() {
+			versions = append(versions, e.Name())
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// loadEmbeddedAssertions returns the list of assertions from a catalog file of the given spec version
+func loadEmbeddedAssertions(specVersion, name string) []string {
+	if !inSlice(specVersions(), specVersion) {
+		fmt.Printf("No assertions catalog for spec version %s. Available versions: %v\n", specVersion, specVersions())
+		os.Exit(1)
+	}
+
+	file, err := catalogs.Open(path.Join("assertions", specVersion, name))
+	if err != nil {
+		fmt.Printf("Error opening embedded assertions file: %s\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	fmt.Printf("Reading assertions from embedded %s catalog: %s\n", specVersion, name)
+	return readAssertions(file)
+}
+
+// readAssertions returns the IDs in the first column of an assertions CSV file
+func readAssertions(r io.Reader) []string {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		fmt.Printf("Error reading assertions template file: %s\n", err)
+		os.Exit(1)
+	}
+
+	var assertionIDs []string
+	for _, record := range records {
+		assertionIDs = append(assertionIDs, record[0])
+	}
+
+	return assertionIDs
+}
@@ -0,0 +1,29 @@
+//go:build !conformance
+// +build !conformance
+
+package directory
+
+import (
+	"path"
+	"testing"
+)
+
+// TestEmbeddedCatalogs loads the assertions of each embedded spec version
+func TestEmbeddedCatalogs(t *testing.T) {
+	versions := specVersions()
+	if len(versions) == 0 {
+		t.Skip("No catalog is embedded, see assertions/README.md")
+	}
+	for _, version := range versions {
+		t.Run(version, func(t *testing.T) {
+			if _, err := catalogs.ReadFile(path.Join("assertions", version, "SOURCE")); err != nil {
+				t.Fatalf("Expected the source of the snapshot: %s", err)
+			}
+			for _, name := range []string{catalogTemplateFile, catalogManualFile} {
+				if ids := loadEmbeddedAssertions(version, name); len(ids) == 0 {
+					t.Fatalf("Expected assertions in %s", name)
+				}
+			}
+		})
+	}
+}
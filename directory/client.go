@@ -0,0 +1,215 @@
+package directory
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpClient is used for all requests to the directory
+var httpClient = &http.Client{}
+
+// unauthenticatedClient sends requests without the credentials of the configured authentication,
+// to check that the directory enforces its security
+var unauthenticatedClient = &http.Client{}
+
+// streamClient is used for event subscriptions, which are not limited by the request timeout
+var streamClient = &http.Client{}
+
+type clientConfig struct {
+	auth    authenticator // nil for no authentication
+	tls     *tls.Config
+	headers http.Header       // added to every request
+	replay  http.RoundTripper // serves the responses instead of the network, if set
+	timeout time.Duration     // for each request, zero for no timeout
+	retries int               // number of times to send a request again after a transient connection error
+}
+
+// setupHTTPClient configures the shared clients to authenticate and record all requests and use the given TLS configuration
+func setupHTTPClient(config clientConfig) {
+	var transport http.RoundTripper = config.replay
+	if transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = recordTLS(config.tls)
+		t.ResponseHeaderTimeout = config.timeout
+		t.RegisterProtocol("coap", &coapTransport{})
+		transport = t
+	}
+	// record the traffic as sent, including the headers set below and each retry
+	transport = &harTransport{base: transport}
+	if config.retries > 0 {
+		transport = &retryTransport{base: transport, retries: config.retries}
+	}
+	if len(config.headers) > 0 {
+		transport = &headerTransport{base: transport, header: config.headers}
+	}
+
+	if a, ok := config.auth.(*oauth2ClientCredentials); ok {
+		// the token endpoint is trusted like the directory, but has its own name
+		tokenTransport := http.DefaultTransport.(*http.Transport).Clone()
+		tokenTransport.TLSClientConfig = config.tls.Clone()
+		tokenTransport.TLSClientConfig.ServerName = ""
+		a.client.Transport = tokenTransport
+	}
+	unauthenticatedClient.Transport = transport
+	unauthenticatedClient.Timeout = config.timeout
+	if config.auth != nil {
+		transport = &authTransport{base: transport, auth: config.auth}
+	}
+	httpClient.Transport = transport
+	httpClient.Timeout = config.timeout
+	streamClient.Transport = transport
+}
+
+// headerTransport is an http.RoundTripper that sets extra headers on all requests
+type headerTransport struct {
+	base   http.RoundTripper
+	header http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a round tripper must not modify the given request
+	req = req.Clone(req.Context())
+	for k, values := range t.header {
+		req.Header.Del(k)
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// headerFlag is a repeatable flag of headers in the form "Name: value"
+type headerFlag http.Header
+
+func (f headerFlag) String() string {
+	var headers []string
+	for k, values := range f {
+		for _, v := range values {
+			headers = append(headers, k+": "+v)
+		}
+	}
+	return strings.Join(headers, ", ")
+}
+
+func (f headerFlag) Set(value string) error {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return fmt.Errorf("header must have the form \"Name: value\"")
+	}
+	http.Header(f).Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	return nil
+}
+
+// credentialHeaders may hold credentials, e.g. those added by the authentication of the requests
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// redactHeader returns a copy of the header without the values of credentials and of the headers set with --header,
+// to keep them out of the reports
+func redactHeader(header http.Header) http.Header {
+	redacted := make(http.Header, len(header))
+	for k, values := range header {
+		if inSlice(credentialHeaders, k) || headers[k] != nil {
+			values = []string{"[redacted]"}
+		}
+		redacted[k] = values
+	}
+	return redacted
+}
+
+// resolveURL appends the path segments to the base URL of the directory, escaping each segment,
+// and sets the query if given. The base URL may have a path prefix and a trailing slash.
+func resolveURL(base string, query url.Values, segments ...string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		// the server URL is validated on startup
+		panic(err)
+	}
+	path := strings.TrimSuffix(u.EscapedPath(), "/")
+	for _, s := range segments {
+		path += "/" + url.PathEscape(s)
+	}
+	u.Path, err = url.PathUnescape(path)
+	if err != nil {
+		panic(err)
+	}
+	u.RawPath = path
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// thingsURL is the URL of the Things API for an operation on the collection of TDs, e.g. createAnonymousThing,
+// as described by the directory TD or the default path
+func thingsURL(serverURL, affordance string) string {
+	for _, a := range []string{affordance, affordanceThings} {
+		if u, found := describedURL(a, thingsOperations[a], nil); found {
+			return u
+		}
+	}
+	return resolveURL(serverURL, nil, "things")
+}
+
+// thingsPageURL is the URL of the first page of the listing with the given limit,
+// as described by the directory TD or the default path
+func thingsPageURL(serverURL string, limit int) string {
+	if u, found := describedURL(affordanceThings, http.MethodGet, map[string]string{"limit": fmt.Sprint(limit)}); found {
+		return u
+	}
+	return resolveURL(serverURL, url.Values{"limit": {fmt.Sprint(limit)}}, "things")
+}
+
+// thingURL is the URL of a TD in the Things API for an operation on it, e.g. updateThing,
+// as described by the directory TD or the default path
+func thingURL(serverURL, id, affordance string) string {
+	variables := map[string]string{"id": id}
+	// the operation may not be described while the TD is
+	for _, a := range []string{affordance, affordanceRetrieveThing} {
+		if u, found := describedURL(a, thingsOperations[a], variables); found {
+			return u
+		}
+	}
+	return resolveURL(serverURL, nil, "things", id)
+}
+
+// searchURL is the URL of a search API, e.g. jsonpath, for a request with the given HTTP method and the query if not empty,
+// as described by the directory TD or the default path
+func searchURL(serverURL, kind, method, query string) string {
+	variables := map[string]string{}
+	if query != "" {
+		variables["query"] = query
+	}
+	if u, found := describedURL(searchAffordances[kind], method, variables); found {
+		return u
+	}
+	if query == "" {
+		return resolveURL(serverURL, nil, "search", kind)
+	}
+	return resolveURL(serverURL, url.Values{"query": {query}}, "search", kind)
+}
+
+// eventsURL is the URL of the Notification API for the event type, or all types if empty,
+// as described by the directory TD or the default path
+func eventsURL(serverURL, eventType string, diff bool) string {
+	variables := map[string]string{}
+	if diff {
+		variables["diff"] = "true"
+	}
+	if u, found := describedURL(eventAffordances[eventType], http.MethodGet, variables); found {
+		return u
+	}
+
+	segments := []string{"events"}
+	if eventType != "" {
+		segments = append(segments, eventType)
+	}
+	var query url.Values
+	if diff {
+		query = url.Values{"diff": {"true"}}
+	}
+	return resolveURL(serverURL, query, segments...)
+}
@@ -0,0 +1,156 @@
+//go:build !conformance
+// +build !conformance
+
+package directory
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestResolveURL checks the construction of directory URLs.
+func TestResolveURL(t *testing.T) {
+	// the default paths, without the ones described in the directory TD
+	hrefs := selfDescription.hrefs
+	selfDescription.hrefs = nil
+	defer func() { selfDescription.hrefs = hrefs }()
+
+	cases := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"things", thingsURL("http://localhost:8081", affordanceThings), "http://localhost:8081/things"},
+		{"trailing slash", thingsURL("http://localhost:8081/", affordanceCreateAnonymous), "http://localhost:8081/things"},
+		{"base path", thingURL("https://gateway/tdd/v1/", "urn:uuid:1", affordanceRetrieveThing), "https://gateway/tdd/v1/things/urn:uuid:1"},
+		{"escaped base path", thingsURL("https://gateway/a%2Fb", affordanceThings), "https://gateway/a%2Fb/things"},
+		{"escaped ID", thingURL("http://localhost", "urn:dev/1?a=b#c d", affordanceDeleteThing), "http://localhost/things/urn:dev%2F1%3Fa=b%23c%20d"},
+		{"search query", searchURL("http://localhost", "jsonpath", http.MethodGet, "$[?(@.tag=='a b')]"), "http://localhost/search/jsonpath?query=%24%5B%3F%28%40.tag%3D%3D%27a+b%27%29%5D"},
+		{"events", eventsURL("http://localhost/", "", false), "http://localhost/events"},
+		{"events diff", eventsURL("http://localhost", EventTypeCreate, true), "http://localhost/events/thing_created?diff=true"},
+	}
+	for _, c := range cases {
+		if c.got != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.name, c.expected, c.got)
+		}
+	}
+}
+
+// TestDescribedURL checks the expansion of the hrefs in the directory TD.
+func TestDescribedURL(t *testing.T) {
+	hrefs := selfDescription.hrefs
+	selfDescription.hrefs = map[string]map[string]string{
+		affordanceThings:          {http.MethodGet: resolveReference("https://gateway/tdd/.well-known/wot", "../things{?offset,limit,format}")},
+		affordanceCreateAnonymous: {http.MethodPost: "https://gateway/tdd/things"},
+		affordanceRetrieveThing:   {http.MethodGet: resolveReference("https://gateway/tdd/", "/td/{id}")},
+		affordanceUpdateThing:     {http.MethodPut: "https://gateway/tdd/td/{id}/update"},
+		affordanceDeleteThing:     {http.MethodPost: "https://gateway/tdd/td/{id}/delete"},
+		affordanceSearchJSONPath:  {http.MethodGet: resolveReference("https://gateway/", "search/jsonpath?a=b{&query}")},
+		affordanceSearchSPARQL: {
+			http.MethodGet:  "https://gateway/sparql{?query}",
+			http.MethodPost: "https://gateway/sparql/post",
+		},
+		affordanceThingCreated: {http.MethodGet: "http://events.example.com/events/thing_created{?diff}"},
+	}
+	defer func() { selfDescription.hrefs = hrefs }()
+
+	cases := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"optional query", thingsURL("", affordanceThings), "https://gateway/tdd/things"},
+		{"create anonymous", thingsURL("", affordanceCreateAnonymous), "https://gateway/tdd/things"},
+		{"escaped ID", thingURL("", "urn:dev/1?a=b", affordanceRetrieveThing), "https://gateway/td/urn%3Adev%2F1%3Fa%3Db"},
+		{"operation form", thingURL("", "urn:dev:1", affordanceUpdateThing), "https://gateway/tdd/td/urn%3Adev%3A1/update"},
+		{"operation not described", thingURL("", "urn:dev:1", affordanceCreateThing), "https://gateway/td/urn%3Adev%3A1"},
+		{"operation with another method", thingURL("", "urn:dev:1", affordanceDeleteThing), "https://gateway/td/urn%3Adev%3A1"},
+		{"query continuation", searchURL("", "jsonpath", http.MethodGet, "$[?(@.a=='b c')]"), "https://gateway/search/jsonpath?a=b&query=%24%5B%3F%28%40.a%3D%3D%27b%20c%27%29%5D"},
+		{"form of the method", searchURL("", "sparql", http.MethodPost, ""), "https://gateway/sparql/post"},
+		{"absolute href", eventsURL("", EventTypeCreate, true), "http://events.example.com/events/thing_created?diff=true"},
+		{"not described", eventsURL("http://localhost", EventTypeDelete, false), "http://localhost/events/thing_deleted"},
+		{"reserved expansion", expandURITemplate("/a{+path}", map[string]string{"path": "/b/c?d"}), "/a/b/c?d"},
+	}
+	for _, c := range cases {
+		if c.got != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.name, c.expected, c.got)
+		}
+	}
+}
+
+// TestResolveReference checks the resolution of the hrefs of the directory TD (RFC 3986, Section 5.4).
+func TestResolveReference(t *testing.T) {
+	base := "https://gateway/tdd/.well-known/wot?a=b"
+	cases := []struct {
+		href     string
+		expected string
+	}{
+		{"things", "https://gateway/tdd/.well-known/things"},
+		{"./things", "https://gateway/tdd/.well-known/things"},
+		{"../things{?offset,limit}", "https://gateway/tdd/things{?offset,limit}"},
+		{"../../../things", "https://gateway/things"},
+		{"/things/{id}", "https://gateway/things/{id}"},
+		{"?query={query}", "https://gateway/tdd/.well-known/wot?query={query}"},
+		{"{?query}", "https://gateway/tdd/.well-known/wot{?query}"},
+		{"", "https://gateway/tdd/.well-known/wot?a=b"},
+		{"//events.example.com/events", "https://events.example.com/events"},
+		{"coap://gateway/things", "coap://gateway/things"},
+		{"search?redirect=http://other/", "https://gateway/tdd/.well-known/search?redirect=http://other/"},
+	}
+	for _, c := range cases {
+		if got := resolveReference(base, c.href); got != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.href, c.expected, got)
+		}
+	}
+}
+
+// TestHeaderFlag checks that the extra headers are set on requests.
+func TestHeaderFlag(t *testing.T) {
+	headers := make(headerFlag)
+	for _, h := range []string{"X-Tenant: a", "X-Api-Key:  secret "} {
+		if err := headers.Set(h); err != nil {
+			t.Fatalf("Error setting header %s: %s", h, err)
+		}
+	}
+	if err := headers.Set("invalid"); err == nil {
+		t.Fatalf("Expected an error for a header without value")
+	}
+
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &headerTransport{base: http.DefaultTransport, header: http.Header(headers)}}
+	res, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Error getting: %s", err)
+	}
+	res.Body.Close()
+
+	if got.Get("X-Tenant") != "a" || got.Get("X-Api-Key") != "secret" {
+		t.Fatalf("Expected the extra headers, got: %v", got)
+	}
+}
+
+// restoreHTTPClients restores the shared clients and the recorded traffic when a test that sets them up ends
+func restoreHTTPClients(t *testing.T) {
+	clients := []*http.Client{httpClient, unauthenticatedClient, streamClient}
+	saved := make([]http.Client, len(clients))
+	for i, c := range clients {
+		saved[i] = *c
+	}
+	archive.Lock()
+	entries, uuids := archive.entries, archive.uuids
+	archive.Unlock()
+	t.Cleanup(func() {
+		for i, c := range clients {
+			*c = saved[i]
+		}
+		archive.Lock()
+		archive.entries, archive.uuids = entries, uuids
+		archive.Unlock()
+	})
+}
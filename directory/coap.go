@@ -0,0 +1,488 @@
+package directory
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The CoAP binding (RFC 7252) of the directory API.
+// Requests to coap:// URLs are sent by coapTransport, which translates the HTTP requests of the tests
+// to confirmable CoAP messages over UDP and the CoAP responses back to HTTP responses, following the
+// HTTP-CoAP mapping (RFC 8075). The helpers and assertions of the tests are therefore used unchanged.
+// Headers other than Content-Type and Accept are not sent, and event streams are not supported.
+
+const (
+	coapDefaultPort    = "5683"
+	coapAckTimeout     = 2 * time.Second
+	coapMaxRetransmit  = 4
+	coapMaxMessageSize = 64 * 1024
+)
+
+// CoAP message types
+const (
+	coapConfirmable    = 0
+	coapNonConfirmable = 1
+	coapAcknowledgment = 2
+	coapReset          = 3
+)
+
+// CoAP option numbers
+const (
+	coapOptionURIHost       = 3
+	coapOptionLocationPath  = 8
+	coapOptionURIPath       = 11
+	coapOptionContentFormat = 12
+	coapOptionURIQuery      = 15
+	coapOptionAccept        = 17
+	coapOptionLocationQuery = 20
+	coapOptionBlock2        = 23
+)
+
+// coapMethods are the CoAP method codes of the HTTP methods (RFC 7252 and RFC 8132)
+var coapMethods = map[string]uint8{
+	http.MethodGet:    coapCode(0, 1),
+	http.MethodPost:   coapCode(0, 2),
+	http.MethodPut:    coapCode(0, 3),
+	http.MethodDelete: coapCode(0, 4),
+	http.MethodPatch:  coapCode(0, 6),
+}
+
+// coapContentFormats are the registered CoAP content-formats of the media types used by the directory API
+var coapContentFormats = map[string]uint16{
+	"text/plain":              0,
+	"application/json":        50,
+	MediaTypeMergePatch:       52,
+	"application/cbor":        60,
+	MediaTypeThingDescription: 432,
+}
+
+func coapCode(class, detail uint8) uint8 {
+	return class<<5 | detail
+}
+
+// coapStatusCode maps a CoAP response code to the HTTP status code expected by the tests (RFC 8075, Section 7).
+// The success codes without payload map to 204 No Content.
+func coapStatusCode(code uint8, payload []byte) int {
+	class, detail := int(code>>5), int(code&0x1f)
+	switch code {
+	case coapCode(2, 1): // Created
+		return http.StatusCreated
+	case coapCode(2, 2), coapCode(2, 4): // Deleted, Changed
+		if len(payload) > 0 {
+			return http.StatusOK
+		}
+		return http.StatusNoContent
+	case coapCode(2, 3): // Valid
+		return http.StatusNotModified
+	case coapCode(2, 5): // Content
+		return http.StatusOK
+	case coapCode(4, 2), coapCode(4, 8): // Bad Option, Request Entity Incomplete
+		return http.StatusBadRequest
+	case coapCode(5, 5): // Proxying Not Supported
+		return http.StatusBadGateway
+	}
+	return class*100 + detail
+}
+
+// coapContentType returns the media type of a content-format
+func coapContentType(format uint16) string {
+	for mediaType, f := range coapContentFormats {
+		if f == format {
+			return mediaType
+		}
+	}
+	return "application/octet-stream"
+}
+
+// coapContentFormat returns the content-format of the media type in a Content-Type or Accept header
+func coapContentFormat(contentType string) (uint16, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return 0, err
+	}
+	format, found := coapContentFormats[mediaType]
+	if !found {
+		return 0, fmt.Errorf("no CoAP content-format for %s", mediaType)
+	}
+	return format, nil
+}
+
+type coapOption struct {
+	number uint16
+	value  []byte
+}
+
+type coapMessage struct {
+	typ       uint8
+	code      uint8
+	messageID uint16
+	token     []byte
+	options   []coapOption
+	payload   []byte
+}
+
+func (m *coapMessage) option(number uint16) ([]byte, bool) {
+	for _, o := range m.options {
+		if o.number == number {
+			return o.value, true
+		}
+	}
+	return nil, false
+}
+
+func (m *coapMessage) optionStrings(number uint16) []string {
+	var values []string
+	for _, o := range m.options {
+		if o.number == number {
+			values = append(values, string(o.value))
+		}
+	}
+	return values
+}
+
+// optionUint returns the value of an option of the uint format, zero if absent
+func (m *coapMessage) optionUint(number uint16) uint32 {
+	value, _ := m.option(number)
+	var v uint32
+	for _, b := range value {
+		v = v<<8 | uint32(b)
+	}
+	return v
+}
+
+func coapUint(v uint32) []byte {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	return b
+}
+
+func (m *coapMessage) marshal() []byte {
+	var b bytes.Buffer
+	b.WriteByte(1<<6 | m.typ<<4 | byte(len(m.token)))
+	b.WriteByte(m.code)
+	binary.Write(&b, binary.BigEndian, m.messageID)
+	b.Write(m.token)
+
+	sort.SliceStable(m.options, func(i, j int) bool {
+		return m.options[i].number < m.options[j].number
+	})
+	var previous uint16
+	for _, o := range m.options {
+		delta, deltaExt := coapOptionNibble(int(o.number - previous))
+		length, lengthExt := coapOptionNibble(len(o.value))
+		b.WriteByte(delta<<4 | length)
+		b.Write(deltaExt)
+		b.Write(lengthExt)
+		b.Write(o.value)
+		previous = o.number
+	}
+	if len(m.payload) > 0 {
+		b.WriteByte(0xff)
+		b.Write(m.payload)
+	}
+	return b.Bytes()
+}
+
+// coapOptionNibble encodes an option delta or length in a nibble and extended bytes
+func coapOptionNibble(v int) (byte, []byte) {
+	switch {
+	case v < 13:
+		return byte(v), nil
+	case v < 269:
+		return 13, []byte{byte(v - 13)}
+	default:
+		return 14, []byte{byte((v - 269) >> 8), byte(v - 269)}
+	}
+}
+
+func unmarshalCoAPMessage(b []byte) (*coapMessage, error) {
+	if len(b) < 4 || b[0]>>6 != 1 {
+		return nil, errors.New("invalid CoAP message header")
+	}
+	m := &coapMessage{
+		typ:       b[0] >> 4 & 0x3,
+		code:      b[1],
+		messageID: binary.BigEndian.Uint16(b[2:4]),
+	}
+	tokenLength := int(b[0] & 0xf)
+	if tokenLength > 8 || len(b) < 4+tokenLength {
+		return nil, errors.New("invalid CoAP token")
+	}
+	m.token = b[4 : 4+tokenLength]
+	b = b[4+tokenLength:]
+
+	var number int
+	for len(b) > 0 {
+		if b[0] == 0xff {
+			m.payload = b[1:]
+			break
+		}
+		delta, length := int(b[0]>>4), int(b[0]&0xf)
+		b = b[1:]
+		var err error
+		if delta, b, err = coapOptionExtended(delta, b); err != nil {
+			return nil, err
+		}
+		if length, b, err = coapOptionExtended(length, b); err != nil {
+			return nil, err
+		}
+		if len(b) < length {
+			return nil, errors.New("invalid CoAP option length")
+		}
+		number += delta
+		m.options = append(m.options, coapOption{number: uint16(number), value: b[:length]})
+		b = b[length:]
+	}
+	return m, nil
+}
+
+func coapOptionExtended(v int, b []byte) (int, []byte, error) {
+	switch v {
+	case 13:
+		if len(b) < 1 {
+			return 0, nil, errors.New("invalid CoAP option")
+		}
+		return int(b[0]) + 13, b[1:], nil
+	case 14:
+		if len(b) < 2 {
+			return 0, nil, errors.New("invalid CoAP option")
+		}
+		return int(binary.BigEndian.Uint16(b)) + 269, b[2:], nil
+	case 15:
+		return 0, nil, errors.New("invalid CoAP option")
+	}
+	return v, b, nil
+}
+
+// coapTransport is an http.RoundTripper that sends requests to coap:// URLs over CoAP
+type coapTransport struct {
+	sync.Mutex
+	messageID uint16
+}
+
+func (t *coapTransport) nextMessageID() uint16 {
+	t.Lock()
+	defer t.Unlock()
+	t.messageID++
+	return t.messageID
+}
+
+func (t *coapTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	code, found := coapMethods[req.Method]
+	if !found {
+		return nil, fmt.Errorf("method %s is not supported over CoAP", req.Method)
+	}
+	var payload []byte
+	if req.Body != nil {
+		var err error
+		payload, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	options, err := coapRequestOptions(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		format, err := coapContentFormat(contentType)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, coapOption{coapOptionContentFormat, coapUint(uint32(format))})
+	}
+	if accept := req.Header.Get("Accept"); accept != "" {
+		format, err := coapContentFormat(accept)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, coapOption{coapOptionAccept, coapUint(uint32(format))})
+	}
+
+	host := req.URL.Host
+	if req.URL.Port() == "" {
+		host = net.JoinHostPort(req.URL.Hostname(), coapDefaultPort)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(req.Context(), "udp", host)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// unblock reads when the request is canceled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-req.Context().Done():
+			conn.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	var response *coapMessage
+	var body []byte
+	requestOptions := options
+	for {
+		m := &coapMessage{
+			typ:       coapConfirmable,
+			code:      code,
+			messageID: t.nextMessageID(),
+			token:     make([]byte, 8),
+			options:   requestOptions,
+			payload:   payload,
+		}
+		rand.Read(m.token)
+		response, err = coapExchange(req.Context(), conn, m)
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, response.payload...)
+
+		_, found := response.option(coapOptionBlock2)
+		block2 := response.optionUint(coapOptionBlock2)
+		if !found || block2&0x8 == 0 || req.Method != http.MethodGet {
+			break
+		}
+		// request the next block of a large response, with the size chosen by the server (RFC 7959)
+		next := (block2>>4+1)<<4 | block2&0x7
+		requestOptions = append(append([]coapOption{}, options...), coapOption{coapOptionBlock2, coapUint(next)})
+	}
+
+	status := coapStatusCode(response.code, body)
+	res := &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "CoAP/1.0",
+		ProtoMajor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	if _, found := response.option(coapOptionContentFormat); found {
+		res.Header.Set("Content-Type", coapContentType(uint16(response.optionUint(coapOptionContentFormat))))
+	}
+	if paths := response.optionStrings(coapOptionLocationPath); len(paths) > 0 {
+		res.Header.Set("Location", coapURIReference(paths, response.optionStrings(coapOptionLocationQuery)))
+	}
+	return res, nil
+}
+
+// coapURIReference returns the escaped relative URI of the path segments and query arguments of path and query options
+func coapURIReference(paths, queries []string) string {
+	escaped := make([]string, len(paths))
+	for i, path := range paths {
+		escaped[i] = url.PathEscape(path)
+	}
+	reference := "/" + strings.Join(escaped, "/")
+	if len(queries) > 0 {
+		escaped = make([]string, len(queries))
+		for i, query := range queries {
+			nameValue := strings.SplitN(query, "=", 2)
+			escaped[i] = url.QueryEscape(nameValue[0])
+			if len(nameValue) == 2 {
+				escaped[i] += "=" + url.QueryEscape(nameValue[1])
+			}
+		}
+		reference += "?" + strings.Join(escaped, "&")
+	}
+	return reference
+}
+
+// coapRequestOptions returns the Uri-Host, Uri-Path and Uri-Query options of a URL
+func coapRequestOptions(u *url.URL) ([]coapOption, error) {
+	var options []coapOption
+	if net.ParseIP(u.Hostname()) == nil {
+		options = append(options, coapOption{coapOptionURIHost, []byte(u.Hostname())})
+	}
+	path := strings.TrimPrefix(u.EscapedPath(), "/")
+	if path != "" {
+		for _, segment := range strings.Split(path, "/") {
+			s, err := url.PathUnescape(segment)
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, coapOption{coapOptionURIPath, []byte(s)})
+		}
+	}
+	if u.RawQuery != "" {
+		for _, argument := range strings.Split(u.RawQuery, "&") {
+			s, err := url.QueryUnescape(argument)
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, coapOption{coapOptionURIQuery, []byte(s)})
+		}
+	}
+	return options, nil
+}
+
+// coapExchange sends a confirmable request and waits for its response, which is either piggybacked
+// on the acknowledgment or sent separately. The request is retransmitted with exponential back-off
+// until it is acknowledged (RFC 7252, Section 4.2).
+func coapExchange(ctx context.Context, conn net.Conn, request *coapMessage) (*coapMessage, error) {
+	b := request.marshal()
+	buf := make([]byte, coapMaxMessageSize)
+	timeout := coapAckTimeout
+	acknowledged := false
+	for attempt := 0; ; attempt++ {
+		if !acknowledged {
+			if _, err := conn.Write(b); err != nil {
+				return nil, err
+			}
+			conn.SetReadDeadline(time.Now().Add(timeout))
+		}
+		for {
+			n, err := conn.Read(buf)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && !acknowledged && attempt < coapMaxRetransmit {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("no CoAP response: %w", err)
+			}
+			m, err := unmarshalCoAPMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			switch {
+			case m.typ == coapReset && m.messageID == request.messageID:
+				return nil, errors.New("CoAP request was reset")
+			case m.typ == coapAcknowledgment && m.messageID == request.messageID && m.code == 0:
+				// empty acknowledgment, the response follows separately
+				acknowledged = true
+				conn.SetReadDeadline(time.Time{})
+			case m.typ == coapAcknowledgment && m.messageID == request.messageID && bytes.Equal(m.token, request.token):
+				return m, nil
+			case (m.typ == coapConfirmable || m.typ == coapNonConfirmable) && bytes.Equal(m.token, request.token):
+				if m.typ == coapConfirmable {
+					ack := &coapMessage{typ: coapAcknowledgment, messageID: m.messageID}
+					conn.Write(ack.marshal())
+				}
+				return m, nil
+			}
+		}
+		timeout *= 2
+	}
+}
@@ -0,0 +1,266 @@
+//go:build !conformance
+// +build !conformance
+
+package directory
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/wot-discovery-testing/directory/reference"
+)
+
+// TestCoAPBinding checks the Things API helpers over CoAP against the reference directory served over CoAP.
+func TestCoAPBinding(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	standIn := &coapStandIn{conn: conn, handler: reference.NewDirectory()}
+	go standIn.serve()
+	defer conn.Close()
+	serverURL := "coap://" + conn.LocalAddr().String()
+
+	restoreHTTPClients(t)
+	setupHTTPClient(clientConfig{tls: &tls.Config{}})
+	hrefs := selfDescription.hrefs
+	selfDescription.hrefs = nil
+	defer func() {
+		selfDescription.hrefs = hrefs
+	}()
+
+	id := "urn:uuid:" + newUUID()
+	td := mockedTD(id)
+
+	t.Run("create", func(t *testing.T) {
+		createThing(id, td, serverURL, t)
+	})
+
+	t.Run("retrieve", func(t *testing.T) {
+		retrievedTD := retrieveThing(id, serverURL, t)
+		assertEqualTitle(t, td, retrievedTD)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		td["title"] = "updated title"
+		updateThing(id, td, serverURL, t)
+		retrievedTD := retrieveThing(id, serverURL, t)
+		assertEqualTitle(t, td, retrievedTD)
+	})
+
+	t.Run("create anonymous", func(t *testing.T) {
+		b, _ := json.Marshal(mockedTD(""))
+		res, err := httpPost(thingsURL(serverURL, affordanceCreateAnonymous), MediaTypeThingDescription, b, t)
+		if err != nil {
+			t.Fatalf("Error posting: %s", err)
+		}
+		body := httpReadBody(res, t)
+		assertStatusCode(t, res, http.StatusCreated, body)
+		if !strings.Contains(res.Header.Get("Location"), "urn:uuid:") {
+			t.Fatalf("Expected Location of the created TD, got: %s", res.Header.Get("Location"))
+		}
+	})
+
+	t.Run("retrieve all in blocks", func(t *testing.T) {
+		// a listing that is larger than a block
+		for i := 0; i < 20; i++ {
+			id := "urn:uuid:" + newUUID()
+			createThing(id, mockedTD(id), serverURL, t)
+		}
+		tds := retrieveAllThings(serverURL, t)
+		if len(tds) != 22 {
+			t.Fatalf("Expected 22 TDs, got: %d", len(tds))
+		}
+		if blocks := standIn.blockCount(); blocks < 2 {
+			t.Fatalf("Expected a response in blocks, got %d blocks", blocks)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		deleteThing(id, serverURL, t)
+
+		res, err := httpGet(thingURL(serverURL, id, affordanceRetrieveThing), t)
+		if err != nil {
+			t.Fatalf("Error getting TD: %s", err)
+		}
+		body := httpReadBody(res, t)
+		assertStatusCode(t, res, http.StatusNotFound, body)
+	})
+
+	t.Run("separate response", func(t *testing.T) {
+		standIn.setSeparate(true)
+		defer standIn.setSeparate(false)
+		tds := retrieveAllThings(serverURL, t)
+		if len(tds) != 21 {
+			t.Fatalf("Expected 21 TDs, got: %d", len(tds))
+		}
+	})
+}
+
+// TestCoAPURIReference checks the escaping of the Location-Path and Location-Query options into the Location header.
+func TestCoAPURIReference(t *testing.T) {
+	for _, c := range []struct {
+		paths, queries []string
+		expected       string
+	}{
+		{[]string{"things", "urn:uuid:1"}, nil, "/things/urn:uuid:1"},
+		{[]string{"things", "urn:a/b?c%d"}, nil, "/things/urn:a%2Fb%3Fc%25d"},
+		{[]string{"things"}, []string{"offset=1", "format=a&b=c", "q=50%"}, "/things?offset=1&format=a%26b%3Dc&q=50%25"},
+		{[]string{"things"}, []string{"a b"}, "/things?a+b"},
+	} {
+		reference := coapURIReference(c.paths, c.queries)
+		if reference != c.expected {
+			t.Errorf("Expected %s for %v %v, got %s", c.expected, c.paths, c.queries, reference)
+			continue
+		}
+		u, err := url.Parse(reference)
+		if err != nil {
+			t.Errorf("Error parsing %s: %s", reference, err)
+			continue
+		}
+		if last := c.paths[len(c.paths)-1]; !strings.HasSuffix(u.Path, "/"+last) {
+			t.Errorf("Expected the path of %s to end with segment %s, got %s", reference, last, u.Path)
+		}
+	}
+}
+
+// coapStandIn serves an HTTP handler over CoAP, like a constrained directory would
+type coapStandIn struct {
+	conn    net.PacketConn
+	handler http.Handler
+
+	sync.Mutex      // the stand-in serves in its own goroutine
+	separate   bool // send the responses separately from the acknowledgments
+	blocks     int  // number of blocks sent after the first
+}
+
+func (s *coapStandIn) setSeparate(separate bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.separate = separate
+}
+
+func (s *coapStandIn) blockCount() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.blocks
+}
+
+const coapStandInBlockSize = 1024
+
+func (s *coapStandIn) serve() {
+	buf := make([]byte, coapMaxMessageSize)
+	var messageID uint16 = 0x8000
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req, err := unmarshalCoAPMessage(buf[:n])
+		if err != nil || req.typ != coapConfirmable || req.code == 0 {
+			continue
+		}
+		res := s.respond(req)
+		res.token = req.token
+		s.Lock()
+		separate := s.separate
+		s.Unlock()
+		if separate {
+			ack := &coapMessage{typ: coapAcknowledgment, messageID: req.messageID}
+			s.conn.WriteTo(ack.marshal(), addr)
+			messageID++
+			res.typ, res.messageID = coapConfirmable, messageID
+		} else {
+			res.typ, res.messageID = coapAcknowledgment, req.messageID
+		}
+		s.conn.WriteTo(res.marshal(), addr)
+	}
+}
+
+// respond maps a CoAP request to the HTTP handler and its response back to CoAP (RFC 8075)
+func (s *coapStandIn) respond(m *coapMessage) *coapMessage {
+	method := ""
+	for name, code := range coapMethods {
+		if code == m.code {
+			method = name
+		}
+	}
+	target := coapURIReference(m.optionStrings(coapOptionURIPath), m.optionStrings(coapOptionURIQuery))
+	req := httptest.NewRequest(method, target, strings.NewReader(string(m.payload)))
+	if _, found := m.option(coapOptionContentFormat); found {
+		req.Header.Set("Content-Type", coapContentType(uint16(m.optionUint(coapOptionContentFormat))))
+	}
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(recorder, req)
+	res := recorder.Result()
+	body, _ := io.ReadAll(res.Body)
+
+	r := &coapMessage{code: coapResponseCode(method, res.StatusCode)}
+	if contentType := res.Header.Get("Content-Type"); contentType != "" {
+		format, err := coapContentFormat(contentType)
+		if err != nil && strings.Contains(contentType, "+json") {
+			// e.g. application/ld+json and application/problem+json have no content-format
+			format, err = coapContentFormats["application/json"], nil
+		}
+		if err == nil {
+			r.options = append(r.options, coapOption{coapOptionContentFormat, coapUint(uint32(format))})
+		}
+	}
+	if location := res.Header.Get("Location"); location != "" {
+		for _, segment := range strings.Split(strings.TrimPrefix(location, "/"), "/") {
+			segment, _ = url.PathUnescape(segment)
+			r.options = append(r.options, coapOption{coapOptionLocationPath, []byte(segment)})
+		}
+	}
+
+	// send large responses in blocks (RFC 7959)
+	if len(body) > coapStandInBlockSize {
+		block := int(m.optionUint(coapOptionBlock2) >> 4)
+		start, end := block*coapStandInBlockSize, (block+1)*coapStandInBlockSize
+		more := uint32(8)
+		if end >= len(body) {
+			end, more = len(body), 0
+		}
+		if block > 0 {
+			s.Lock()
+			s.blocks++
+			s.Unlock()
+		}
+		r.options = append(r.options, coapOption{coapOptionBlock2, coapUint(uint32(block)<<4 | more | 6)})
+		body = body[start:end]
+	}
+	r.payload = body
+	return r
+}
+
+// coapResponseCode maps an HTTP status code to a CoAP response code (RFC 8075, Section 7)
+func coapResponseCode(method string, status int) uint8 {
+	switch status {
+	case http.StatusOK:
+		if method == http.MethodGet {
+			return coapCode(2, 5)
+		}
+		return coapCode(2, 4)
+	case http.StatusCreated:
+		return coapCode(2, 1)
+	case http.StatusNoContent:
+		if method == http.MethodDelete {
+			return coapCode(2, 2)
+		}
+		return coapCode(2, 4)
+	case http.StatusNotModified:
+		return coapCode(2, 3)
+	}
+	if status%100 < 32 {
+		return coapCode(uint8(status/100), uint8(status%100))
+	}
+	return coapCode(uint8(status/100), 0)
+}
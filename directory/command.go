@@ -0,0 +1,227 @@
+package directory
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/wot-discovery-testing/directory/reference"
+)
+
+// commandUsage describes the commands of the test binary, built as the wot-tdd-test command with go test -c.
+// Without a command, the binary runs the tests like go test.
+const commandUsage = `Usage: wot-tdd-test [command] [flags]
+
+Commands:
+  run          run the tests and write the reports, the default
+  list         list the tests and the assertions they cover
+  report       write the reports again from saved results
+  validate-td  validate TD files against the JSON Schema of TDs
+
+Run wot-tdd-test <command> -h for the flags of a command.
+`
+
+// runCommand runs a command of the test binary, given with its arguments, and returns the exit code of the process.
+// The tests run with the given function, i.e. the Run method of testing.M.
+func runCommand(args []string, runTests func() int) int {
+	switch args[0] {
+	case "run":
+		return runTestsCommand(args[1:], runTests)
+	case "list":
+		return listCommand(args[1:], runTests)
+	case "report":
+		return reportCommand(args[1:])
+	case "validate-td":
+		return validateTDCommand(args[1:])
+	}
+	fmt.Printf("Unknown command: %s\n\n%s", args[0], commandUsage)
+	return 2
+}
+
+// runTestsCommand runs the tests and writes the reports.
+// It takes the flags of the tests, and those of test binaries with the test. prefix, e.g. -test.timeout.
+func runTestsCommand(args []string, runTests func() int) int {
+	run := flag.String("run", "", "Regular expression selecting the tests to run, like -test.run")
+	flag.CommandLine.Parse(args)
+	if *run != "" {
+		flag.Set("test.run", *run)
+	}
+	invocation.command, invocation.args = os.Args[:len(os.Args)-len(args)], args
+
+	return runSuite(func() bool {
+		return runTests() == 0
+	})
+}
+
+// listCommand prints the tests and the assertions they report.
+// These are found by running the tests quietly against the in-memory reference directory, or read from saved results.
+func listCommand(args []string, runTests func() int) int {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	from := fs.String("from", "", "JSON report to read the tests from, instead of running them against the in-memory reference directory")
+	run := fs.String("run", "", "Regular expression selecting the tests to list")
+	fs.Parse(args)
+
+	if *from != "" {
+		_, err := loadJSONResults(*from)
+		if err != nil {
+			fmt.Printf("Error reading saved results: %s\n", err)
+			return 1
+		}
+		printTests(*run)
+		return 0
+	}
+
+	server := httptest.NewServer(reference.NewDirectory())
+	defer server.Close()
+	serverURL = server.URL
+	setupHTTPClient(clientConfig{tls: &tls.Config{}})
+	discoverEndpoints(selfDescriptionURL(serverURL))
+	selectCapabilities(capabilitiesAuto, serverURL)
+	results = make(map[string]result)
+	exchanges = make(map[string][]exchange)
+	flag.Set("test.run", *run)
+
+	// only the reported assertions are needed, not the output of the tests
+	stdout := os.Stdout
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	runTests()
+	os.Stdout = stdout
+
+	printTests("")
+	return 0
+}
+
+// printTests prints the reported subtests of the tests whose names match the pattern, with their assertions
+func printTests(pattern string) {
+	re := regexp.MustCompile(pattern)
+	var tests []string
+	lines := make(map[string][]string)
+	for _, s := range subtestsFromResults(results) {
+		test := topLevelTest(s.name)
+		if !re.MatchString(test) {
+			continue
+		}
+		if _, found := lines[test]; !found {
+			tests = append(tests, test)
+		}
+		lines[test] = append(lines[test], fmt.Sprintf("    %s: %s", s.name, strings.Join(s.assertions, " ")))
+	}
+	for _, test := range tests {
+		fmt.Println(test)
+		fmt.Println(strings.Join(lines[test], "\n"))
+	}
+}
+
+// reportCommand writes the reports again from the results saved in a JSON report and the HAR file of the same run,
+// e.g. in other formats or with other waivers.
+func reportCommand(args []string) int {
+	var config reportConfig
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	from := fs.String("from", "", "JSON report with the saved results. Defaults to tdd-auto.json in the report directory")
+	har := fs.String("har", "", "HAR file of the saved results, with the exchanges of the subtests. Ignored if missing. Defaults to tdd-auto.har in the report directory")
+	fs.StringVar(&reportDir, "reportDir", defaultReportDir, "Directory to write the reports to")
+	formats := fs.String("reportFormats", reportFormatCSV, "Comma-separated list of report formats: csv, junit, earl-turtle, earl-jsonld, html, json")
+	fs.StringVar(&config.specVersion, "specVersion", "", "Spec version of the embedded assertion catalogs to use, instead of downloading them. Defaults to the catalog of the saved results")
+	fs.StringVar(&config.templateURL, "templateURL", "", "URL to download assertions template. Defaults to the catalog of the saved results or "+defaultTemplateURL)
+	fs.StringVar(&config.manualURL, "manualURL", defaultManualURL, "URL to download template for assertions that are tested manually")
+	fs.StringVar(&config.manualResults, "manualResults", "", "CSV file with results of manual testing, to be merged into a combined report")
+	fs.StringVar(&config.waivers, "waivers", "", "CSV file with expected outcomes of assertions or subtests. If set, the process exits with an error on unexpected outcomes")
+	fs.Parse(args)
+	if *from == "" {
+		*from = reportPath(jsonReportFile)
+	}
+	if *har == "" {
+		*har = reportPath(harFile)
+	}
+
+	saved, err := loadJSONResults(*from)
+	if err != nil {
+		fmt.Printf("Error reading saved results: %s\n", err)
+		return 1
+	}
+	err = loadHARExchanges(*har, saved)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("Error reading the HAR file: %s\n", err)
+		return 1
+	}
+
+	if config.specVersion == "" && config.templateURL == "" {
+		if strings.HasPrefix(saved.Catalog, "embedded ") {
+			config.specVersion = strings.TrimPrefix(saved.Catalog, "embedded ")
+		} else if saved.Catalog != "" && saved.Catalog != catalogNone {
+			config.templateURL = saved.Catalog
+		} else {
+			config.templateURL = defaultTemplateURL
+		}
+	}
+	config.serverURL = saved.Server
+	config.effective = saved.Config
+	config.formats = strings.Split(*formats, ",")
+
+	unexpected := writeReports(config, loadReportSources(config), saved.TLS)
+	if config.waivers != "" && unexpected > 0 {
+		fmt.Printf("%d assertions had an unexpected outcome.\n", unexpected)
+		return 1
+	}
+	return 0
+}
+
+// validateTDCommand validates TD files, or standard input given as -, against the JSON Schema of TDs
+func validateTDCommand(args []string) int {
+	fs := flag.NewFlagSet("validate-td", flag.ExitOnError)
+	schemaLocation := fs.String("schema", defaultTDSchemaURL, "URL or file of the JSON Schema of TDs to validate against")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: validate-td [-schema <URL or file>] <file>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	schema, err := loadTDSchema(*schemaLocation)
+	if err != nil {
+		fmt.Printf("Error loading the TD schema from %s: %s\n", *schemaLocation, err)
+		return 1
+	}
+
+	code := 0
+	for _, filename := range fs.Args() {
+		var b []byte
+		var err error
+		if filename == "-" {
+			b, err = io.ReadAll(os.Stdin)
+		} else {
+			b, err = os.ReadFile(filename)
+		}
+		if err != nil {
+			fmt.Printf("%s: Error reading: %s\n", filename, err)
+			code = 1
+			continue
+		}
+		var td any
+		err = json.Unmarshal(b, &td)
+		if err != nil {
+			fmt.Printf("%s: Invalid JSON: %s\n", filename, err)
+			code = 1
+			continue
+		}
+		problems := validateTD(schema, td)
+		for _, problem := range problems {
+			fmt.Printf("%s: %s\n", filename, problem)
+		}
+		if len(problems) > 0 {
+			code = 1
+			continue
+		}
+		fmt.Printf("%s: valid\n", filename)
+	}
+	return code
+}
@@ -0,0 +1,98 @@
+//go:build !conformance
+// +build !conformance
+
+package directory
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSavedResults checks that the results read from a JSON report are those it was written from.
+func TestSavedResults(t *testing.T) {
+	saved := map[string]result{
+		"tdd-things-crud": {
+			passed: []string{"TestCreateThing/create"},
+			failed: []string{"TestDelete/delete"},
+			details: map[string]*subtestDetails{
+				"TestCreateThing/create": {elapsed: 1500 * time.Millisecond},
+				"TestDelete/delete":      {messages: []string{"things_test.go:42: Expected status 204, got: 500"}},
+			},
+		},
+		"tdd-search-xpath": {
+			skipped: []string{"TestXPath"},
+			details: map[string]*subtestDetails{
+				"TestXPath": {skipReason: "No XPath"},
+			},
+		},
+	}
+	currentResults, currentExchanges := results, exchanges
+	defer func() {
+		results, exchanges = currentResults, currentExchanges
+	}()
+
+	filename := filepath.Join(t.TempDir(), "tdd-auto.json")
+	writeJSONReport(filename, "http://localhost:8081", "embedded 1.0", nil, nil, saved, nil)
+	report, err := loadJSONResults(filename)
+	if err != nil {
+		t.Fatalf("Error loading results: %s", err)
+	}
+	if report.Server != "http://localhost:8081" || report.Catalog != "embedded 1.0" {
+		t.Fatalf("Unexpected server or catalog: %s %s", report.Server, report.Catalog)
+	}
+	if !reflect.DeepEqual(results, saved) {
+		t.Fatalf("Expected %v, got %v", saved, results)
+	}
+}
+
+// TestCheckTD checks the problems found in TDs.
+func TestCheckTD(t *testing.T) {
+	td := mockedTD("urn:uuid:" + newUUID())
+	if problems := checkTD(td); len(problems) != 0 {
+		t.Fatalf("Expected a valid TD, got: %v", problems)
+	}
+
+	delete(td, "security")
+	td["properties"] = mapAny{"status": mapAny{"type": "string"}}
+	expected := []string{"Missing security", "No forms for status of properties"}
+	if problems := checkTD(td); !reflect.DeepEqual(problems, expected) {
+		t.Fatalf("Expected %v, got %v", expected, problems)
+	}
+}
+
+// TestValidateTD checks the violations of a TD schema, given as a file, found in TDs
+func TestValidateTD(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "td-schema.json")
+	err := os.WriteFile(filename, []byte(`{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"type": "object",
+		"required": ["title", "security"],
+		"properties": {
+			"title": {"type": "string"},
+			"properties": {"additionalProperties": {"required": ["forms"]}}
+		}
+	}`), 0644)
+	if err != nil {
+		t.Fatalf("Error writing the schema: %s", err)
+	}
+	schema, err := loadTDSchema(filename)
+	if err != nil {
+		t.Fatalf("Error loading the schema: %s", err)
+	}
+
+	td := any(map[string]any(mockedTD("urn:uuid:" + newUUID())))
+	if problems := validateTD(schema, td); len(problems) != 0 {
+		t.Fatalf("Expected a valid TD, got: %v", problems)
+	}
+	var invalid any
+	json.Unmarshal([]byte(`{"title": 1, "properties": {"status": {"type": "string"}}}`), &invalid)
+	problems := validateTD(schema, invalid)
+	if len(problems) != 3 || !strings.HasPrefix(problems[0], "/: ") || !strings.HasPrefix(problems[1], "/properties/status: ") || !strings.HasPrefix(problems[2], "/title: ") {
+		t.Fatalf("Unexpected problems: %v", problems)
+	}
+}
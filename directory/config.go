@@ -0,0 +1,353 @@
+package directory
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configEnvPrefix is the prefix of the environment variables of the flags, e.g. WOT_TDD_SERVER for --server
+const configEnvPrefix = "WOT_TDD_"
+
+const configReportFile = "tdd-config.csv"
+
+var configHeader = []string{"Name", "Value", "Source"}
+
+// sources of the configuration, in increasing precedence
+const (
+	configSourceDefault = "default"
+	configSourceFile    = "file"
+	configSourceFlag    = "flag"
+	configSourceEnv     = "env"
+	// configSourceCatalog is the source of the catalog entry, resolved from the catalog flags
+	configSourceCatalog = "resolved"
+	// configSourceNegotiated is the source of the TLS entry, negotiated with the directory
+	configSourceNegotiated = "negotiated"
+)
+
+// catalogEntry is the entry of the source of the assertions, e.g. embedded 1.0 or the URL of the template
+const catalogEntry = "catalog"
+
+// tlsEntry is the entry of the negotiated TLS parameters, as in the header of the JSON and HTML reports
+const tlsEntry = "tls"
+
+var configFile string
+
+// configSources are the sources of the flags that are not set to their defaults, keyed by flag name
+var configSources map[string]string
+
+// configWaivers are the waivers listed in the config file, as records of the waivers CSV file
+var configWaivers [][]string
+
+// configEntry is a value of the effective configuration of a run, echoed into the reports
+type configEntry struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// runConfig is the config file. Secrets are given by the names of the environment variables holding them.
+type runConfig struct {
+	Server       string   `yaml:"server"`
+	DirectoryTD  string   `yaml:"directoryTD"`
+	Capabilities []string `yaml:"capabilities"`
+	Headers      []string `yaml:"headers"`
+	Auth         struct {
+		Scheme          string   `yaml:"scheme"`
+		Username        string   `yaml:"username"`
+		PasswordEnv     string   `yaml:"passwordEnv"`
+		TokenEnv        string   `yaml:"tokenEnv"`
+		TokenURL        string   `yaml:"tokenURL"`
+		ClientID        string   `yaml:"clientID"`
+		ClientSecretEnv string   `yaml:"clientSecretEnv"`
+		Scopes          []string `yaml:"scopes"`
+	} `yaml:"auth"`
+	TLS struct {
+		CA         string `yaml:"ca"`
+		Cert       string `yaml:"cert"`
+		Key        string `yaml:"key"`
+		ServerName string `yaml:"serverName"`
+		MinVersion string `yaml:"minVersion"`
+	} `yaml:"tls"`
+	Catalog struct {
+		SpecVersion string `yaml:"specVersion"`
+		TemplateURL string `yaml:"templateURL"`
+		ManualURL   string `yaml:"manualURL"`
+	} `yaml:"catalog"`
+	Report struct {
+		Formats []string `yaml:"formats"`
+		Dir     string   `yaml:"dir"`
+	} `yaml:"report"`
+	Timeouts struct {
+		Request string `yaml:"request"`
+		Test    string `yaml:"test"`
+	} `yaml:"timeouts"`
+	Retries       *int           `yaml:"retries"`
+	ManualResults string         `yaml:"manualResults"`
+	WaiversFile   string         `yaml:"waiversFile"`
+	Waivers       []configWaiver `yaml:"waivers"`
+	Targets       []target       `yaml:"targets"`
+}
+
+type configWaiver struct {
+	Pattern       string `yaml:"pattern"`
+	Expected      string `yaml:"expected"`
+	Justification string `yaml:"justification"`
+}
+
+// configValue is the value of a flag given by the config file, with several values for repeatable flags
+type configValue struct {
+	flag   string
+	values []string
+}
+
+// loadConfigFile reads the values of the flags from a config file, and the file for its other settings.
+// JSON is read as YAML. Relative paths are resolved against the directory of the file.
+func loadConfigFile(filename string) ([]configValue, runConfig, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, runConfig{}, err
+	}
+	var c runConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, c, fmt.Errorf("error decoding config file: %s", err)
+	}
+
+	path := func(p string) string {
+		if p != "" && !filepath.IsAbs(p) {
+			return filepath.Join(filepath.Dir(filename), p)
+		}
+		return p
+	}
+	var lookupErr error
+	secret := func(field, variable string) string {
+		if variable == "" {
+			return ""
+		}
+		value, found := os.LookupEnv(variable)
+		if !found && lookupErr == nil {
+			lookupErr = fmt.Errorf("%s refers to the unset environment variable %s", field, variable)
+		}
+		return value
+	}
+
+	var values []configValue
+	add := func(flag string, value ...string) {
+		if len(value) > 0 && value[0] != "" {
+			values = append(values, configValue{flag, value})
+		}
+	}
+	add("server", c.Server)
+	add("directoryTD", c.DirectoryTD)
+	add("capabilities", strings.Join(c.Capabilities, ","))
+	add("header", c.Headers...)
+	add("auth", c.Auth.Scheme)
+	add("authUsername", c.Auth.Username)
+	add("authPassword", secret("auth.passwordEnv", c.Auth.PasswordEnv))
+	add("authToken", secret("auth.tokenEnv", c.Auth.TokenEnv))
+	add("oauth2TokenURL", c.Auth.TokenURL)
+	add("oauth2ClientID", c.Auth.ClientID)
+	add("oauth2ClientSecret", secret("auth.clientSecretEnv", c.Auth.ClientSecretEnv))
+	add("oauth2Scopes", strings.Join(c.Auth.Scopes, ","))
+	add("tlsCA", path(c.TLS.CA))
+	add("tlsCert", path(c.TLS.Cert))
+	add("tlsKey", path(c.TLS.Key))
+	add("tlsServerName", c.TLS.ServerName)
+	add("tlsMinVersion", c.TLS.MinVersion)
+	add("specVersion", c.Catalog.SpecVersion)
+	add("templateURL", c.Catalog.TemplateURL)
+	add("manualURL", c.Catalog.ManualURL)
+	add("reportFormats", strings.Join(c.Report.Formats, ","))
+	add("reportDir", path(c.Report.Dir))
+	add("requestTimeout", c.Timeouts.Request)
+	add("testTimeout", c.Timeouts.Test)
+	if c.Retries != nil {
+		add("retries", strconv.Itoa(*c.Retries))
+	}
+	add("manualResults", path(c.ManualResults))
+	add("waivers", path(c.WaiversFile))
+	if lookupErr != nil {
+		return nil, c, lookupErr
+	}
+	for i := range c.Targets {
+		c.Targets[i].ManualResults = path(c.Targets[i].ManualResults)
+	}
+	return values, c, nil
+}
+
+// applyConfig completes the parsed flags with the values of the config file given by --config,
+// and overrides them with the environment variables
+func applyConfig(fs *flag.FlagSet) error {
+	configSources = make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		if suiteFlags.Lookup(f.Name) != nil {
+			configSources[f.Name] = configSourceFlag
+		}
+	})
+
+	if value, found := os.LookupEnv(envVariable("config")); found {
+		configFile = value
+		configSources["config"] = configSourceEnv
+	}
+	if configFile != "" {
+		values, c, err := loadConfigFile(configFile)
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			if configSources[v.flag] != "" {
+				continue
+			}
+			for _, value := range v.values {
+				err = fs.Set(v.flag, value)
+				if err != nil {
+					return fmt.Errorf("invalid %s in config file: %s", v.flag, err)
+				}
+			}
+			configSources[v.flag] = configSourceFile
+		}
+		for _, w := range c.Waivers {
+			configWaivers = append(configWaivers, []string{w.Pattern, w.Expected, w.Justification})
+		}
+		configTargets = c.Targets
+	}
+
+	var err error
+	suiteFlags.VisitAll(func(f *flag.Flag) {
+		value, found := os.LookupEnv(envVariable(f.Name))
+		if !found || f.Name == "config" || err != nil {
+			return
+		}
+		if f.Name == "header" {
+			// replaces the headers of the flags and the file, one per line
+			for name := range headers {
+				delete(headers, name)
+			}
+			for _, line := range strings.Split(value, "\n") {
+				if strings.TrimSpace(line) != "" && err == nil {
+					err = headers.Set(line)
+				}
+			}
+		} else {
+			err = f.Value.Set(value)
+		}
+		if err != nil {
+			err = fmt.Errorf("invalid %s: %s", envVariable(f.Name), err)
+		}
+		configSources[f.Name] = configSourceEnv
+	})
+	return err
+}
+
+// envVariable returns the environment variable of a flag, e.g. WOT_TDD_REQUEST_TIMEOUT for requestTimeout
+func envVariable(flagName string) string {
+	var b strings.Builder
+	runes := []rune(flagName)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (!unicode.IsUpper(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return configEnvPrefix + b.String()
+}
+
+// secretFlags are not echoed into the reports
+var secretFlags = []string{"authPassword", "authToken", "oauth2ClientSecret"}
+
+// effectiveConfig returns the values of all flags of a test run and their sources, without secrets
+func effectiveConfig() []configEntry {
+	var entries []configEntry
+	suiteFlags.VisitAll(func(f *flag.Flag) {
+		entry := configEntry{Name: f.Name, Value: f.Value.String(), Source: configSources[f.Name]}
+		if entry.Source == "" {
+			entry.Source = configSourceDefault
+		}
+		if inSlice(secretFlags, f.Name) && entry.Value != "" {
+			entry.Value = "(redacted)"
+		}
+		if f.Name == "header" {
+			entry.Value = redactedHeaders()
+		}
+		entries = append(entries, entry)
+	})
+	if len(configWaivers) > 0 {
+		var waivers []string
+		for _, w := range configWaivers {
+			waivers = append(waivers, w[0]+"="+w[1])
+		}
+		entries = append(entries, configEntry{Name: "waivers (inline)", Value: strings.Join(waivers, " "), Source: configSourceFile})
+	}
+	return entries
+}
+
+// redactedHeaders returns the extra headers, without the values of those that may hold credentials
+func redactedHeaders() string {
+	var lines []string
+	for name, values := range headers {
+		for _, value := range values {
+			lower := strings.ToLower(name)
+			for _, s := range []string{"auth", "key", "token", "secret", "cookie", "password"} {
+				if strings.Contains(lower, s) {
+					value = "(redacted)"
+				}
+			}
+			lines = append(lines, name+": "+value)
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, ", ")
+}
+
+// printConfig prints the values that are not set to their defaults
+func printConfig(entries []configEntry) {
+	var lines []string
+	for _, e := range entries {
+		if e.Source != configSourceDefault {
+			lines = append(lines, fmt.Sprintf("  %-18s %s (%s)", e.Name, e.Value, e.Source))
+		}
+	}
+	if len(lines) > 0 {
+		fmt.Printf("Configuration:\n%s\n", strings.Join(lines, "\n"))
+	}
+}
+
+// withCatalog returns the configuration entries with the source of the assertions that the results are reported with
+func withCatalog(entries []configEntry, catalog string) []configEntry {
+	var kept []configEntry
+	for _, e := range entries {
+		if e.Name != catalogEntry {
+			kept = append(kept, e)
+		}
+	}
+	return append(kept, configEntry{Name: catalogEntry, Value: catalog, Source: configSourceCatalog})
+}
+
+// withTLS returns the configuration entries with the TLS parameters negotiated with the directory, if any
+func withTLS(entries []configEntry, connection *tlsInfo) []configEntry {
+	if connection == nil {
+		return entries
+	}
+	return append(entries, configEntry{Name: tlsEntry, Value: connection.String(), Source: configSourceNegotiated})
+}
+
+func writeConfigReport(filename string, entries []configEntry) {
+	var records [][]string
+	for _, e := range entries {
+		records = append(records, []string{e.Name, e.Value, e.Source})
+	}
+	writeCSVFile(filename, configHeader, records)
+}
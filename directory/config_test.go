@@ -0,0 +1,143 @@
+//go:build !conformance
+// +build !conformance
+
+package directory
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestConfigFile checks the flag values read from a config file.
+func TestConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "tdd.yaml")
+	os.WriteFile(filename, []byte(`
+server: http://localhost:8081
+capabilities: [things-crud, jsonpath]
+headers:
+  - "X-Tenant: a"
+  - "X-Zone: b"
+auth:
+  scheme: bearer
+  tokenEnv: TEST_CONFIG_TOKEN
+report:
+  formats: [csv, html]
+  dir: out
+timeouts:
+  request: 10s
+retries: 0
+waivers:
+  - pattern: tdd-search-xpath.*
+    expected: "null"
+    justification: not implemented
+targets:
+  - name: a
+    server: http://localhost:8082
+    capabilities: [things-crud]
+    manualResults: a.csv
+  - name: b
+    server: http://localhost:8083
+`), 0644)
+	os.Setenv("TEST_CONFIG_TOKEN", "secret")
+	defer os.Unsetenv("TEST_CONFIG_TOKEN")
+
+	values, c, err := loadConfigFile(filename)
+	if err != nil {
+		t.Fatalf("Error loading config file: %s", err)
+	}
+	expected := []configValue{
+		{"server", []string{"http://localhost:8081"}},
+		{"capabilities", []string{"things-crud,jsonpath"}},
+		{"header", []string{"X-Tenant: a", "X-Zone: b"}},
+		{"auth", []string{"bearer"}},
+		{"authToken", []string{"secret"}},
+		{"reportFormats", []string{"csv,html"}},
+		{"reportDir", []string{filepath.Join(dir, "out")}},
+		{"requestTimeout", []string{"10s"}},
+		{"retries", []string{"0"}},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("Expected %v, got %v", expected, values)
+	}
+	if !reflect.DeepEqual(c.Waivers, []configWaiver{{"tdd-search-xpath.*", "null", "not implemented"}}) {
+		t.Fatalf("Unexpected waivers: %v", c.Waivers)
+	}
+	expectedTargets := []target{
+		{Name: "a", Server: "http://localhost:8082", Capabilities: []string{"things-crud"}, ManualResults: filepath.Join(dir, "a.csv")},
+		{Name: "b", Server: "http://localhost:8083"},
+	}
+	if !reflect.DeepEqual(c.Targets, expectedTargets) {
+		t.Fatalf("Expected targets %v, got %v", expectedTargets, c.Targets)
+	}
+
+	t.Run("unset secret", func(t *testing.T) {
+		os.WriteFile(filename, []byte(`{"auth": {"scheme": "basic", "passwordEnv": "TEST_CONFIG_UNSET"}}`), 0644)
+		if _, _, err := loadConfigFile(filename); err == nil {
+			t.Fatalf("Expected an error for the unset environment variable")
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		os.WriteFile(filename, []byte("servers: http://localhost:8081\n"), 0644)
+		if _, _, err := loadConfigFile(filename); err == nil {
+			t.Fatalf("Expected an error for the unknown field")
+		}
+	})
+}
+
+// TestEnvVariable checks the environment variables of flags.
+func TestEnvVariable(t *testing.T) {
+	for flag, expected := range map[string]string{
+		"server":         "WOT_TDD_SERVER",
+		"requestTimeout": "WOT_TDD_REQUEST_TIMEOUT",
+		"tlsCA":          "WOT_TDD_TLS_CA",
+		"oauth2TokenURL": "WOT_TDD_OAUTH2_TOKEN_URL",
+		"directoryTD":    "WOT_TDD_DIRECTORY_TD",
+	} {
+		if v := envVariable(flag); v != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, flag, v)
+		}
+	}
+}
+
+// TestConfigReport checks the TLS settings and the negotiated TLS parameters in the CSV configuration report.
+func TestConfigReport(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), configReportFile)
+	entries := []configEntry{
+		{Name: "tlsCA", Value: "/etc/ca.pem", Source: configSourceFlag},
+		{Name: "tlsCert", Value: "/etc/client.pem", Source: configSourceFlag},
+		{Name: "tlsMinVersion", Value: "1.2", Source: configSourceFile},
+		{Name: "tlsServerName", Value: "tdd.example.com", Source: configSourceEnv},
+	}
+	connection := &tlsInfo{Version: "TLS 1.3", CipherSuite: "TLS_AES_128_GCM_SHA256", ServerName: "tdd.example.com", ClientCertificate: "CN=client"}
+	writeConfigReport(filename, withTLS(entries, connection))
+
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Error opening the report: %s", err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("Error reading the report: %s", err)
+	}
+	expected := [][]string{
+		configHeader,
+		{"tlsCA", "/etc/ca.pem", configSourceFlag},
+		{"tlsCert", "/etc/client.pem", configSourceFlag},
+		{"tlsMinVersion", "1.2", configSourceFile},
+		{"tlsServerName", "tdd.example.com", configSourceEnv},
+		{tlsEntry, connection.String(), configSourceNegotiated},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Fatalf("Expected %v, got %v", expected, records)
+	}
+
+	if got := withTLS(entries, nil); !reflect.DeepEqual(got, entries) {
+		t.Fatalf("Expected no TLS entry without a TLS connection, got %v", got)
+	}
+}
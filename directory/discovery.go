@@ -0,0 +1,315 @@
+package directory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Names of the interaction affordances in the TD of a directory (https://www.w3.org/TR/wot-discovery/#exploration-directory-api)
+const (
+	affordanceThings          = "things"
+	affordanceCreateThing     = "createThing"
+	affordanceCreateAnonymous = "createAnonymousThing"
+	affordanceRetrieveThing   = "retrieveThing"
+	affordanceUpdateThing     = "updateThing"
+	affordancePartialUpdate   = "partiallyUpdateThing"
+	affordanceDeleteThing     = "deleteThing"
+	affordanceSearchJSONPath  = "searchJSONPath"
+	affordanceSearchXPath     = "searchXPath"
+	affordanceSearchSPARQL    = "searchSPARQL"
+	affordanceThingCreated    = "thingCreated"
+	affordanceThingUpdated    = "thingUpdated"
+	affordanceThingDeleted    = "thingDeleted"
+	wellKnownPath             = "/.well-known/wot"
+	directoryType             = "ThingDirectory"
+)
+
+// searchAffordances maps the kinds of search to the actions that describe them
+var searchAffordances = map[string]string{
+	"jsonpath": affordanceSearchJSONPath,
+	"xpath":    affordanceSearchXPath,
+	"sparql":   affordanceSearchSPARQL,
+}
+
+// thingsOperations are the HTTP methods of the affordances of the Things API (https://www.w3.org/TR/wot-discovery/#exploration-directory-api-things)
+var thingsOperations = map[string]string{
+	affordanceThings:          http.MethodGet,
+	affordanceCreateThing:     http.MethodPut,
+	affordanceCreateAnonymous: http.MethodPost,
+	affordanceRetrieveThing:   http.MethodGet,
+	affordanceUpdateThing:     http.MethodPut,
+	affordancePartialUpdate:   http.MethodPatch,
+	affordanceDeleteThing:     http.MethodDelete,
+}
+
+// eventAffordances maps the event types to the events that describe them
+var eventAffordances = map[string]string{
+	EventTypeCreate: affordanceThingCreated,
+	EventTypeUpdate: affordanceThingUpdated,
+	EventTypeDelete: affordanceThingDeleted,
+}
+
+// selfDescription is the TD of the directory under test, fetched before the tests
+var selfDescription struct {
+	url      string
+	response *http.Response
+	body     []byte
+	td       mapAny
+	err      error  // of fetching or decoding the TD
+	base     string // to resolve the hrefs against
+	// hrefs of the forms of the affordances by HTTP method, as URI templates resolved against the base of the TD
+	hrefs map[string]map[string]string
+}
+
+// selfDescriptionURL returns the well-known URL of the directory TD on the host of the server
+func selfDescriptionURL(serverURL string) string {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		panic(err)
+	}
+	return u.Scheme + "://" + u.Host + wellKnownPath
+}
+
+// discoverEndpoints fetches the TD of the directory and resolves the hrefs of its affordances.
+// The tests use the default paths for the affordances that are not described.
+func discoverEndpoints(tdURL string) error {
+	selfDescription.url = tdURL
+	selfDescription.hrefs = make(map[string]map[string]string)
+
+	res, err := httpClient.Get(tdURL)
+	if err != nil {
+		selfDescription.err = err
+		return err
+	}
+	defer res.Body.Close()
+	selfDescription.response = res
+
+	selfDescription.body, err = io.ReadAll(res.Body)
+	if err != nil {
+		selfDescription.err = err
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		selfDescription.err = fmt.Errorf("got status %d", res.StatusCode)
+		return selfDescription.err
+	}
+	err = json.Unmarshal(selfDescription.body, &selfDescription.td)
+	if err != nil {
+		selfDescription.err = fmt.Errorf("error decoding TD: %s", err)
+		return selfDescription.err
+	}
+
+	selfDescription.base = tdURL
+	if b, ok := selfDescription.td["base"].(string); ok && b != "" {
+		selfDescription.base = resolveReference(tdURL, b)
+	}
+	for _, kind := range []string{"properties", "actions", "events"} {
+		affordances, _ := selfDescription.td[kind].(mapAny)
+		for name, a := range affordances {
+			affordance, _ := a.(mapAny)
+			forms, _ := affordance["forms"].([]any)
+			for _, f := range forms {
+				form, _ := f.(mapAny)
+				href, ok := form["href"].(string)
+				if !ok || href == "" {
+					continue
+				}
+				if selfDescription.hrefs[name] == nil {
+					selfDescription.hrefs[name] = make(map[string]string)
+				}
+				// the first form of each method is used
+				method := formMethod(kind, form)
+				if _, found := selfDescription.hrefs[name][method]; !found {
+					selfDescription.hrefs[name][method] = resolveReference(selfDescription.base, href)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolveReference resolves a possibly relative href against a base URL (RFC 3986, Section 5.2),
+// keeping URI template expressions
+func resolveReference(base, href string) string {
+	// the expressions are replaced while resolving, since they may contain delimiters of URI components.
+	// Form-style query and fragment expansions, e.g. {?query}, start the component of their expansion.
+	var expressions, placeholders []string
+	var reference strings.Builder
+	for rest := href; ; {
+		start := strings.Index(rest, "{")
+		end := strings.Index(rest, "}")
+		if start == -1 || end < start {
+			reference.WriteString(rest)
+			break
+		}
+		expression := rest[start : end+1]
+		placeholder := fmt.Sprintf("uritemplateexpression%d", len(expressions))
+		if strings.HasPrefix(expression, "{?") || strings.HasPrefix(expression, "{#") {
+			placeholder = expression[1:2] + placeholder
+		}
+		reference.WriteString(rest[:start] + placeholder)
+		expressions = append(expressions, expression)
+		placeholders = append(placeholders, placeholder)
+		rest = rest[end+1:]
+	}
+
+	b, err := url.Parse(base)
+	if err != nil {
+		return href
+	}
+	ref, err := url.Parse(reference.String())
+	if err != nil {
+		return href
+	}
+	resolved := b.ResolveReference(ref).String()
+	for i, e := range expressions {
+		resolved = strings.Replace(resolved, placeholders[i], e, 1)
+	}
+	return resolved
+}
+
+// describedURL expands the href of the form of an affordance with the given HTTP method, described in the directory TD.
+// A HEAD request uses the form of GET. It returns false if the affordance or its form is not described.
+func describedURL(affordance, method string, variables map[string]string) (string, bool) {
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	href, found := selfDescription.hrefs[affordance][method]
+	if !found {
+		return "", false
+	}
+	return expandURITemplate(href, variables), true
+}
+
+// formMethod returns the HTTP method of a form, or the default of the operation (https://www.w3.org/TR/wot-thing-description11/#http-default-vocabulary-terms)
+func formMethod(kind string, form mapAny) string {
+	if method, ok := form["htv:methodName"].(string); ok && method != "" {
+		return method
+	}
+	switch kind {
+	case "actions":
+		return http.MethodPost
+	case "properties":
+		if op, ok := form["op"].(string); ok && op == "writeproperty" {
+			return http.MethodPut
+		}
+	}
+	return http.MethodGet
+}
+
+// expandURITemplate expands the simple ({var}), reserved ({+var}), form-style query ({?a,b})
+// and query continuation ({&a}) expressions of a URI template (RFC 6570). Undefined variables are omitted.
+func expandURITemplate(template string, variables map[string]string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(template, "{")
+		if start == -1 {
+			b.WriteString(template)
+			return b.String()
+		}
+		end := strings.Index(template[start:], "}")
+		if end == -1 {
+			b.WriteString(template)
+			return b.String()
+		}
+		b.WriteString(template[:start])
+		expression := template[start+1 : start+end]
+		template = template[start+end+1:]
+
+		operator := ""
+		if expression != "" && strings.ContainsAny(expression[:1], "+?&") {
+			operator, expression = expression[:1], expression[1:]
+		}
+		var values []string
+		for _, name := range strings.Split(expression, ",") {
+			value, defined := variables[name]
+			if !defined {
+				continue
+			}
+			switch operator {
+			case "?", "&":
+				values = append(values, name+"="+escapeUnreserved(value))
+			case "+":
+				values = append(values, value)
+			default:
+				values = append(values, escapeUnreserved(value))
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		switch operator {
+		case "?":
+			b.WriteString("?" + strings.Join(values, "&"))
+		case "&":
+			b.WriteString("&" + strings.Join(values, "&"))
+		default:
+			b.WriteString(strings.Join(values, ","))
+		}
+	}
+}
+
+// escapeUnreserved percent-encodes all characters except the unreserved ones (RFC 3986, Section 2.3)
+func escapeUnreserved(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) != -1 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// checkTD returns the problems found by basic checks of a TD: a missing TD context, title or security, or affordances without forms.
+// It does not validate the TD against the JSON Schema of TDs.
+func checkTD(td mapAny) []string {
+	var problems []string
+	if !hasValue(td["@context"], "https://www.w3.org/2019/wot/td/v1") &&
+		!hasValue(td["@context"], "https://www.w3.org/2022/wot/td/v1.1") {
+		problems = append(problems, fmt.Sprintf("The @context does not include the TD context: %v", td["@context"]))
+	}
+	if title, ok := td["title"].(string); !ok || title == "" {
+		problems = append(problems, fmt.Sprintf("Invalid or missing title: %v", td["title"]))
+	}
+	if _, ok := td["securityDefinitions"].(mapAny); !ok {
+		problems = append(problems, fmt.Sprintf("Invalid or missing securityDefinitions: %v", td["securityDefinitions"]))
+	}
+	if td["security"] == nil {
+		problems = append(problems, "Missing security")
+	}
+	for _, kind := range []string{"properties", "actions", "events"} {
+		affordances, _ := td[kind].(mapAny)
+		for name, a := range affordances {
+			affordance, _ := a.(mapAny)
+			forms, _ := affordance["forms"].([]any)
+			if len(forms) == 0 {
+				problems = append(problems, fmt.Sprintf("No forms for %s of %s", name, kind))
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// hasValue tells whether a JSON-LD value, either a single value or an array, includes the given value
+func hasValue(value any, expected string) bool {
+	switch v := value.(type) {
+	case string:
+		return v == expected
+	case []any:
+		for _, e := range v {
+			if e == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package directory
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// TestSelfDescription checks the TD of the directory, which is fetched before the tests to resolve the endpoints
+func TestSelfDescription(t *testing.T) {
+
+	runSubtest(t, "retrieve", func(t *testing.T) {
+		defer report(t, "tdd-self-description")
+		if selfDescription.response == nil {
+			fatalf(t, "Error getting the directory TD from %s: %s", selfDescription.url, selfDescription.err)
+		}
+		assertStatusCode(t, selfDescription.response, http.StatusOK, selfDescription.body)
+		assertContentMediaType(t, selfDescription.response, MediaTypeThingDescription)
+		if selfDescription.err != nil {
+			fatalf(t, "Invalid directory TD: %s", selfDescription.err)
+		}
+	})
+
+	if selfDescription.td == nil {
+		skipf(t, "No directory TD at %s", selfDescription.url)
+	}
+	td := selfDescription.td
+
+	runSubtest(t, "valid", func(t *testing.T) {
+		defer report(t, "tdd-self-description")
+		for _, problem := range checkTD(td) {
+			errorf(t, "%s", problem)
+		}
+	})
+
+	runSubtest(t, "type", func(t *testing.T) {
+		defer report(t, "tdd-self-description-type")
+		if !hasValue(td["@type"], directoryType) {
+			fatalf(t, "Expected @type %s, got: %v", directoryType, td["@type"])
+		}
+	})
+
+	runSubtest(t, "affordances", func(t *testing.T) {
+		defer report(t, "tdd-self-description-affordances")
+		// the mandatory APIs tested by this suite
+		for _, name := range []string{
+			affordanceThings,
+			affordanceCreateAnonymous,
+			affordanceRetrieveThing,
+			"createThing",
+			"updateThing",
+			"partiallyUpdateThing",
+			"deleteThing",
+		} {
+			if _, found := selfDescription.hrefs[name]; !found {
+				errorf(t, "The directory TD does not describe %s", name)
+			}
+		}
+		// optional features
+		for _, name := range []string{
+			affordanceSearchJSONPath,
+			affordanceSearchXPath,
+			affordanceSearchSPARQL,
+			affordanceThingCreated,
+			affordanceThingUpdated,
+			affordanceThingDeleted,
+		} {
+			if _, found := selfDescription.hrefs[name]; !found {
+				logf(t, "The directory TD does not describe the optional %s", name)
+			}
+		}
+	})
+
+	runSubtest(t, "list things as described", func(t *testing.T) {
+		defer report(t, "tdd-self-description-behavior")
+		if _, found := selfDescription.hrefs[affordanceThings]; !found {
+			skipf(t, "The directory TD does not describe %s", affordanceThings)
+		}
+
+		res, err := httpGet(thingsURL(serverURL, affordanceThings), t)
+		if err != nil {
+			fatalf(t, "Error getting TDs: %s", err)
+		}
+		body := httpReadBody(res, t)
+		assertStatusCode(t, res, http.StatusOK, body)
+
+		var tds []mapAny
+		err = json.Unmarshal(body, &tds)
+		if err != nil {
+			fatalf(t, "Error decoding the list of TDs: %s", err)
+		}
+	})
+
+	runSubtest(t, "retrieve thing as described", func(t *testing.T) {
+		defer report(t, "tdd-self-description-behavior")
+		if _, found := selfDescription.hrefs[affordanceRetrieveThing]; !found {
+			skipf(t, "The directory TD does not describe %s", affordanceRetrieveThing)
+		}
+
+		id := "urn:uuid:" + newUUID()
+		createThing(id, mockedTD(id), serverURL, t)
+
+		res, err := httpGet(thingURL(serverURL, id, affordanceRetrieveThing), t)
+		if err != nil {
+			fatalf(t, "Error getting TD: %s", err)
+		}
+		body := httpReadBody(res, t)
+		assertStatusCode(t, res, http.StatusOK, body)
+
+		var retrievedTD mapAny
+		err = json.Unmarshal(body, &retrievedTD)
+		if err != nil {
+			fatalf(t, "Error decoding body: %s", err)
+		}
+		if retrievedTD["id"] != id {
+			fatalf(t, "Expected TD with id %s, got: %v", id, retrievedTD["id"])
+		}
+	})
+}
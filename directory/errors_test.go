@@ -26,10 +26,11 @@ type ValidationError struct {
 func assertErrorResponse(t *testing.T, res *http.Response, body []byte) {
 	t.Helper()
 	if res == nil {
-		t.Fatalf("previous errors")
+		fatalf(t, "previous errors")
 	}
+	recordExchange(t, res, body)
 	if res.StatusCode < 400 {
-		t.Fatalf("Expected error. Status was %d", res.StatusCode)
+		fatalf(t, "Expected error. Status was %d", res.StatusCode)
 	}
 
 	if len(body) == 0 {
@@ -39,7 +40,7 @@ func assertErrorResponse(t *testing.T, res *http.Response, body []byte) {
 	var problemDetails ProblemDetails
 	err := json.Unmarshal(body, &problemDetails)
 	if err != nil {
-		t.Fatalf("Error decoding body: %s", err)
+		fatalf(t, "Error decoding body: %s", err)
 	}
 
 	/*
@@ -55,7 +56,7 @@ func assertErrorResponse(t *testing.T, res *http.Response, body []byte) {
 		https://datatracker.ietf.org/doc/html/rfc7807#section-3.1
 	*/
 	if problemDetails.Status != res.StatusCode {
-		t.Fatalf("status field not equal to http status code. Got: %d, expected: %d", problemDetails.Status, res.StatusCode)
+		fatalf(t, "status field not equal to http status code. Got: %d, expected: %d", problemDetails.Status, res.StatusCode)
 	}
 
 	/*
@@ -79,7 +80,7 @@ func assertErrorResponse(t *testing.T, res *http.Response, body []byte) {
 	} else if problemDetails.Type != "about:blank" {
 		_, err = url.ParseRequestURI(problemDetails.Type)
 		if err != nil {
-			t.Fatalf("type field not a valid URI. Got: %s", problemDetails.Type)
+			fatalf(t, "type field not a valid URI. Got: %s", problemDetails.Type)
 		}
 	}
 
@@ -98,7 +99,7 @@ func assertErrorResponse(t *testing.T, res *http.Response, body []byte) {
 	*/
 	if problemDetails.Type != "about:blank" &&
 		problemDetails.Title != http.StatusText(res.StatusCode) {
-		t.Fatalf("title field not equal to http status text. Got: %s, expected: %s", problemDetails.Title, http.StatusText(res.StatusCode))
+		fatalf(t, "title field not equal to http status text. Got: %s, expected: %s", problemDetails.Title, http.StatusText(res.StatusCode))
 
 	}
 }
@@ -107,35 +108,36 @@ func assertValidationResponse(t *testing.T, res *http.Response, body []byte) {
 	t.Helper()
 
 	if res == nil {
-		t.Fatalf("previous errors")
+		fatalf(t, "previous errors")
 	}
 	if res.StatusCode < 400 {
-		t.Fatalf("Expected error. Status was %d", res.StatusCode)
+		fatalf(t, "Expected error. Status was %d", res.StatusCode)
 	}
 
 	if len(body) == 0 {
 		body = httpReadBody(res, t)
 	}
+	recordExchange(t, res, body)
 
 	var problemDetails ProblemDetails
 	err := json.Unmarshal(body, &problemDetails)
 	if err != nil {
-		t.Fatalf("Error decoding body: %s", err)
+		fatalf(t, "Error decoding body: %s", err)
 	}
 
 	validationErrors := problemDetails.ValidationErrors
 
 	if len(validationErrors) < 1 {
-		t.Fatalf("expected one or more validation errors, got: %d.", len(validationErrors))
+		fatalf(t, "expected one or more validation errors, got: %d.", len(validationErrors))
 	}
 	for _, validationError := range validationErrors {
 
 		if validationError.Field == "" {
-			t.Fatalf("Missing validation error field in: %s", marshalPrettyJSON(validationError))
+			fatalf(t, "Missing validation error field in: %s", marshalPrettyJSON(validationError))
 		}
 
 		if validationError.Description == "" {
-			t.Fatalf("Missing validation error description in: %s", marshalPrettyJSON(validationError))
+			fatalf(t, "Missing validation error description in: %s", marshalPrettyJSON(validationError))
 		}
 	}
 }
@@ -0,0 +1,144 @@
+package directory
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// exchanges are the HTTP request/response pairs checked by each test, keyed by test name
+var exchanges map[string][]exchange
+
+// exchange is an HTTP request and the response it received
+type exchange struct {
+	method         string
+	url            string
+	requestHeader  http.Header
+	requestBody    []byte
+	status         string
+	responseHeader http.Header
+	responseBody   []byte
+
+	response *http.Response // to record each response once per test
+}
+
+// recordExchange keeps the response and the request that led to it as evidence for the test.
+// The response body should be given since it can only be read once.
+func recordExchange(t *testing.T, res *http.Response, body []byte) {
+	if exchanges == nil || res == nil {
+		return
+	}
+	name := t.Name()
+	for _, e := range exchanges[name] {
+		if e.response == res {
+			return
+		}
+	}
+
+	e := exchange{
+		status:         res.Status,
+		responseHeader: redactHeader(res.Header),
+		responseBody:   body,
+		response:       res,
+	}
+	if req := res.Request; req != nil {
+		e.method = req.Method
+		e.url = req.URL.String()
+		e.requestHeader = redactHeader(req.Header)
+		if req.GetBody != nil {
+			if r, err := req.GetBody(); err == nil {
+				e.requestBody, _ = io.ReadAll(r)
+			}
+		}
+	}
+	exchanges[name] = append(exchanges[name], e)
+}
+
+// testEvidence are the messages, elapsed time and skip reason of each test, keyed by test name.
+// They are kept by the helpers that the tests run subtests, log, fail and skip with, e.g. fatalf instead of t.Fatalf,
+// which do not depend on the output of go test.
+var testEvidence = struct {
+	sync.Mutex
+	details map[string]*subtestDetails
+}{details: make(map[string]*subtestDetails)}
+
+// keepEvidence updates the details of a test
+func keepEvidence(t *testing.T, update func(d *subtestDetails)) {
+	testEvidence.Lock()
+	defer testEvidence.Unlock()
+	d, found := testEvidence.details[t.Name()]
+	if !found {
+		d = &subtestDetails{}
+		testEvidence.details[t.Name()] = d
+	}
+	update(d)
+}
+
+// keepMessage keeps a message logged by a test
+func keepMessage(t *testing.T, message string) {
+	keepEvidence(t, func(d *subtestDetails) {
+		d.messages = append(d.messages, message)
+	})
+}
+
+// runSubtest runs f as a subtest like t.Run and keeps its elapsed time
+func runSubtest(t *testing.T, name string, f func(t *testing.T)) bool {
+	trackTopLevelTest(t)
+	return t.Run(name, func(t *testing.T) {
+		start := time.Now()
+		defer func() {
+			elapsed := time.Since(start)
+			keepEvidence(t, func(d *subtestDetails) {
+				d.elapsed = elapsed
+			})
+		}()
+		f(t)
+	})
+}
+
+// logf logs a message like t.Logf and keeps it
+func logf(t *testing.T, format string, args ...any) {
+	t.Helper()
+	message := fmt.Sprintf(format, args...)
+	keepMessage(t, message)
+	t.Log(message)
+}
+
+// errorf marks the test as failed like t.Errorf and keeps the message
+func errorf(t *testing.T, format string, args ...any) {
+	t.Helper()
+	message := fmt.Sprintf(format, args...)
+	keepMessage(t, message)
+	t.Error(message)
+}
+
+// fatalf ends the test as failed like t.Fatalf and keeps the message
+func fatalf(t *testing.T, format string, args ...any) {
+	t.Helper()
+	message := fmt.Sprintf(format, args...)
+	keepMessage(t, message)
+	t.Fatal(message)
+}
+
+// fatal ends the test as failed like t.Fatal and keeps the message
+func fatal(t *testing.T, args ...any) {
+	t.Helper()
+	message := strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+	keepMessage(t, message)
+	t.Fatal(message)
+}
+
+// skipf skips the test like t.Skipf and keeps the message as the reason
+func skipf(t *testing.T, format string, args ...any) {
+	t.Helper()
+	message := fmt.Sprintf(format, args...)
+	keepEvidence(t, func(d *subtestDetails) {
+		d.messages = append(d.messages, message)
+		d.skipReason = message
+	})
+	t.Skip(message)
+}
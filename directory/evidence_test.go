@@ -0,0 +1,91 @@
+//go:build !conformance
+// +build !conformance
+
+package directory
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestEvidence checks the messages, elapsed time and skip reason kept for the reported subtests.
+func TestEvidence(t *testing.T) {
+	currentResults, currentDetails := results, testEvidence.details
+	defer func() {
+		results, testEvidence.details = currentResults, currentDetails
+	}()
+	results = make(map[string]result)
+	testEvidence.details = make(map[string]*subtestDetails)
+
+	runSubtest(t, "logged", func(t *testing.T) {
+		defer report(t, "tdd-evidence-logged")
+		logf(t, "Response %d", 200)
+		time.Sleep(10 * time.Millisecond)
+	})
+	runSubtest(t, "skipped", func(t *testing.T) {
+		defer report(t, "tdd-evidence-skipped")
+		skipf(t, "No %s", "XPath")
+	})
+	addEvidence()
+
+	logged := results["tdd-evidence-logged"].details[t.Name()+"/logged"]
+	if !reflect.DeepEqual(logged.messages, []string{"Response 200"}) || logged.elapsed < 10*time.Millisecond {
+		t.Fatalf("Unexpected evidence of the logged subtest: %v %s", logged.messages, logged.elapsed)
+	}
+	skipped := results["tdd-evidence-skipped"]
+	if len(skipped.skipped) != 1 || skipped.details[t.Name()+"/skipped"].skipReason != "No XPath" {
+		t.Fatalf("Unexpected evidence of the skipped subtest: %v", skipped)
+	}
+}
+
+// TestRedactedCredentials checks that the credentials of the requests are kept out of the HTML report and the HAR file
+func TestRedactedCredentials(t *testing.T) {
+	restoreHTTPClients(t)
+	currentResults, currentExchanges, currentHeaders := results, exchanges, headers
+	defer func() {
+		results, exchanges, headers = currentResults, currentExchanges, currentHeaders
+	}()
+	results = make(map[string]result)
+	exchanges = make(map[string][]exchange)
+	headers = headerFlag{"X-Api-Key": {"key-secret"}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	auth, err := newAuthenticator(authConfig{scheme: authBearer, token: "token-secret"})
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %s", err)
+	}
+	setupHTTPClient(clientConfig{auth: auth, tls: &tls.Config{}, headers: http.Header(headers)})
+
+	runSubtest(t, "request", func(t *testing.T) {
+		defer report(t, "tdd-redacted")
+		res, err := httpGet(server.URL, t)
+		if err != nil {
+			fatalf(t, "Error getting: %s", err)
+		}
+		assertStatusCode(t, res, http.StatusOK, httpReadBody(res, t))
+	})
+
+	dir := t.TempDir()
+	writeHTMLReport(filepath.Join(dir, htmlReportFile), server.URL, catalogNone, nil, nil, results, nil, nil)
+	writeHARFile(filepath.Join(dir, harFile))
+	for _, name := range []string{htmlReportFile, harFile} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Error reading %s: %s", name, err)
+		}
+		if strings.Contains(string(b), "token-secret") || strings.Contains(string(b), "key-secret") {
+			t.Fatalf("Expected no credentials in %s", name)
+		}
+		if !strings.Contains(string(b), "[redacted]") {
+			t.Fatalf("Expected redacted headers in %s", name)
+		}
+	}
+}
@@ -4,6 +4,8 @@ go 1.16
 
 require (
 	github.com/r3labs/sse/v2 v2.3.3
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.0
 	github.com/satori/go.uuid v1.2.0
 	gopkg.in/cenkalti/backoff.v1 v1.1.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,354 @@
+package directory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HTTP Archive (HAR) 1.2 of all the traffic with the directory (http://www.softwareishard.com/blog/har-12-spec/)
+const harFile = "tdd-auto.har"
+
+// archive records the exchanges made through the shared client
+var archive httpArchive
+
+type httpArchive struct {
+	sync.Mutex
+	entries []*harEntry
+	uuids   []string // generated by the tests, to replay the same requests
+}
+
+type harLog struct {
+	Log harLogBody `json:"log"`
+}
+
+type harLogBody struct {
+	Version string      `json:"version"`
+	Creator harCreator  `json:"creator"`
+	Pages   []harPage   `json:"pages"`
+	Entries []*harEntry `json:"entries"`
+	UUIDs   []string    `json:"_uuids,omitempty"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// harPage groups the entries of a test
+type harPage struct {
+	StartedDateTime string   `json:"startedDateTime"`
+	ID              string   `json:"id"`
+	Title           string   `json:"title"`
+	PageTimings     struct{} `json:"pageTimings"`
+}
+
+type harEntry struct {
+	Pageref         string      `json:"pageref,omitempty"` // name of the test that made the request
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"` // milliseconds
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+
+	started  time.Time
+	body     []byte // of the response, as read so far
+	response *http.Response
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+	Error       string         `json:"_error,omitempty"` // the request failed without a response
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+// harTimings are in milliseconds
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// testNameKey is the context key of the name of the test that made a request
+type testNameKey struct{}
+
+// harTransport is an http.RoundTripper that records all exchanges in the archive
+type harTransport struct {
+	base http.RoundTripper
+}
+
+func (t *harTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	entry := &harEntry{
+		StartedDateTime: started.UTC().Format(time.RFC3339Nano),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(req.Header),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+		},
+		Response: harResponse{
+			Cookies:     []harNameValue{},
+			Headers:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		started: started,
+	}
+	if name, ok := req.Context().Value(testNameKey{}).(string); ok {
+		entry.Pageref = name
+	}
+	for k, values := range req.URL.Query() {
+		for _, v := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{k, v})
+		}
+	}
+	if req.GetBody != nil {
+		if r, err := req.GetBody(); err == nil {
+			b, _ := io.ReadAll(r)
+			entry.Request.BodySize = len(b)
+			if len(b) > 0 {
+				entry.Request.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: string(b)}
+			}
+		}
+	}
+
+	archive.Lock()
+	archive.entries = append(archive.entries, entry)
+	archive.Unlock()
+
+	res, err := t.base.RoundTrip(req)
+	archive.Lock()
+	defer archive.Unlock()
+	entry.Timings.Wait = milliseconds(time.Since(started))
+	entry.Time = entry.Timings.Wait
+	if err != nil {
+		entry.Response.Error = err.Error()
+		return nil, err
+	}
+
+	entry.Response.Status = res.StatusCode
+	entry.Response.StatusText = http.StatusText(res.StatusCode)
+	entry.Response.HTTPVersion = res.Proto
+	entry.Response.Headers = harHeaders(res.Header)
+	entry.Response.Content.MimeType = res.Header.Get("Content-Type")
+	entry.Response.RedirectURL = res.Header.Get("Location")
+	entry.response = res
+	// the body is recorded as it is read, since event streams do not end
+	res.Body = &harBody{ReadCloser: res.Body, entry: entry}
+	return res, nil
+}
+
+// harBody records the content of a response body as it is read
+type harBody struct {
+	io.ReadCloser
+	entry *harEntry
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	archive.Lock()
+	defer archive.Unlock()
+	b.entry.body = append(b.entry.body, p[:n]...)
+	b.entry.Response.Content.Size = len(b.entry.body)
+	b.entry.Response.BodySize = len(b.entry.body)
+	b.entry.Time = milliseconds(time.Since(b.entry.started))
+	b.entry.Timings.Receive = b.entry.Time - b.entry.Timings.Wait
+	return n, err
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for k, values := range redactHeader(header) {
+		for _, v := range values {
+			headers = append(headers, harNameValue{k, v})
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool {
+		return headers[i].Name < headers[j].Name
+	})
+	return headers
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// harEntryIndexes returns the positions of the entries of each test in the archive, keyed by test name.
+// These are the requests made by the test and those whose responses it checked.
+func harEntryIndexes() map[string][]int {
+	archive.Lock()
+	defer archive.Unlock()
+	indexes := make(map[string][]int)
+	responses := make(map[*http.Response]int)
+	for i, e := range archive.entries {
+		if e.Pageref != "" {
+			indexes[e.Pageref] = append(indexes[e.Pageref], i)
+		}
+		if e.response != nil {
+			responses[e.response] = i
+		}
+	}
+	for name, checked := range exchanges {
+		for _, c := range checked {
+			i, found := responses[c.response]
+			if found && !intInSlice(indexes[name], i) {
+				indexes[name] = append(indexes[name], i)
+			}
+		}
+		sort.Ints(indexes[name])
+	}
+	return indexes
+}
+
+func intInSlice(s []int, i int) bool {
+	for _, v := range s {
+		if v == i {
+			return true
+		}
+	}
+	return false
+}
+
+// writeHARFile writes all recorded exchanges, with a page for each test that made requests
+func writeHARFile(filename string) {
+	archive.Lock()
+	defer archive.Unlock()
+
+	log := harLogBody{
+		Version: "1.2",
+		Creator: harCreator{Name: "WoT Discovery Testing"},
+		Pages:   []harPage{},
+		Entries: archive.entries,
+		UUIDs:   archive.uuids,
+	}
+	if log.Entries == nil {
+		log.Entries = []*harEntry{}
+	}
+	pages := make(map[string]bool)
+	for _, e := range archive.entries {
+		e.Response.Content.Text = string(e.body)
+		if e.Pageref != "" && !pages[e.Pageref] {
+			pages[e.Pageref] = true
+			log.Pages = append(log.Pages, harPage{
+				StartedDateTime: e.StartedDateTime,
+				ID:              e.Pageref,
+				Title:           e.Pageref,
+			})
+		}
+	}
+
+	b, err := json.MarshalIndent(harLog{log}, "", "\t")
+	if err != nil {
+		fmt.Printf("Error encoding the HAR file: %s\n", err)
+		os.Exit(1)
+	}
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		fmt.Printf("Error writing the HAR file: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// loadHARExchanges sets the archive from a HAR file, and the exchanges of the subtests of saved results from its entries
+func loadHARExchanges(filename string, saved jsonReport) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var log harLog
+	err = json.Unmarshal(b, &log)
+	if err != nil {
+		return fmt.Errorf("error decoding HAR file: %s", err)
+	}
+
+	archive.Lock()
+	defer archive.Unlock()
+	for _, e := range log.Log.Entries {
+		e.body = []byte(e.Response.Content.Text)
+		// stands for the recorded response, to find the entries of the exchanges
+		e.response = &http.Response{}
+	}
+	archive.entries, archive.uuids = log.Log.Entries, log.Log.UUIDs
+
+	for _, a := range saved.Assertions {
+		for _, s := range a.Subtests {
+			if _, found := exchanges[s.Name]; found {
+				continue
+			}
+			for _, i := range s.HAREntries {
+				if i < len(archive.entries) {
+					exchanges[s.Name] = append(exchanges[s.Name], harExchange(archive.entries[i]))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// harExchange returns the exchange of an entry
+func harExchange(e *harEntry) exchange {
+	x := exchange{
+		method:         e.Request.Method,
+		url:            e.Request.URL,
+		requestHeader:  make(http.Header),
+		status:         fmt.Sprintf("%d %s", e.Response.Status, e.Response.StatusText),
+		responseHeader: make(http.Header),
+		responseBody:   e.body,
+		response:       e.response,
+	}
+	for _, h := range e.Request.Headers {
+		x.requestHeader.Add(h.Name, h.Value)
+	}
+	if e.Request.PostData != nil {
+		x.requestBody = []byte(e.Request.PostData.Text)
+	}
+	for _, h := range e.Response.Headers {
+		x.responseHeader.Add(h.Name, h.Value)
+	}
+	return x
+}
@@ -0,0 +1,160 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"gopkg.in/cenkalti/backoff.v1"
+)
+
+// infrastructureErrors are the first errors of requests that got no response, keyed by the name of the test that made them.
+// A failure of that test is reported with the error status instead of fail,
+// since it is likely caused by the infrastructure rather than the directory's behavior.
+var infrastructureErrors = struct {
+	sync.Mutex
+	errors map[string]string
+}{errors: make(map[string]string)}
+
+// topLevelTest returns the name of the top-level test of a subtest
+func topLevelTest(name string) string {
+	return strings.SplitN(name, "/", 2)[0]
+}
+
+// recordInfrastructureError keeps an error of a request that got no response
+func recordInfrastructureError(t *testing.T, err error) {
+	infrastructureErrors.Lock()
+	defer infrastructureErrors.Unlock()
+	if _, found := infrastructureErrors.errors[t.Name()]; !found {
+		infrastructureErrors.errors[t.Name()] = err.Error()
+	}
+}
+
+// infrastructureError returns the infrastructure error of a request made by a subtest, if any
+func infrastructureError(name string) (string, bool) {
+	infrastructureErrors.Lock()
+	defer infrastructureErrors.Unlock()
+	err, found := infrastructureErrors.errors[name]
+	return err, found
+}
+
+// testDeadlines limits the time for the requests of each top-level test
+var testDeadlines = struct {
+	sync.Mutex
+	timeout  time.Duration // zero for no deadline
+	contexts map[string]context.Context
+	cancels  map[string]context.CancelFunc
+	tracked  map[string]bool // top-level tests that release their context when they end
+}{
+	contexts: make(map[string]context.Context),
+	cancels:  make(map[string]context.CancelFunc),
+	tracked:  make(map[string]bool),
+}
+
+// trackTopLevelTest cancels the context of a top-level test when it ends, after its subtests.
+// It does nothing for subtests.
+func trackTopLevelTest(t *testing.T) {
+	name := t.Name()
+	if name != topLevelTest(name) {
+		return
+	}
+	testDeadlines.Lock()
+	defer testDeadlines.Unlock()
+	if testDeadlines.tracked[name] {
+		return
+	}
+	testDeadlines.tracked[name] = true
+	t.Cleanup(func() {
+		testDeadlines.Lock()
+		defer testDeadlines.Unlock()
+		if cancel, found := testDeadlines.cancels[name]; found {
+			cancel()
+		}
+		delete(testDeadlines.cancels, name)
+		delete(testDeadlines.contexts, name)
+		delete(testDeadlines.tracked, name)
+	})
+}
+
+// testContext returns a context that tags the requests made with it by the name of the test.
+// The context expires when the top-level test has run for longer than the test timeout since its first request,
+// and is canceled when the top-level test ends.
+func testContext(t *testing.T) context.Context {
+	trackTopLevelTest(t)
+	testDeadlines.Lock()
+	defer testDeadlines.Unlock()
+
+	name := topLevelTest(t.Name())
+	ctx, found := testDeadlines.contexts[name]
+	if !found {
+		ctx = context.Background()
+		if testDeadlines.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, testDeadlines.timeout)
+			testDeadlines.cancels[name] = cancel
+		}
+		testDeadlines.contexts[name] = ctx
+	}
+	return context.WithValue(ctx, testNameKey{}, t.Name())
+}
+
+// retryTransport is an http.RoundTripper that sends requests again after transient connection errors
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var res *http.Response
+	attempt := 0
+	operation := func() error {
+		r := req
+		if attempt > 0 {
+			if req.Body != nil && req.GetBody == nil {
+				return backoff.Permanent(errors.New("request body can not be sent again"))
+			}
+			r = req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return backoff.Permanent(err)
+				}
+				r.Body = body
+			}
+		}
+		attempt++
+
+		var err error
+		res, err = t.base.RoundTrip(r)
+		if err != nil && (req.Context().Err() != nil || !isTransientError(err)) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = 500 * time.Millisecond
+	err := backoff.Retry(operation, backoff.WithContext(backoff.WithMaxTries(b, uint64(t.retries)), req.Context()))
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// isTransientError tells whether a request failed on a connection error that may not happen again
+func isTransientError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		(errors.As(err, &netErr) && netErr.Timeout())
+}
@@ -0,0 +1,45 @@
+//go:build !conformance
+// +build !conformance
+
+package directory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestInfrastructureError checks that infrastructure errors are kept for the subtests that made the requests,
+// and that the deadline of a top-level test is canceled when it ends.
+func TestInfrastructureError(t *testing.T) {
+	currentTimeout := testDeadlines.timeout
+	defer func() {
+		testDeadlines.timeout = currentTimeout
+	}()
+	testDeadlines.timeout = time.Minute
+
+	// registered first, so that it runs after the cleanup of the deadline
+	var ctx context.Context
+	t.Cleanup(func() {
+		if ctx.Err() != context.Canceled {
+			t.Errorf("Expected the context of the test to be canceled when it ends, got %v", ctx.Err())
+		}
+	})
+
+	runSubtest(t, "request", func(t *testing.T) {
+		ctx = testContext(t)
+		recordInfrastructureError(t, errors.New("connection refused"))
+	})
+	runSubtest(t, "other", func(t *testing.T) {})
+
+	if err, found := infrastructureError(t.Name() + "/request"); !found || err != "connection refused" {
+		t.Fatalf("Expected the infrastructure error of the subtest that made the request, got %q", err)
+	}
+	if _, found := infrastructureError(t.Name() + "/other"); found {
+		t.Fatalf("Unexpected infrastructure error for a subtest that made no request")
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("Unexpected end of the context before the end of the test: %s", ctx.Err())
+	}
+}
@@ -0,0 +1,514 @@
+package directory
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wot-discovery-testing/directory/reference"
+)
+
+const (
+	mutationReportFile = "tdd-mutations.csv"
+	mutationRunsDir    = "mutations" // working directories of the runs with mutations
+	// mutationHeader marks the mutated responses, to find the tests that received them
+	mutationHeader = "X-Mutation"
+	// baselineMutation is the run without faults, to compare the runs with mutations with
+	baselineMutation = "none"
+)
+
+var mutationReportHeader = []string{"Mutation", "Status", "Mutated responses", "Comment"}
+
+// verdicts of a mutation
+const (
+	mutationKilled     = "killed"      // a test that received a mutated response failed
+	mutationSurvived   = "survived"    // all tests that received a mutated response passed
+	mutationNotApplied = "not-applied" // no response was mutated
+	mutationError      = "error"       // the tests could not be run
+)
+
+// mutation is a fault injected into the responses of a conformant directory, which the tests should catch
+type mutation struct {
+	name        string
+	description string
+	// mutate changes the response and returns false if it does not apply to it
+	mutate func(res *http.Response) bool
+}
+
+var mutations = []mutation{
+	{"success-status", "Change the status of successful responses, e.g. 201 Created to 200 OK", mutateSuccessStatus},
+	{"error-status", "Respond to failed requests with 200 OK", mutateErrorStatus},
+	{"missing-location", "Remove the Location header", mutateLocation},
+	{"content-type", "Change the Content-Type header to application/octet-stream", mutateContentType},
+	{"problem-status", "Set a status in the problem details of errors that differs from the response status", mutateProblemStatus},
+	{"problem-syntax", "Truncate the problem details of errors to invalid JSON", mutateProblemSyntax},
+	{"dropped-events", "Drop all events from event streams", mutateEventStream},
+}
+
+func mutateSuccessStatus(res *http.Response) bool {
+	switch res.StatusCode {
+	case http.StatusOK:
+		setStatus(res, http.StatusCreated)
+	case http.StatusCreated, http.StatusNoContent:
+		setStatus(res, http.StatusOK)
+	default:
+		return false
+	}
+	return true
+}
+
+func mutateErrorStatus(res *http.Response) bool {
+	if res.StatusCode < 400 {
+		return false
+	}
+	setStatus(res, http.StatusOK)
+	return true
+}
+
+func mutateLocation(res *http.Response) bool {
+	if res.Header.Get("Location") == "" {
+		return false
+	}
+	res.Header.Del("Location")
+	return true
+}
+
+func mutateContentType(res *http.Response) bool {
+	contentType := res.Header.Get("Content-Type")
+	if contentType == "" || strings.HasPrefix(contentType, "text/event-stream") {
+		return false
+	}
+	res.Header.Set("Content-Type", "application/octet-stream")
+	return true
+}
+
+func mutateProblemStatus(res *http.Response) bool {
+	if res.StatusCode < 400 || res.Request.Method == http.MethodHead {
+		return false
+	}
+	b, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	var problem mapAny
+	if err != nil || json.Unmarshal(b, &problem) != nil {
+		setBody(res, b)
+		return false
+	}
+	problem["status"] = res.StatusCode + 1
+	b, _ = json.Marshal(problem)
+	setBody(res, b)
+	return true
+}
+
+func mutateProblemSyntax(res *http.Response) bool {
+	if res.StatusCode < 400 || res.Request.Method == http.MethodHead {
+		return false
+	}
+	b, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil || len(b) < 2 {
+		setBody(res, b)
+		return false
+	}
+	setBody(res, b[:len(b)/2])
+	return true
+}
+
+func mutateEventStream(res *http.Response) bool {
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream") {
+		return false
+	}
+	r, w := io.Pipe()
+	body := res.Body
+	go func() {
+		// forward the stream without the events that have data, keeping comments and retry fields
+		var event []string
+		hasData := false
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line != "" {
+				event = append(event, line)
+				hasData = hasData || strings.HasPrefix(line, "data")
+				continue
+			}
+			if !hasData && len(event) > 0 {
+				if _, err := io.WriteString(w, strings.Join(event, "\n")+"\n\n"); err != nil {
+					break
+				}
+			}
+			event, hasData = nil, false
+		}
+		w.CloseWithError(scanner.Err())
+	}()
+	res.Body = &pipeBody{PipeReader: r, source: body}
+	return true
+}
+
+// pipeBody is a response body fed by a goroutine that reads the original body
+type pipeBody struct {
+	*io.PipeReader
+	source io.Closer
+}
+
+func (b *pipeBody) Close() error {
+	b.PipeReader.Close()
+	return b.source.Close()
+}
+
+func setStatus(res *http.Response, status int) {
+	res.StatusCode = status
+	res.Status = fmt.Sprintf("%d %s", status, http.StatusText(status))
+}
+
+func setBody(res *http.Response, b []byte) {
+	res.Body = io.NopCloser(bytes.NewReader(b))
+	res.ContentLength = int64(len(b))
+	res.Header.Set("Content-Length", strconv.Itoa(len(b)))
+}
+
+// mutationProxy forwards the requests to the directory and injects a mutation into its responses.
+// The requests keep the Host of the proxy, so that a directory describing its endpoints relative to the Host
+// describes them behind the proxy.
+type mutationProxy struct {
+	*httputil.ReverseProxy
+	sync.Mutex
+	mutated int // number of mutated responses
+}
+
+func newMutationProxy(target *url.URL, m mutation, tlsConfig *tls.Config) *mutationProxy {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	p := &mutationProxy{}
+	p.ReverseProxy = &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = target.Scheme
+			req.URL.Host = target.Host
+		},
+		Transport:     transport,
+		FlushInterval: -1, // forward events as they arrive
+		ModifyResponse: func(res *http.Response) error {
+			if !m.mutate(res) {
+				return nil
+			}
+			res.Header.Set(mutationHeader, m.name)
+			p.Lock()
+			p.mutated++
+			p.Unlock()
+			return nil
+		},
+	}
+	return p
+}
+
+// mutationRun is the outcome of the tests in a run with a mutation
+type mutationRun struct {
+	mutated   int
+	statuses  map[string]string // passed, failed or skipped, keyed by test name
+	receivers []string          // top-level tests that received mutated responses, sorted
+	err       error
+}
+
+// runMutationTesting runs the tests once without faults and once with each of the given mutations,
+// and writes which mutations the tests did not catch. It returns the number of mutations that survived.
+// With the reference directory, each run tests a new one.
+func runMutationTesting(names []string, serverURL string, inMemory bool, tlsConfig *tls.Config) (survived int) {
+	selected, err := selectMutations(names)
+	if err != nil {
+		fmt.Printf("Error selecting mutations: %s\n", err)
+		os.Exit(1)
+	}
+	err = os.RemoveAll(reportPath(mutationRunsDir))
+	if err != nil {
+		fmt.Printf("Error removing previous runs: %s\n", err)
+		os.Exit(1)
+	}
+
+	run := func(m mutation) mutationRun {
+		target := serverURL
+		if inMemory {
+			server := httptest.NewServer(reference.NewDirectory())
+			defer server.Close()
+			target = server.URL
+		}
+		fmt.Printf("Running the tests with mutation %s\n", m.name)
+		return runWithMutation(m, target, tlsConfig)
+	}
+
+	baseline := run(mutation{name: baselineMutation, mutate: func(*http.Response) bool { return false }})
+	if baseline.err != nil {
+		fmt.Printf("Error running the tests without mutations: %s\n", baseline.err)
+		os.Exit(1)
+	}
+
+	var records [][]string
+	for _, m := range selected {
+		r := run(m)
+		status, comment := mutationVerdict(baseline, r)
+		if status == mutationSurvived {
+			survived++
+		}
+		records = append(records, []string{m.name, status, strconv.Itoa(r.mutated), comment})
+		fmt.Printf("%-12s %s (%d mutated responses) %s\n", status, m.name, r.mutated, comment)
+	}
+	fmt.Printf("%d of %d mutations survived\n", survived, len(selected))
+	writeCSVFile(reportPath(mutationReportFile), mutationReportHeader, records)
+	return survived
+}
+
+func selectMutations(names []string) ([]mutation, error) {
+	if len(names) == 1 && names[0] == "all" {
+		return mutations, nil
+	}
+	var selected []mutation
+	for _, name := range names {
+		found := false
+		for _, m := range mutations {
+			if m.name == name {
+				selected = append(selected, m)
+				found = true
+			}
+		}
+		if !found {
+			var available []string
+			for _, m := range mutations {
+				available = append(available, m.name)
+			}
+			return nil, fmt.Errorf("unknown mutation %s, expected all or some of: %s", name, strings.Join(available, ", "))
+		}
+	}
+	return selected, nil
+}
+
+// mutationVerdict compares a run with a mutation with the baseline.
+// The comment lists the tests that received mutated responses: the subtests that failed only with the mutation caught it.
+func mutationVerdict(baseline, r mutationRun) (status, comment string) {
+	if r.err != nil {
+		return mutationError, r.err.Error()
+	}
+	if r.mutated == 0 {
+		return mutationNotApplied, ""
+	}
+
+	var caught, uncaught []string
+	for _, test := range r.receivers {
+		failed := newlyFailed(baseline, r, test)
+		if len(failed) > 0 {
+			caught = append(caught, failed...)
+		} else if r.statuses[test] == "passed" {
+			uncaught = append(uncaught, test)
+		}
+	}
+	var details []string
+	if len(caught) > 0 {
+		details = append(details, fmt.Sprint("caught:", strings.Join(caught, " caught:")))
+	}
+	if len(uncaught) > 0 {
+		details = append(details, fmt.Sprint("uncaught:", strings.Join(uncaught, " uncaught:")))
+	}
+	if len(caught) > 0 {
+		return mutationKilled, strings.Join(details, " ")
+	}
+	return mutationSurvived, strings.Join(details, " ")
+}
+
+// newlyFailed returns the innermost subtests of a top-level test that failed with the mutation but not in the baseline, sorted
+func newlyFailed(baseline, r mutationRun, test string) []string {
+	var failed []string
+	for name, status := range r.statuses {
+		if topLevelTest(name) == test && status == "failed" && baseline.statuses[name] != "failed" {
+			failed = append(failed, name)
+		}
+	}
+	sort.Strings(failed)
+	var innermost []string
+	for _, name := range failed {
+		parent := false
+		for _, other := range failed {
+			if strings.HasPrefix(other, name+"/") {
+				parent = true
+			}
+		}
+		if !parent {
+			innermost = append(innermost, name)
+		}
+	}
+	return innermost
+}
+
+// runWithMutation runs the tests in a new process against a proxy of the directory that injects the mutation.
+// The process has its own working directory, where it writes its reports.
+func runWithMutation(m mutation, target string, tlsConfig *tls.Config) mutationRun {
+	u, err := url.Parse(target)
+	if err != nil {
+		return mutationRun{err: err}
+	}
+	proxy := newMutationProxy(u, m, tlsConfig)
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	dir := filepath.Join(reportPath(mutationRunsDir), m.name)
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return mutationRun{err: err}
+	}
+	// the statuses of the tests are read from the verbose output
+	args := append(mutationArgs(invocation.args), "-test.v=true", "-server="+server.URL+u.RequestURI(), "-reportFormats="+reportFormatCSV)
+	cmd := exec.Command(invocation.command[0], append(invocation.command[1:], args...)...)
+	cmd.Dir = dir
+	cmd.Env = runEnv(os.Environ(), mutationExcludedFlags)
+	output, err := cmd.CombinedOutput()
+	os.WriteFile(filepath.Join(dir, "output.txt"), output, 0644)
+	if err != nil {
+		return mutationRun{err: fmt.Errorf("tests exited with %s, see %s", err, filepath.Join(dir, "output.txt"))}
+	}
+
+	r := mutationRun{mutated: proxy.mutated, statuses: make(map[string]string)}
+	for _, line := range strings.Split(string(output), "\n") {
+		if match := testResultRegexp.FindStringSubmatch(strings.TrimPrefix(line, "\x16")); match != nil {
+			r.statuses[match[2]] = testResultStatus[match[1]]
+		}
+	}
+	r.receivers, err = mutatedResponseReceivers(filepath.Join(dir, defaultReportDir, harFile))
+	if err != nil {
+		r.err = err
+	}
+	return r
+}
+
+var (
+	// --- FAIL: TestName/sub_test (0.00s)
+	testResultRegexp = regexp.MustCompile(`^ *--- (PASS|FAIL|SKIP): (\S+) \(([0-9.]+s)\)`)
+	testResultStatus = map[string]string{"PASS": "passed", "FAIL": "failed", "SKIP": "skipped"}
+)
+
+// mutatedResponseReceivers returns the top-level tests that received mutated responses, from the HAR file of a run.
+// The subtest that sent a request is not always the one that checks the response, e.g. its content type,
+// so each mutated response is attributed to the top-level test of the subtest that sent it.
+func mutatedResponseReceivers(filename string) ([]string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var log harLog
+	err = json.Unmarshal(b, &log)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding HAR file: %s", err)
+	}
+	var receivers []string
+	for _, e := range log.Log.Entries {
+		for _, h := range e.Response.Headers {
+			if h.Name == mutationHeader && e.Pageref != "" && !inSlice(receivers, topLevelTest(e.Pageref)) {
+				receivers = append(receivers, topLevelTest(e.Pageref))
+			}
+		}
+	}
+	sort.Strings(receivers)
+	return receivers, nil
+}
+
+// mutationExcludedFlags are not passed on to the runs with mutations, which write their own reports
+var mutationExcludedFlags = []string{"config", "server", "mutations", "reportFormats", "reportDir", "waivers", "manualResults", "diffFrom", "diffTo", "replay"}
+
+// mutationFileFlags are paths, made absolute since the runs with mutations have their own working directories
+var mutationFileFlags = []string{"tlsCA", "tlsCert", "tlsKey"}
+
+// mutationArgs returns the arguments of a run with a mutation: those of this process,
+// without the ones of mutation testing, reports and test output, and with absolute paths of files
+// since the run has its own working directory.
+func mutationArgs(args []string) []string {
+	return runArgs(args, mutationExcludedFlags)
+}
+
+// runArgs returns the arguments of this process for a run in a new process, without the excluded flags
+// and those of test output, and with absolute paths of files.
+func runArgs(args []string, excluded []string) []string {
+	keptTestFlags := []string{"test.run", "test.skip", "test.timeout", "test.short"}
+
+	var kept []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			kept = append(kept, arg)
+			continue
+		}
+		nameValue := strings.SplitN(strings.TrimLeft(arg, "-"), "=", 2)
+		name := nameValue[0]
+		f := flag.Lookup(name)
+		if len(nameValue) == 1 && f != nil && !isBoolFlag(f) && i+1 < len(args) {
+			// value in the next argument
+			i++
+			nameValue = append(nameValue, args[i])
+		}
+		if inSlice(excluded, name) || strings.HasPrefix(name, "test.") && !inSlice(keptTestFlags, name) {
+			continue
+		}
+		if len(nameValue) == 2 && inSlice(mutationFileFlags, name) && nameValue[1] != "" {
+			if abs, err := filepath.Abs(nameValue[1]); err == nil {
+				nameValue[1] = abs
+			}
+		}
+		kept = append(kept, "-"+strings.Join(nameValue, "="))
+	}
+	return kept
+}
+
+// runEnv returns the environment of a run in a new process: the configuration set by the config file
+// and environment variables is passed as environment variables, without the excluded flags
+func runEnv(environ []string, excluded []string) []string {
+	var env []string
+	for _, v := range environ {
+		if !strings.HasPrefix(v, configEnvPrefix) {
+			env = append(env, v)
+		}
+	}
+	for name, source := range configSources {
+		if source != configSourceFile && source != configSourceEnv || inSlice(excluded, name) {
+			continue
+		}
+		value := suiteFlags.Lookup(name).Value.String()
+		if name == "header" {
+			value = strings.Join(headerLines(), "\n")
+		}
+		if inSlice(mutationFileFlags, name) && value != "" {
+			if abs, err := filepath.Abs(value); err == nil {
+				value = abs
+			}
+		}
+		env = append(env, envVariable(name)+"="+value)
+	}
+	return env
+}
+
+// headerLines returns the extra headers in the form "Name: value"
+func headerLines() []string {
+	var lines []string
+	for name, values := range headers {
+		for _, v := range values {
+			lines = append(lines, name+": "+v)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,137 @@
+//go:build !conformance
+// +build !conformance
+
+package directory
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestMutationProxy checks the injection of faults into the responses of a stand-in directory.
+func TestMutationProxy(t *testing.T) {
+	directory := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/things":
+			w.Header().Set("Location", "urn:uuid:1")
+			w.WriteHeader(http.StatusCreated)
+		case "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte(": comment\n\nevent: thing_created\ndata: {\"id\":\"urn:uuid:1\"}\n\n"))
+		default:
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status":404,"title":"Not Found"}`))
+		}
+	}))
+	defer directory.Close()
+	target, _ := url.Parse(directory.URL)
+
+	get := func(t *testing.T, name, path string) (*http.Response, string) {
+		t.Helper()
+		var m mutation
+		for _, m = range mutations {
+			if m.name == name {
+				break
+			}
+		}
+		proxy := httptest.NewServer(newMutationProxy(target, m, nil))
+		defer proxy.Close()
+		res, err := http.Get(proxy.URL + path)
+		if err != nil {
+			t.Fatalf("Error getting: %s", err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res, string(body)
+	}
+
+	t.Run("status", func(t *testing.T) {
+		res, _ := get(t, "success-status", "/things")
+		if res.StatusCode != http.StatusOK || res.Header.Get(mutationHeader) != "success-status" {
+			t.Fatalf("Expected marked 200 instead of 201, got %d %v", res.StatusCode, res.Header)
+		}
+		res, _ = get(t, "error-status", "/things/x")
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200 instead of 404, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("not applied", func(t *testing.T) {
+		res, _ := get(t, "error-status", "/things")
+		if res.StatusCode != http.StatusCreated || res.Header.Get(mutationHeader) != "" {
+			t.Fatalf("Expected unmarked 201, got %d %v", res.StatusCode, res.Header)
+		}
+	})
+
+	t.Run("headers", func(t *testing.T) {
+		res, _ := get(t, "missing-location", "/things")
+		if res.Header.Get("Location") != "" {
+			t.Fatalf("Expected no Location, got %s", res.Header.Get("Location"))
+		}
+		res, _ = get(t, "content-type", "/things/x")
+		if res.Header.Get("Content-Type") != "application/octet-stream" {
+			t.Fatalf("Expected application/octet-stream, got %s", res.Header.Get("Content-Type"))
+		}
+	})
+
+	t.Run("problem details", func(t *testing.T) {
+		_, body := get(t, "problem-status", "/things/x")
+		if body != `{"status":405,"title":"Not Found"}` {
+			t.Fatalf("Expected a different status, got %s", body)
+		}
+		_, body = get(t, "problem-syntax", "/things/x")
+		if body != `{"status":404,"ti` {
+			t.Fatalf("Expected truncated problem details, got %s", body)
+		}
+	})
+
+	t.Run("dropped events", func(t *testing.T) {
+		_, body := get(t, "dropped-events", "/events")
+		if body != ": comment\n\n" {
+			t.Fatalf("Expected the stream without events, got %q", body)
+		}
+	})
+}
+
+// TestMutationArgs checks the arguments of the runs with mutations.
+func TestMutationArgs(t *testing.T) {
+	args := mutationArgs([]string{"-test.v=true", "-test.run", "TestListThings", "--server", "http://localhost:8081",
+		"-mutations=all", "-test.short", "-capabilities", "jsonpath", "--header=X-Tenant: a", "-tlsCA", "/etc/ca.pem"})
+	expected := []string{"-test.run=TestListThings", "-test.short", "-capabilities=jsonpath", "-header=X-Tenant: a", "-tlsCA=/etc/ca.pem"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("Expected %v, got %v", expected, args)
+	}
+}
+
+// TestMutationVerdict runs the tests of retrieving things with a wrong content type from the reference directory.
+// The subtest that checks the content type is not the one that sent the request, and should catch the mutation.
+func TestMutationVerdict(t *testing.T) {
+	dir, command := reportDir, invocation
+	defer func() { reportDir, invocation = dir, command }()
+	reportDir = t.TempDir()
+	invocation.command, invocation.args = os.Args[:1], []string{"-test.run=^TestRetrieveThing$"}
+
+	if survived := runMutationTesting([]string{"content-type"}, "", true, nil); survived != 0 {
+		t.Fatalf("Expected the mutation to be caught, %d survived", survived)
+	}
+	file, err := os.Open(reportPath(mutationReportFile))
+	if err != nil {
+		t.Fatalf("Error opening the report: %s", err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Error reading the report: %s", err)
+	}
+	if len(records) != 2 || records[1][1] != mutationKilled || !strings.Contains(records[1][3], "caught:TestRetrieveThing/content_type") {
+		t.Fatalf("Expected content-type to be caught by TestRetrieveThing/content_type, got %v", records)
+	}
+}
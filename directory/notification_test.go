@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"github.com/r3labs/sse/v2"
-	uuid "github.com/satori/go.uuid"
 )
 
 // tdd-notification
@@ -17,39 +16,36 @@ import (
 const (
 	timeoutDuration = 5 * time.Second
 	waitDuration    = time.Second
-	// TD event types
-	EventTypeCreate = "thing_created"
-	EventTypeUpdate = "thing_updated"
-	EventTypeDelete = "thing_deleted"
 )
 
 func TestCreateEvent(t *testing.T) {
+	requireCapability(t, capabilityNotifications)
 
-	t.Run("create event subscriber", func(t *testing.T) {
+	runSubtest(t, "create event subscriber", func(t *testing.T) {
 
 		// subscribe to create events
 		eventCh := make(chan *sse.Event)
 		errCh := make(chan error)
-		client := subscribeEvent(t, serverURL+"/events/"+EventTypeCreate, eventCh, errCh)
+		client := subscribeEvent(t, eventsURL(serverURL, EventTypeCreate, false), eventCh, errCh)
 		defer unsubscribeEvent(t, client, eventCh)
 
 		time.Sleep(waitDuration)
 
 		// add a new TD
-		id := "urn:uuid:" + uuid.NewV4().String()
+		id := "urn:uuid:" + newUUID()
 		td := mockedTD(id)
 		createThing(id, td, serverURL, t)
 
 		select {
 		case res := <-eventCh:
-			t.Run("get event ID", func(t *testing.T) {
+			runSubtest(t, "get event ID", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse", "tdd-notification-event-id")
 				if string(res.ID) == "" {
-					t.Fatal("missing event ID")
+					fatal(t, "missing event ID")
 				}
 			})
 
-			t.Run("get event type", func(t *testing.T) {
+			runSubtest(t, "get event type", func(t *testing.T) {
 				defer report(t,
 					"tdd-notification",
 					"tdd-notification-sse",
@@ -57,90 +53,92 @@ func TestCreateEvent(t *testing.T) {
 					"tdd-notification-filter-type",
 				)
 				if string(res.Event) != EventTypeCreate {
-					t.Fatalf("Unexpected event type: %s, expected: %s", string(res.Event), EventTypeCreate)
+					fatalf(t, "Unexpected event type: %s, expected: %s", string(res.Event), EventTypeCreate)
 				}
 			})
 
 			var data mapAny
-			t.Run("check event data", func(t *testing.T) {
+			runSubtest(t, "check event data", func(t *testing.T) {
 				defer report(t, "tdd-notification-data")
 				err := json.Unmarshal(res.Data, &data)
 				if err != nil {
-					t.Fatal("unable to unmarshal the event data to TDD")
+					fatal(t, "unable to unmarshal the event data to TDD")
 				}
 			})
 
-			t.Run("check event data td id", func(t *testing.T) {
+			runSubtest(t, "check event data td id", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-td-id")
 				if id != data["id"] {
-					t.Fatalf("td id did not match: expected %s, got %s", id, data["id"])
+					fatalf(t, "td id did not match: expected %s, got %s", id, data["id"])
 				}
 
 			})
 		case err := <-errCh:
-			t.Run("event subscription errors", func(t *testing.T) {
+			runSubtest(t, "event subscription errors", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse")
-				t.Fatalf("unexpected error while subscribing to notification: %s", err)
+				fatalf(t, "unexpected error while subscribing to notification: %s", err)
 			})
 		case <-time.After(timeoutDuration):
-			t.Run("event subscription timeout", func(t *testing.T) {
+			runSubtest(t, "event subscription timeout", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse")
-				t.Fatal("timed out waiting for subscription")
+				fatal(t, "timed out waiting for subscription")
 			})
 		}
 	})
 
-	t.Run("create event with diff subscriber", func(t *testing.T) {
+	runSubtest(t, "create event with diff subscriber", func(t *testing.T) {
+		requireDiff(t, EventTypeCreate)
+
 		// subscribe to create events
 		eventCh := make(chan *sse.Event)
 		errCh := make(chan error)
-		client := subscribeEvent(t, serverURL+"/events/"+EventTypeCreate+"?diff=true", eventCh, errCh)
+		client := subscribeEvent(t, eventsURL(serverURL, EventTypeCreate, true), eventCh, errCh)
 		defer unsubscribeEvent(t, client, eventCh)
 
 		time.Sleep(waitDuration)
 
 		// add a new TD
-		id := "urn:uuid:" + uuid.NewV4().String()
+		id := "urn:uuid:" + newUUID()
 		td := mockedTD(id)
 		createThing(id, td, serverURL, t)
 
 		select {
 		case res := <-eventCh:
-			t.Run("get event ID", func(t *testing.T) {
+			runSubtest(t, "get event ID", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse", "tdd-notification-event-id")
 				if string(res.ID) == "" {
-					t.Fatal("missing event ID")
+					fatal(t, "missing event ID")
 				}
 			})
 
-			t.Run("get event type", func(t *testing.T) {
+			runSubtest(t, "get event type", func(t *testing.T) {
 				defer report(t,
 					"tdd-notification-sse",
 					"tdd-notification-event-types",
 					"tdd-notification-filter-type",
 				)
 				if string(res.Event) != EventTypeCreate {
-					t.Fatalf("Unexpected event type: %s, expected: %s", string(res.Event), EventTypeCreate)
+					fatalf(t, "Unexpected event type: %s, expected: %s", string(res.Event), EventTypeCreate)
 				}
 			})
 
 			var data mapAny
-			t.Run("check event data", func(t *testing.T) {
+			runSubtest(t, "check event data", func(t *testing.T) {
 				defer report(t, "tdd-notification-data")
 				err := json.Unmarshal(res.Data, &data)
 				if err != nil {
-					t.Fatal("unable to unmarshal the event data to TDD")
+					fatal(t, "unable to unmarshal the event data to TDD")
 				}
 			})
 
-			t.Run("check event data td id", func(t *testing.T) {
+			runSubtest(t, "check event data td id", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-td-id")
 				if id != data["id"] {
-					t.Fatalf("td id did not match: expected %s, got %s", id, data["id"])
+					fatalf(t, "td id did not match: expected %s, got %s", id, data["id"])
 				}
 
 			})
-			t.Run("check event data create full", func(t *testing.T) {
+			runSubtest(t, "check event data create full", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-create-full")
 				// remove system-generated attributes
 				delete(data, "registration")
@@ -148,80 +146,80 @@ func TestCreateEvent(t *testing.T) {
 				assertEqualTitle(t, td, data)
 			})
 		case err := <-errCh:
-			t.Run("event subscription diff unsupported", func(t *testing.T) {
+			runSubtest(t, "event subscription diff unsupported", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-diff-unsupported")
 				var httpErr *httpError
 				if errors.As(err, &httpErr) {
 					if httpErr.code != http.StatusNotImplemented {
-						t.Fatalf("unexpected response code: %d", httpErr.code)
+						fatalf(t, "unexpected response code: %d", httpErr.code)
 					}
 				} else {
-					t.Fatalf("unexpected error while subscribing to notification: %s", err)
+					fatalf(t, "unexpected error while subscribing to notification: %s", err)
 				}
 			})
 		case <-time.After(timeoutDuration):
-			t.Fatal("timed out")
+			fatal(t, "timed out")
 		}
 	})
 
-	t.Run("all event subscriber", func(t *testing.T) {
+	runSubtest(t, "all event subscriber", func(t *testing.T) {
 		// subscribe to create events
 		eventCh := make(chan *sse.Event)
 		errCh := make(chan error)
-		client := subscribeEvent(t, serverURL+"/events", eventCh, errCh)
+		client := subscribeEvent(t, eventsURL(serverURL, "", false), eventCh, errCh)
 		defer unsubscribeEvent(t, client, eventCh)
 
 		time.Sleep(waitDuration)
 
 		// add a new TD
-		id := "urn:uuid:" + uuid.NewV4().String()
+		id := "urn:uuid:" + newUUID()
 		td := mockedTD(id)
 		createThing(id, td, serverURL, t)
 
 		select {
 		case res := <-eventCh:
-			t.Run("get event ID", func(t *testing.T) {
+			runSubtest(t, "get event ID", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse", "tdd-notification-event-id")
 				if string(res.ID) == "" {
-					t.Fatal("missing event ID")
+					fatal(t, "missing event ID")
 				}
 			})
 
-			t.Run("get event type", func(t *testing.T) {
+			runSubtest(t, "get event type", func(t *testing.T) {
 				defer report(t,
 					"tdd-notification-sse",
 					"tdd-notification-event-types",
 				)
 				if string(res.Event) != EventTypeCreate {
-					t.Fatalf("Unexpected event type: %s, expected: %s", string(res.Event), EventTypeCreate)
+					fatalf(t, "Unexpected event type: %s, expected: %s", string(res.Event), EventTypeCreate)
 				}
 			})
 
 			var data mapAny
-			t.Run("check event data", func(t *testing.T) {
+			runSubtest(t, "check event data", func(t *testing.T) {
 				defer report(t, "tdd-notification-data")
 				err := json.Unmarshal(res.Data, &data)
 				if err != nil {
-					t.Fatal("unable to unmarshal the event data to TDD")
+					fatal(t, "unable to unmarshal the event data to TDD")
 				}
 			})
 
-			t.Run("check event data td id", func(t *testing.T) {
+			runSubtest(t, "check event data td id", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-td-id")
 				if id != data["id"] {
-					t.Fatalf("td id did not match: expected %s, got %s", id, data["id"])
+					fatalf(t, "td id did not match: expected %s, got %s", id, data["id"])
 				}
 
 			})
 		case err := <-errCh:
-			t.Run("event subscription errors", func(t *testing.T) {
+			runSubtest(t, "event subscription errors", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse")
-				t.Fatalf("unexpected error while subscribing to notification: %s", err)
+				fatalf(t, "unexpected error while subscribing to notification: %s", err)
 			})
 		case <-time.After(timeoutDuration):
-			t.Run("event subscription timeout", func(t *testing.T) {
+			runSubtest(t, "event subscription timeout", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse")
-				t.Fatal("timed out waiting for subscription")
+				fatal(t, "timed out waiting for subscription")
 			})
 		}
 	})
@@ -230,12 +228,12 @@ func TestCreateEvent(t *testing.T) {
 	// 	// subscribe to create events
 	// 	eventCh := make(chan *sse.Event)
 	// 	errCh := make(chan error)
-	// 	client := subscribeEvent(t, serverURL+"/events/"+EventTypeUpdate, eventCh, errCh)
+	// 	client := subscribeEvent(t, eventsURL(serverURL, EventTypeUpdate, false), eventCh, errCh)
 	// 	defer unsubscribeEvent(t, client, eventCh)
 	// 	time.Sleep(waitDuration)
 
 	// 	// add a new TD
-	// 	id := "urn:uuid:" + uuid.NewV4().String()
+	// 	id := "urn:uuid:" + newUUID()
 	// 	td := mockedTD(id)
 	// 	createThing(id, td, serverURL, t)
 
@@ -254,18 +252,19 @@ func TestCreateEvent(t *testing.T) {
 }
 
 func TestUpdateEvent(t *testing.T) {
+	requireCapability(t, capabilityNotifications)
 
 	// add a new TD
-	id := "urn:uuid:" + uuid.NewV4().String()
+	id := "urn:uuid:" + newUUID()
 	td := mockedTD(id)
 	createThing(id, td, serverURL, t)
 
-	t.Run("update event subscriber", func(t *testing.T) {
+	runSubtest(t, "update event subscriber", func(t *testing.T) {
 
 		// subscribe to update events
 		eventCh := make(chan *sse.Event)
 		errCh := make(chan error)
-		client := subscribeEvent(t, serverURL+"/events/"+EventTypeUpdate, eventCh, errCh)
+		client := subscribeEvent(t, eventsURL(serverURL, EventTypeUpdate, false), eventCh, errCh)
 		defer unsubscribeEvent(t, client, eventCh)
 
 		time.Sleep(waitDuration)
@@ -276,62 +275,64 @@ func TestUpdateEvent(t *testing.T) {
 
 		select {
 		case res := <-eventCh:
-			t.Run("get event ID", func(t *testing.T) {
+			runSubtest(t, "get event ID", func(t *testing.T) {
 				defer report(t,
 					"tdd-notification",
 					"tdd-notification-sse",
 					"tdd-notification-event-id",
 				)
 				if string(res.ID) == "" {
-					t.Fatal("missing event ID")
+					fatal(t, "missing event ID")
 				}
 			})
 
-			t.Run("get event type", func(t *testing.T) {
+			runSubtest(t, "get event type", func(t *testing.T) {
 				defer report(t,
 					"tdd-notification-sse",
 					"tdd-notification-event-types",
 					"tdd-notification-filter-type",
 				)
 				if string(res.Event) != EventTypeUpdate {
-					t.Fatalf("Unexpected event type: %s, expected: %s", string(res.Event), EventTypeUpdate)
+					fatalf(t, "Unexpected event type: %s, expected: %s", string(res.Event), EventTypeUpdate)
 				}
 			})
 
 			var data mapAny
-			t.Run("check event data", func(t *testing.T) {
+			runSubtest(t, "check event data", func(t *testing.T) {
 				defer report(t, "tdd-notification-data")
 				err := json.Unmarshal(res.Data, &data)
 				if err != nil {
-					t.Fatal("unable to unmarshal the event data to TDD")
+					fatal(t, "unable to unmarshal the event data to TDD")
 				}
 			})
 
-			t.Run("check event data td id", func(t *testing.T) {
+			runSubtest(t, "check event data td id", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-td-id")
 				if id != data["id"] {
-					t.Fatalf("td id did not match: expected %s, got %s", id, data["id"])
+					fatalf(t, "td id did not match: expected %s, got %s", id, data["id"])
 				}
 
 			})
 		case err := <-errCh:
-			t.Run("event subscription errors", func(t *testing.T) {
+			runSubtest(t, "event subscription errors", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse")
-				t.Fatalf("unexpected error while subscribing to notification: %s", err)
+				fatalf(t, "unexpected error while subscribing to notification: %s", err)
 			})
 		case <-time.After(timeoutDuration):
-			t.Run("event subscription timeout", func(t *testing.T) {
+			runSubtest(t, "event subscription timeout", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse")
-				t.Fatal("timed out waiting for data")
+				fatal(t, "timed out waiting for data")
 			})
 		}
 	})
 
-	t.Run("update event with diff subscriber", func(t *testing.T) {
+	runSubtest(t, "update event with diff subscriber", func(t *testing.T) {
+		requireDiff(t, EventTypeUpdate)
+
 		// subscribe to update events
 		eventCh := make(chan *sse.Event)
 		errCh := make(chan error)
-		client := subscribeEvent(t, serverURL+"/events/"+EventTypeUpdate+"?diff=true", eventCh, errCh)
+		client := subscribeEvent(t, eventsURL(serverURL, EventTypeUpdate, true), eventCh, errCh)
 		defer unsubscribeEvent(t, client, eventCh)
 
 		time.Sleep(waitDuration)
@@ -342,77 +343,77 @@ func TestUpdateEvent(t *testing.T) {
 
 		select {
 		case res := <-eventCh:
-			t.Run("get event ID", func(t *testing.T) {
+			runSubtest(t, "get event ID", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse", "tdd-notification-event-id")
 				if string(res.ID) == "" {
-					t.Fatal("missing event ID")
+					fatal(t, "missing event ID")
 				}
 			})
 
-			t.Run("get event type", func(t *testing.T) {
+			runSubtest(t, "get event type", func(t *testing.T) {
 				defer report(t,
 					"tdd-notification-sse",
 					"tdd-notification-event-types",
 					"tdd-notification-filter-type",
 				)
 				if string(res.Event) != EventTypeUpdate {
-					t.Fatalf("Unexpected event type: %s, expected: %s", string(res.Event), EventTypeUpdate)
+					fatalf(t, "Unexpected event type: %s, expected: %s", string(res.Event), EventTypeUpdate)
 				}
 			})
 
 			var data mapAny
-			t.Run("check event data", func(t *testing.T) {
+			runSubtest(t, "check event data", func(t *testing.T) {
 				defer report(t, "tdd-notification-data")
 				err := json.Unmarshal(res.Data, &data)
 				if err != nil {
-					t.Fatal("unable to unmarshal the event data to TDD")
+					fatal(t, "unable to unmarshal the event data to TDD")
 				}
 			})
 
-			t.Run("check event data td id", func(t *testing.T) {
+			runSubtest(t, "check event data td id", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-td-id", "tdd-notification-data-update-id")
 				if id != data["id"] {
-					t.Fatalf("td id did not match: expected %s, got %s", id, data["id"])
+					fatalf(t, "td id did not match: expected %s, got %s", id, data["id"])
 				}
 
 			})
-			t.Run("check event data update diff", func(t *testing.T) {
+			runSubtest(t, "check event data update diff", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-update-diff")
 				// remove system-generated attributes
 				delete(data, "registration")
 
 				for key, _ := range data {
 					if !(key == "id" || key == "title") {
-						t.Fatalf("unexpected part in the merge patch : %s", key)
+						fatalf(t, "unexpected part in the merge patch : %s", key)
 					}
 				}
 				if td["title"] != data["title"] {
-					t.Fatalf("notification data does not reflect the changes in the title: Expected:\n%v\nRetrieved:\n%v", td["title"], data["title"])
+					fatalf(t, "notification data does not reflect the changes in the title: Expected:\n%v\nRetrieved:\n%v", td["title"], data["title"])
 				}
 
 			})
 		case err := <-errCh:
-			t.Run("event subscription diff unsupported", func(t *testing.T) {
+			runSubtest(t, "event subscription diff unsupported", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-diff-unsupported")
 				var httpErr *httpError
 				if errors.As(err, &httpErr) {
 					if httpErr.code != http.StatusNotImplemented {
-						t.Fatalf("unexpected response code: %d", httpErr.code)
+						fatalf(t, "unexpected response code: %d", httpErr.code)
 					}
 				} else {
-					t.Fatalf("unexpected error while subscribing to notification: %s", err)
+					fatalf(t, "unexpected error while subscribing to notification: %s", err)
 				}
 			})
 		case <-time.After(timeoutDuration):
-			t.Fatal("timed out waiting for data")
+			fatal(t, "timed out waiting for data")
 		}
 	})
 
-	t.Run("all event subscriber", func(t *testing.T) {
+	runSubtest(t, "all event subscriber", func(t *testing.T) {
 		// subscribe to create events
 		eventCh := make(chan *sse.Event)
 		errCh := make(chan error)
-		client := subscribeEvent(t, serverURL+"/events", eventCh, errCh)
+		client := subscribeEvent(t, eventsURL(serverURL, "", false), eventCh, errCh)
 		defer unsubscribeEvent(t, client, eventCh)
 
 		time.Sleep(waitDuration)
@@ -423,48 +424,48 @@ func TestUpdateEvent(t *testing.T) {
 
 		select {
 		case res := <-eventCh:
-			t.Run("get event ID", func(t *testing.T) {
+			runSubtest(t, "get event ID", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse", "tdd-notification-event-id")
 				if string(res.ID) == "" {
-					t.Fatal("missing event ID")
+					fatal(t, "missing event ID")
 				}
 			})
 
-			t.Run("get event type", func(t *testing.T) {
+			runSubtest(t, "get event type", func(t *testing.T) {
 				defer report(t,
 					"tdd-notification-sse",
 					"tdd-notification-event-types",
 				)
 				if string(res.Event) != EventTypeUpdate {
-					t.Fatalf("Unexpected event type: %s, expected: %s", string(res.Event), EventTypeUpdate)
+					fatalf(t, "Unexpected event type: %s, expected: %s", string(res.Event), EventTypeUpdate)
 				}
 			})
 
 			var data mapAny
-			t.Run("check event data", func(t *testing.T) {
+			runSubtest(t, "check event data", func(t *testing.T) {
 				defer report(t, "tdd-notification-data")
 				err := json.Unmarshal(res.Data, &data)
 				if err != nil {
-					t.Fatal("unable to unmarshal the event data to TDD")
+					fatal(t, "unable to unmarshal the event data to TDD")
 				}
 			})
 
-			t.Run("check event data td id", func(t *testing.T) {
+			runSubtest(t, "check event data td id", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-td-id")
 				if id != data["id"] {
-					t.Fatalf("td id did not match: expected %s, got %s", id, data["id"])
+					fatalf(t, "td id did not match: expected %s, got %s", id, data["id"])
 				}
 
 			})
 		case err := <-errCh:
-			t.Run("event subscription errors", func(t *testing.T) {
+			runSubtest(t, "event subscription errors", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse")
-				t.Fatalf("unexpected error while subscribing to notification: %s", err)
+				fatalf(t, "unexpected error while subscribing to notification: %s", err)
 			})
 		case <-time.After(timeoutDuration):
-			t.Run("event subscription timeout", func(t *testing.T) {
+			runSubtest(t, "event subscription timeout", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse")
-				t.Fatal("timed out waiting for data")
+				fatal(t, "timed out waiting for data")
 			})
 		}
 	})
@@ -473,7 +474,7 @@ func TestUpdateEvent(t *testing.T) {
 	// 	// subscribe to create events
 	// 	eventCh := make(chan *sse.Event)
 	// 	errCh := make(chan error)
-	// 	client := subscribeEvent(t, serverURL+"/events/"+EventTypeCreate, eventCh, errCh)
+	// 	client := subscribeEvent(t, eventsURL(serverURL, EventTypeCreate, false), eventCh, errCh)
 	// 	defer unsubscribeEvent(t, client, eventCh)
 
 	// 	time.Sleep(waitDuration)
@@ -497,11 +498,12 @@ func TestUpdateEvent(t *testing.T) {
 }
 
 func TestDeleteEvent(t *testing.T) {
+	requireCapability(t, capabilityNotifications)
 
-	t.Run("delete event subscriber", func(t *testing.T) {
+	runSubtest(t, "delete event subscriber", func(t *testing.T) {
 
 		// add a new TD
-		id := "urn:uuid:" + uuid.NewV4().String()
+		id := "urn:uuid:" + newUUID()
 		td := mockedTD(id)
 		createThing(id, td, serverURL, t)
 
@@ -510,7 +512,7 @@ func TestDeleteEvent(t *testing.T) {
 		// subscribe to delete events
 		eventCh := make(chan *sse.Event)
 		errCh := make(chan error)
-		client := subscribeEvent(t, serverURL+"/events/"+EventTypeDelete, eventCh, errCh)
+		client := subscribeEvent(t, eventsURL(serverURL, EventTypeDelete, false), eventCh, errCh)
 		defer unsubscribeEvent(t, client, eventCh)
 
 		time.Sleep(waitDuration)
@@ -519,60 +521,62 @@ func TestDeleteEvent(t *testing.T) {
 
 		select {
 		case res := <-eventCh:
-			t.Run("get event ID", func(t *testing.T) {
+			runSubtest(t, "get event ID", func(t *testing.T) {
 				defer report(t,
 					"tdd-notification",
 					"tdd-notification-sse",
 					"tdd-notification-event-id",
 				)
 				if string(res.ID) == "" {
-					t.Fatal("missing event ID")
+					fatal(t, "missing event ID")
 				}
 			})
 
-			t.Run("get event type", func(t *testing.T) {
+			runSubtest(t, "get event type", func(t *testing.T) {
 				defer report(t,
 					"tdd-notification-sse",
 					"tdd-notification-event-types",
 					"tdd-notification-filter-type",
 				)
 				if string(res.Event) != EventTypeDelete {
-					t.Fatalf("Unexpected event type: %s, expected: %s", string(res.Event), EventTypeDelete)
+					fatalf(t, "Unexpected event type: %s, expected: %s", string(res.Event), EventTypeDelete)
 				}
 			})
 
 			var data mapAny
-			t.Run("check event data", func(t *testing.T) {
+			runSubtest(t, "check event data", func(t *testing.T) {
 				defer report(t, "tdd-notification-data")
 				err := json.Unmarshal(res.Data, &data)
 				if err != nil {
-					t.Fatal("unable to unmarshal the event data to TDD")
+					fatal(t, "unable to unmarshal the event data to TDD")
 				}
 			})
 
-			t.Run("check event data td id", func(t *testing.T) {
+			runSubtest(t, "check event data td id", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-td-id")
 				if id != data["id"] {
-					t.Fatalf("td id did not match: expected %s, got %s", id, data["id"])
+					fatalf(t, "td id did not match: expected %s, got %s", id, data["id"])
 				}
 
 			})
 		case err := <-errCh:
-			t.Run("event subscription errors", func(t *testing.T) {
+			runSubtest(t, "event subscription errors", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse")
-				t.Fatalf("unexpected error while subscribing to notification: %s", err)
+				fatalf(t, "unexpected error while subscribing to notification: %s", err)
 			})
 		case <-time.After(timeoutDuration):
-			t.Run("event subscription timeout", func(t *testing.T) {
+			runSubtest(t, "event subscription timeout", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse")
-				t.Fatal("timed out waiting for data")
+				fatal(t, "timed out waiting for data")
 			})
 		}
 	})
 
-	t.Run("delete event with diff subscriber", func(t *testing.T) {
+	runSubtest(t, "delete event with diff subscriber", func(t *testing.T) {
+		requireDiff(t, EventTypeDelete)
+
 		// add a new TD
-		id := "urn:uuid:" + uuid.NewV4().String()
+		id := "urn:uuid:" + newUUID()
 		td := mockedTD(id)
 		createThing(id, td, serverURL, t)
 
@@ -581,7 +585,7 @@ func TestDeleteEvent(t *testing.T) {
 		// subscribe to delete events
 		eventCh := make(chan *sse.Event)
 		errCh := make(chan error)
-		client := subscribeEvent(t, serverURL+"/events/"+EventTypeDelete+"?diff=true", eventCh, errCh)
+		client := subscribeEvent(t, eventsURL(serverURL, EventTypeDelete, true), eventCh, errCh)
 		defer unsubscribeEvent(t, client, eventCh)
 
 		time.Sleep(waitDuration)
@@ -591,68 +595,68 @@ func TestDeleteEvent(t *testing.T) {
 
 		select {
 		case res := <-eventCh:
-			t.Run("get event ID", func(t *testing.T) {
+			runSubtest(t, "get event ID", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse", "tdd-notification-event-id")
 				if string(res.ID) == "" {
-					t.Fatal("missing event ID")
+					fatal(t, "missing event ID")
 				}
 			})
 
-			t.Run("get event type", func(t *testing.T) {
+			runSubtest(t, "get event type", func(t *testing.T) {
 				defer report(t,
 					"tdd-notification-sse",
 					"tdd-notification-event-types",
 					"tdd-notification-filter-type",
 				)
 				if string(res.Event) != EventTypeDelete {
-					t.Fatalf("Unexpected event type: %s, expected: %s", string(res.Event), EventTypeDelete)
+					fatalf(t, "Unexpected event type: %s, expected: %s", string(res.Event), EventTypeDelete)
 				}
 			})
 
 			var data mapAny
-			t.Run("check event data", func(t *testing.T) {
+			runSubtest(t, "check event data", func(t *testing.T) {
 				defer report(t, "tdd-notification-data")
 				err := json.Unmarshal(res.Data, &data)
 				if err != nil {
-					t.Fatal("unable to unmarshal the event data to TDD")
+					fatal(t, "unable to unmarshal the event data to TDD")
 				}
 			})
 
-			t.Run("check event data td id", func(t *testing.T) {
+			runSubtest(t, "check event data td id", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-td-id")
 				if id != data["id"] {
-					t.Fatalf("td id did not match: expected %s, got %s", id, data["id"])
+					fatalf(t, "td id did not match: expected %s, got %s", id, data["id"])
 				}
 
 			})
-			t.Run("check event data", func(t *testing.T) {
+			runSubtest(t, "check event data", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-delete-diff")
 				for key, _ := range data {
 					if key != "id" {
-						t.Fatalf("unexpected part in the delete notification : %s", key)
+						fatalf(t, "unexpected part in the delete notification : %s", key)
 					}
 				}
 			})
 		case err := <-errCh:
-			t.Run("event subscription diff unsupported", func(t *testing.T) {
+			runSubtest(t, "event subscription diff unsupported", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-diff-unsupported")
 				var httpErr *httpError
 				if errors.As(err, &httpErr) {
 					if httpErr.code != http.StatusNotImplemented {
-						t.Fatalf("unexpected response code: %d", httpErr.code)
+						fatalf(t, "unexpected response code: %d", httpErr.code)
 					}
 				} else {
-					t.Fatalf("unexpected error while subscribing to notification: %s", err)
+					fatalf(t, "unexpected error while subscribing to notification: %s", err)
 				}
 			})
 		case <-time.After(timeoutDuration):
-			t.Fatal("timed out waiting for data")
+			fatal(t, "timed out waiting for data")
 		}
 	})
 
-	t.Run("all event subscriber", func(t *testing.T) {
+	runSubtest(t, "all event subscriber", func(t *testing.T) {
 		// add a new TD
-		id := "urn:uuid:" + uuid.NewV4().String()
+		id := "urn:uuid:" + newUUID()
 		td := mockedTD(id)
 		createThing(id, td, serverURL, t)
 
@@ -661,7 +665,7 @@ func TestDeleteEvent(t *testing.T) {
 		// subscribe to delete events
 		eventCh := make(chan *sse.Event)
 		errCh := make(chan error)
-		client := subscribeEvent(t, serverURL+"/events", eventCh, errCh)
+		client := subscribeEvent(t, eventsURL(serverURL, "", false), eventCh, errCh)
 		defer unsubscribeEvent(t, client, eventCh)
 
 		time.Sleep(waitDuration)
@@ -671,55 +675,55 @@ func TestDeleteEvent(t *testing.T) {
 
 		select {
 		case res := <-eventCh:
-			t.Run("get event ID", func(t *testing.T) {
+			runSubtest(t, "get event ID", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse", "tdd-notification-event-id")
 				if string(res.ID) == "" {
-					t.Fatal("missing event ID")
+					fatal(t, "missing event ID")
 				}
 			})
 
-			t.Run("get event type", func(t *testing.T) {
+			runSubtest(t, "get event type", func(t *testing.T) {
 				defer report(t,
 					"tdd-notification-sse",
 					"tdd-notification-event-types",
 				)
 				if string(res.Event) != EventTypeDelete {
-					t.Fatalf("Unexpected event type: %s, expected: %s", string(res.Event), EventTypeDelete)
+					fatalf(t, "Unexpected event type: %s, expected: %s", string(res.Event), EventTypeDelete)
 				}
 			})
 
 			var data mapAny
-			t.Run("check event data", func(t *testing.T) {
+			runSubtest(t, "check event data", func(t *testing.T) {
 				defer report(t, "tdd-notification-data")
 				err := json.Unmarshal(res.Data, &data)
 				if err != nil {
-					t.Fatal("unable to unmarshal the event data to TDD")
+					fatal(t, "unable to unmarshal the event data to TDD")
 				}
 			})
 
-			t.Run("check event data td id", func(t *testing.T) {
+			runSubtest(t, "check event data td id", func(t *testing.T) {
 				defer report(t, "tdd-notification-data-td-id")
 				if id != data["id"] {
-					t.Fatalf("td id did not match: expected %s, got %s", id, data["id"])
+					fatalf(t, "td id did not match: expected %s, got %s", id, data["id"])
 				}
 
 			})
 		case err := <-errCh:
-			t.Run("event subscription errors", func(t *testing.T) {
+			runSubtest(t, "event subscription errors", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse")
-				t.Fatalf("unexpected error while subscribing to notification: %s", err)
+				fatalf(t, "unexpected error while subscribing to notification: %s", err)
 			})
 		case <-time.After(timeoutDuration):
-			t.Run("event subscription timeout", func(t *testing.T) {
+			runSubtest(t, "event subscription timeout", func(t *testing.T) {
 				defer report(t, "tdd-notification-sse")
-				t.Fatal("timed out waiting for data")
+				fatal(t, "timed out waiting for data")
 			})
 		}
 	})
 
 	// t.Run("create event subscriber", func(t *testing.T) {
 	// 	// add a new TD
-	// 	id := "urn:uuid:" + uuid.NewV4().String()
+	// 	id := "urn:uuid:" + newUUID()
 	// 	td := mockedTD(id)
 	// 	createThing(id, td, serverURL, t)
 
@@ -728,7 +732,7 @@ func TestDeleteEvent(t *testing.T) {
 	// 	// subscribe to delete events
 	// 	eventCh := make(chan *sse.Event)
 	// 	errCh := make(chan error)
-	// 	client := subscribeEvent(t, serverURL+"/events/"+EventTypeCreate, eventCh, errCh)
+	// 	client := subscribeEvent(t, eventsURL(serverURL, EventTypeCreate, false), eventCh, errCh)
 	// 	defer unsubscribeEvent(t, client, eventCh)
 
 	// 	time.Sleep(waitDuration)
@@ -749,3 +753,29 @@ func TestDeleteEvent(t *testing.T) {
 	// 	}
 	// })
 }
+
+// requireDiff skips the subtests of a subscription with diff if the directory does not support it,
+// after checking that it rejects the subscription if the capability was detected as absent
+func requireDiff(t *testing.T, eventType string) {
+	t.Helper()
+	s := supportedCapabilities[capabilityNotificationsDiff]
+	if s.supported {
+		return
+	}
+	runSubtest(t, "event subscription diff unsupported", func(t *testing.T) {
+		defer report(t, "tdd-notification-data-diff-unsupported")
+		// a directory that was not probed may support diffs
+		if !s.detected {
+			skipf(t, "The directory was not probed for %s: %s", capabilityNotificationsDiff, s.reason)
+		}
+		res, err := httpGet(eventsURL(serverURL, eventType, true), t)
+		if err != nil {
+			fatalf(t, "Error subscribing: %s", err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusNotImplemented {
+			fatalf(t, "unexpected response code: %d", res.StatusCode)
+		}
+	})
+	requireCapability(t, capabilityNotificationsDiff)
+}
@@ -0,0 +1,101 @@
+package reference
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// selfDescription serves the TD of the directory (https://www.w3.org/TR/wot-discovery/#exploration-directory-api),
+// with the base URL of the request
+func (d *Directory) selfDescription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	idVariable := mapAny{
+		"id": mapAny{"title": "Thing Description ID", "type": "string", "format": "iri-reference"},
+	}
+	action := func(method, href, contentType string) mapAny {
+		form := mapAny{"href": href, "htv:methodName": method}
+		if contentType != "" {
+			form["contentType"] = contentType
+		}
+		return mapAny{
+			"uriVariables": idVariable,
+			"forms":        []mapAny{form},
+		}
+	}
+	event := func(eventType string) mapAny {
+		return mapAny{
+			"uriVariables": mapAny{"diff": mapAny{"type": "boolean"}},
+			"data":         mapAny{"type": "object"},
+			"forms": []mapAny{{
+				"op":          "subscribeevent",
+				"href":        "events/" + eventType + "{?diff}",
+				"subprotocol": "sse",
+				"contentType": "text/event-stream",
+			}},
+		}
+	}
+
+	createAnonymous := action(http.MethodPost, "things", mediaTypeThingDescription)
+	delete(createAnonymous, "uriVariables")
+	createAnonymous["output"] = mapAny{"description": "The ID of the created TD in the Location header"}
+
+	td := mapAny{
+		"@context":            "https://www.w3.org/2022/wot/td/v1.1",
+		"@type":               "ThingDirectory",
+		"id":                  d.id,
+		"title":               "Reference Thing Directory",
+		"description":         "In-memory directory for checking the test suite",
+		"base":                scheme + "://" + r.Host + "/",
+		"security":            "nosec_sc",
+		"securityDefinitions": mapAny{"nosec_sc": mapAny{"scheme": "nosec"}},
+		"properties": mapAny{
+			"things": mapAny{
+				"description": "Retrieve all Thing Descriptions",
+				"uriVariables": mapAny{
+					"offset": mapAny{"type": "number", "minimum": 0},
+					"limit":  mapAny{"type": "number", "minimum": 1},
+				},
+				"readOnly": true,
+				"type":     "array",
+				"forms": []mapAny{{
+					"href":           "things{?offset,limit}",
+					"htv:methodName": http.MethodGet,
+					"response":       mapAny{"contentType": mediaTypeJSONLD},
+				}},
+			},
+		},
+		"actions": mapAny{
+			"createThing":          action(http.MethodPut, "things/{id}", mediaTypeThingDescription),
+			"createAnonymousThing": createAnonymous,
+			"retrieveThing":        action(http.MethodGet, "things/{id}", ""),
+			"updateThing":          action(http.MethodPut, "things/{id}", mediaTypeThingDescription),
+			"partiallyUpdateThing": action(http.MethodPatch, "things/{id}", mediaTypeMergePatch),
+			"deleteThing":          action(http.MethodDelete, "things/{id}", ""),
+			"searchJSONPath": mapAny{
+				"uriVariables": mapAny{"query": mapAny{"type": "string"}},
+				"forms": []mapAny{{
+					"href":           "search/jsonpath{?query}",
+					"htv:methodName": http.MethodGet,
+					"response":       mapAny{"contentType": mediaTypeJSON},
+				}},
+			},
+		},
+		"events": mapAny{
+			"thingCreated": event(eventCreate),
+			"thingUpdated": event(eventUpdate),
+			"thingDeleted": event(eventDelete),
+		},
+	}
+
+	b, _ := json.Marshal(td)
+	w.Header().Set("Content-Type", mediaTypeThingDescription)
+	w.Write(b)
+}
@@ -0,0 +1,358 @@
+// Package reference implements a minimal in-memory Thing Directory (https://www.w3.org/TR/wot-discovery/#exploration-directory-api)
+// to check the test suite itself without an external directory.
+// It supports the Things API with merge-patch, RFC 7807 errors, the Notification API with diff and JSONPath search.
+package reference
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	mediaTypeJSON             = "application/json"
+	mediaTypeJSONLD           = "application/ld+json"
+	mediaTypeThingDescription = "application/td+json"
+	mediaTypeMergePatch       = "application/merge-patch+json"
+	mediaTypeProblem          = "application/problem+json"
+
+	maxBodySize = 1 << 20
+)
+
+type mapAny = map[string]interface{}
+
+// Directory is an http.Handler serving the directory API from memory
+type Directory struct {
+	sync.RWMutex
+	id     string // of the directory TD
+	tds    map[string]mapAny
+	order  []string // IDs in the order of creation
+	events *eventBus
+}
+
+// NewDirectory returns an empty directory
+func NewDirectory() *Directory {
+	return &Directory{
+		id:     "urn:uuid:" + uuid.NewV4().String(),
+		tds:    make(map[string]mapAny),
+		events: newEventBus(),
+	}
+}
+
+func (d *Directory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	switch {
+	case path == "/.well-known/wot":
+		d.selfDescription(w, r)
+	case path == "/things" || path == "/things/":
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			d.listThings(w, r)
+		case http.MethodPost:
+			d.createAnonymousThing(w, r)
+		default:
+			methodNotAllowed(w, r, "GET, HEAD, POST")
+		}
+	case strings.HasPrefix(path, "/things/"):
+		id := strings.TrimPrefix(path, "/things/")
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			d.retrieveThing(w, id)
+		case http.MethodPut:
+			d.createOrUpdateThing(w, r, id)
+		case http.MethodPatch:
+			d.patchThing(w, r, id)
+		case http.MethodDelete:
+			d.deleteThing(w, id)
+		default:
+			methodNotAllowed(w, r, "GET, HEAD, PUT, PATCH, DELETE")
+		}
+	case path == "/search/jsonpath":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			methodNotAllowed(w, r, "GET, HEAD")
+			return
+		}
+		d.searchJSONPath(w, r)
+	case strings.HasPrefix(path, "/search/"):
+		writeProblem(w, http.StatusNotImplemented, "Search with "+strings.TrimPrefix(path, "/search/")+" is not supported", nil)
+	case path == "/events" || strings.HasPrefix(path, "/events/"):
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, "GET")
+			return
+		}
+		d.subscribe(w, r, strings.Trim(strings.TrimPrefix(path, "/events"), "/"))
+	default:
+		writeProblem(w, http.StatusNotFound, "No such endpoint: "+path, nil)
+	}
+}
+
+func (d *Directory) listThings(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := pagination(r)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	d.RLock()
+	total := len(d.order)
+	ids := d.order
+	if offset > len(ids) {
+		offset = len(ids)
+	}
+	ids = ids[offset:]
+	if limit > 0 && limit < len(ids) {
+		ids = ids[:limit]
+	}
+	tds := make([]mapAny, 0, len(ids))
+	for _, id := range ids {
+		tds = append(tds, d.tds[id])
+	}
+	b, err := json.Marshal(tds)
+	d.RUnlock()
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	if limit > 0 && offset+limit < total {
+		w.Header().Set("Link", nextLink(offset+limit, limit))
+	}
+	w.Header().Set("Content-Type", mediaTypeJSONLD)
+	w.Write(b)
+}
+
+func (d *Directory) retrieveThing(w http.ResponseWriter, id string) {
+	d.RLock()
+	td, found := d.tds[id]
+	var b []byte
+	if found {
+		b, _ = json.Marshal(td)
+	}
+	d.RUnlock()
+	if !found {
+		writeProblem(w, http.StatusNotFound, "TD with id "+id+" is not found", nil)
+		return
+	}
+	w.Header().Set("Content-Type", mediaTypeThingDescription)
+	w.Write(b)
+}
+
+func (d *Directory) createAnonymousThing(w http.ResponseWriter, r *http.Request) {
+	td, ok := readTD(w, r, mediaTypeThingDescription, mediaTypeJSON)
+	if !ok {
+		return
+	}
+	if _, found := td["id"]; found {
+		writeProblem(w, http.StatusBadRequest, "Anonymous TDs must not have an id. Use PUT to create TDs with an id", nil)
+		return
+	}
+	if errs := validateTD(td); len(errs) > 0 {
+		writeProblem(w, http.StatusBadRequest, "Invalid TD", errs)
+		return
+	}
+
+	id := "urn:uuid:" + uuid.NewV4().String()
+	td["id"] = id
+	d.Lock()
+	d.store(id, td, nil)
+	d.Unlock()
+
+	w.Header().Set("Location", id)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (d *Directory) createOrUpdateThing(w http.ResponseWriter, r *http.Request, id string) {
+	td, ok := readTD(w, r, mediaTypeThingDescription, mediaTypeJSON)
+	if !ok {
+		return
+	}
+	if tdID, found := td["id"]; found && tdID != id {
+		writeProblem(w, http.StatusBadRequest, "The id of the TD does not match the path", []validationError{
+			{Field: "(root).id", Description: "Must be equal to the id in the path: " + id},
+		})
+		return
+	}
+	td["id"] = id
+	if errs := validateTD(td); len(errs) > 0 {
+		writeProblem(w, http.StatusBadRequest, "Invalid TD", errs)
+		return
+	}
+
+	d.Lock()
+	previous, found := d.tds[id]
+	d.store(id, td, previous)
+	d.Unlock()
+
+	if found {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (d *Directory) patchThing(w http.ResponseWriter, r *http.Request, id string) {
+	patch, ok := readTD(w, r, mediaTypeMergePatch)
+	if !ok {
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	previous, found := d.tds[id]
+	if !found {
+		writeProblem(w, http.StatusNotFound, "TD with id "+id+" is not found", nil)
+		return
+	}
+	td := mergePatch(copyJSON(previous), patch).(mapAny)
+	if td["id"] != id {
+		writeProblem(w, http.StatusBadRequest, "The id of the TD must not be changed", []validationError{
+			{Field: "(root).id", Description: "Must be equal to the id in the path: " + id},
+		})
+		return
+	}
+	if errs := validateTD(td); len(errs) > 0 {
+		writeProblem(w, http.StatusBadRequest, "Invalid TD after applying the patch", errs)
+		return
+	}
+	d.store(id, td, previous)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (d *Directory) deleteThing(w http.ResponseWriter, id string) {
+	d.Lock()
+	_, found := d.tds[id]
+	if found {
+		delete(d.tds, id)
+		for i := range d.order {
+			if d.order[i] == id {
+				d.order = append(d.order[:i:i], d.order[i+1:]...)
+				break
+			}
+		}
+		d.events.publish(eventDelete, mapAny{"id": id}, nil)
+	}
+	d.Unlock()
+
+	if !found {
+		writeProblem(w, http.StatusNotFound, "TD with id "+id+" is not found", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// store adds or replaces a TD with its registration information and notifies the subscribers.
+// The previous TD is nil for new TDs. It must be called with the lock held.
+func (d *Directory) store(id string, td, previous mapAny) {
+	now := time.Now().UTC()
+	registration := mapAny{"created": now.Format(time.RFC3339), "modified": now.Format(time.RFC3339)}
+	// the time-to-live of the registration is given by the producer, the expiry time is derived from it
+	if r, ok := td["registration"].(mapAny); ok {
+		if ttl, ok := r["ttl"].(float64); ok && ttl > 0 {
+			registration["ttl"] = ttl
+			registration["expires"] = now.Add(time.Duration(ttl * float64(time.Second))).Format(time.RFC3339)
+		}
+	}
+	if previous != nil {
+		if r, ok := previous["registration"].(mapAny); ok && r["created"] != nil {
+			registration["created"] = r["created"]
+		}
+	}
+	td["registration"] = registration
+	d.tds[id] = td
+
+	if previous == nil {
+		d.order = append(d.order, id)
+		d.events.publish(eventCreate, mapAny{"id": id}, copyJSON(td).(mapAny))
+		return
+	}
+	// the changes as a merge patch, without the system-generated attributes
+	diff, _ := createMergePatch(withoutRegistration(previous), withoutRegistration(td)).(mapAny)
+	if diff == nil {
+		diff = mapAny{}
+	}
+	diff["id"] = id
+	d.events.publish(eventUpdate, mapAny{"id": id}, diff)
+}
+
+func withoutRegistration(td mapAny) mapAny {
+	c := make(mapAny, len(td))
+	for k, v := range td {
+		if k != "registration" {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+// readTD decodes a JSON object from the request body, which must have one of the given media types
+func readTD(w http.ResponseWriter, r *http.Request, mediaTypes ...string) (mapAny, bool) {
+	contentType := strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0])
+	if !inSlice(mediaTypes, contentType) {
+		writeProblem(w, http.StatusUnsupportedMediaType, "Content-Type must be one of: "+strings.Join(mediaTypes, ", "), nil)
+		return nil, false
+	}
+	var td mapAny
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&td)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Error decoding the body: "+err.Error(), nil)
+		return nil, false
+	}
+	if td == nil {
+		writeProblem(w, http.StatusBadRequest, "The body must be a JSON object", nil)
+		return nil, false
+	}
+	return td, true
+}
+
+// pagination returns the offset and limit query parameters of a listing, zero if not set
+func pagination(r *http.Request) (offset, limit int, err error) {
+	query := r.URL.Query()
+	if s := query.Get("offset"); s != "" {
+		offset, err = strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset: %s", s)
+		}
+	}
+	if s := query.Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 {
+			return 0, 0, fmt.Errorf("invalid limit: %s", s)
+		}
+	}
+	return offset, limit, nil
+}
+
+func nextLink(offset, limit int) string {
+	return fmt.Sprintf(`</things?offset=%d&limit=%d>; rel="next"`, offset, limit)
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeProblem(w, http.StatusMethodNotAllowed, "Method "+r.Method+" is not allowed", nil)
+}
+
+func inSlice(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of a map in order, for deterministic output
+func sortedKeys(m mapAny) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,65 @@
+package reference
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// problemDetails is an RFC 7807 error (https://tools.ietf.org/html/rfc7807) with the
+// validation errors extension of the directory API
+type problemDetails struct {
+	Title            string            `json:"title"`
+	Status           int               `json:"status"`
+	Detail           string            `json:"detail,omitempty"`
+	ValidationErrors []validationError `json:"validationErrors,omitempty"`
+}
+
+type validationError struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// writeProblem writes an error of the about:blank type, which has the status text as title
+func writeProblem(w http.ResponseWriter, status int, detail string, validationErrors []validationError) {
+	b, _ := json.Marshal(problemDetails{
+		Title:            http.StatusText(status),
+		Status:           status,
+		Detail:           detail,
+		ValidationErrors: validationErrors,
+	})
+	w.Header().Set("Content-Type", mediaTypeProblem)
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// validateTD checks the mandatory fields of a TD (https://www.w3.org/TR/wot-thing-description11/#thing)
+func validateTD(td mapAny) []validationError {
+	var errs []validationError
+	if td["@context"] == nil {
+		errs = append(errs, validationError{"(root)", "@context is required"})
+	}
+	if title, ok := td["title"].(string); !ok || title == "" {
+		errs = append(errs, validationError{"(root).title", "title is required and must be a non-empty string"})
+	}
+	switch security := td["security"].(type) {
+	case string:
+	case []interface{}:
+		if len(security) == 0 {
+			errs = append(errs, validationError{"(root).security", "security must not be empty"})
+		}
+	default:
+		errs = append(errs, validationError{"(root).security", "security is required and must be a string or an array"})
+	}
+	definitions, ok := td["securityDefinitions"].(mapAny)
+	if !ok || len(definitions) == 0 {
+		errs = append(errs, validationError{"(root).securityDefinitions", "securityDefinitions is required and must be a non-empty object"})
+	}
+	if id, found := td["id"]; found {
+		s, ok := id.(string)
+		if u, err := url.Parse(s); !ok || err != nil || u.Scheme == "" {
+			errs = append(errs, validationError{"(root).id", "id must be an absolute URI"})
+		}
+	}
+	return errs
+}
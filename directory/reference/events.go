@@ -0,0 +1,111 @@
+package reference
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Event types of the Notification API (https://www.w3.org/TR/wot-discovery/#exploration-directory-api-notification)
+const (
+	eventCreate = "thing_created"
+	eventUpdate = "thing_updated"
+	eventDelete = "thing_deleted"
+)
+
+// subscriberBuffer is the number of events kept for a slow subscriber before dropping them
+const subscriberBuffer = 64
+
+type subscriber struct {
+	eventType string // empty for all types
+	diff      bool
+	events    chan []byte
+}
+
+// eventBus delivers the events to the subscribers as server-sent events
+type eventBus struct {
+	sync.Mutex
+	lastID      int
+	subscribers map[*subscriber]bool
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[*subscriber]bool)}
+}
+
+// publish sends an event with the given data to the subscribers, or the data with diff to those that requested it.
+// The diff data is nil if it is the same as the data.
+func (b *eventBus) publish(eventType string, data, diff mapAny) {
+	b.Lock()
+	defer b.Unlock()
+	b.lastID++
+
+	for s := range b.subscribers {
+		if s.eventType != "" && s.eventType != eventType {
+			continue
+		}
+		d := data
+		if s.diff && diff != nil {
+			d = diff
+		}
+		j, _ := json.Marshal(d)
+		select {
+		case s.events <- []byte(fmt.Sprintf("event: %s\nid: %d\ndata: %s\n\n", eventType, b.lastID, j)):
+		default:
+		}
+	}
+}
+
+func (b *eventBus) add(s *subscriber) {
+	b.Lock()
+	defer b.Unlock()
+	b.subscribers[s] = true
+}
+
+func (b *eventBus) remove(s *subscriber) {
+	b.Lock()
+	defer b.Unlock()
+	delete(b.subscribers, s)
+}
+
+// subscribe streams the events of a type, or all types if empty, until the client disconnects
+func (d *Directory) subscribe(w http.ResponseWriter, r *http.Request, eventType string) {
+	if eventType != "" && eventType != eventCreate && eventType != eventUpdate && eventType != eventDelete {
+		writeProblem(w, http.StatusNotFound, "Unknown event type: "+eventType, nil)
+		return
+	}
+	diff := false
+	switch r.URL.Query().Get("diff") {
+	case "", "false":
+	case "true":
+		diff = true
+	default:
+		writeProblem(w, http.StatusBadRequest, "Invalid diff parameter: "+r.URL.Query().Get("diff"), nil)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeProblem(w, http.StatusInternalServerError, "Streaming is not supported", nil)
+		return
+	}
+
+	s := &subscriber{eventType: eventType, diff: diff, events: make(chan []byte, subscriberBuffer)}
+	d.events.add(s)
+	defer d.events.remove(s)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-s.events:
+			w.Write(event)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,459 @@
+package reference
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// searchJSONPath returns the values selected by a JSONPath query from the list of TDs
+func (d *Directory) searchJSONPath(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("query")
+	if query == "" {
+		writeProblem(w, http.StatusBadRequest, "The query parameter is required", nil)
+		return
+	}
+	path, err := parseJSONPath(query)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "Invalid JSONPath query: "+err.Error(), nil)
+		return
+	}
+
+	d.RLock()
+	tds := make([]interface{}, 0, len(d.order))
+	for _, id := range d.order {
+		tds = append(tds, d.tds[id])
+	}
+	results := path.evaluate(tds)
+	b, err := json.Marshal(results)
+	d.RUnlock()
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	w.Header().Set("Content-Type", mediaTypeJSON)
+	w.Write(b)
+}
+
+// jsonPath is a query in the JSONPath syntax of https://goessner.net/articles/JsonPath/.
+// It supports child names, wildcards, array indexes, recursive descent and filters with comparisons,
+// but not slices, unions and script expressions.
+type jsonPath []jsonPathSelector
+
+type jsonPathSelector struct {
+	recursive bool
+	name      string
+	index     *int
+	filter    filterExpression
+}
+
+// filterExpression tells whether a value is selected by a filter
+type filterExpression func(current interface{}) bool
+
+func (p jsonPath) evaluate(root interface{}) []interface{} {
+	nodes := []interface{}{root}
+	for _, s := range p {
+		if s.recursive {
+			var descendants []interface{}
+			for _, n := range nodes {
+				descendants = appendDescendants(descendants, n)
+			}
+			nodes = descendants
+		}
+		var selected []interface{}
+		for _, n := range nodes {
+			selected = append(selected, s.selectFrom(n)...)
+		}
+		nodes = selected
+	}
+	if nodes == nil {
+		return []interface{}{}
+	}
+	return nodes
+}
+
+// appendDescendants appends a value and all values nested in it
+func appendDescendants(values []interface{}, v interface{}) []interface{} {
+	values = append(values, v)
+	switch v := v.(type) {
+	case mapAny:
+		for _, k := range sortedKeys(v) {
+			values = appendDescendants(values, v[k])
+		}
+	case []interface{}:
+		for _, e := range v {
+			values = appendDescendants(values, e)
+		}
+	}
+	return values
+}
+
+func (s jsonPathSelector) selectFrom(v interface{}) []interface{} {
+	var children []interface{}
+	switch v := v.(type) {
+	case mapAny:
+		if s.name != "" {
+			if child, found := v[s.name]; found {
+				return []interface{}{child}
+			}
+			return nil
+		}
+		if s.index != nil {
+			return nil
+		}
+		for _, k := range sortedKeys(v) {
+			children = append(children, v[k])
+		}
+	case []interface{}:
+		if s.name != "" {
+			return nil
+		}
+		if s.index != nil {
+			i := *s.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i < 0 || i >= len(v) {
+				return nil
+			}
+			return []interface{}{v[i]}
+		}
+		children = v
+	default:
+		return nil
+	}
+	if s.filter == nil {
+		return children
+	}
+	var selected []interface{}
+	for _, c := range children {
+		if s.filter(c) {
+			selected = append(selected, c)
+		}
+	}
+	return selected
+}
+
+// parseJSONPath parses a query that starts at the root ($)
+func parseJSONPath(query string) (jsonPath, error) {
+	p := &jsonPathParser{s: query}
+	if !p.consume("$") {
+		return nil, fmt.Errorf("must start with $")
+	}
+	path, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.rest(), p.pos)
+	}
+	return path, nil
+}
+
+type jsonPathParser struct {
+	s   string
+	pos int
+}
+
+func (p *jsonPathParser) rest() string {
+	return p.s[p.pos:]
+}
+
+func (p *jsonPathParser) skipSpaces() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// consume skips the given token, if next
+func (p *jsonPathParser) consume(token string) bool {
+	p.skipSpaces()
+	if strings.HasPrefix(p.rest(), token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+// path parses selectors until the end of the query, or of a relative path in a filter
+func (p *jsonPathParser) path() (jsonPath, error) {
+	var path jsonPath
+	for p.pos < len(p.s) {
+		var s jsonPathSelector
+		switch {
+		case strings.HasPrefix(p.rest(), ".."):
+			p.pos += 2
+			s.recursive = true
+			if !strings.HasPrefix(p.rest(), "[") {
+				if err := p.dotSelector(&s); err != nil {
+					return nil, err
+				}
+				break
+			}
+			p.pos++
+			if err := p.bracketSelector(&s); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(p.rest(), "."):
+			p.pos++
+			if err := p.dotSelector(&s); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(p.rest(), "["):
+			p.pos++
+			if err := p.bracketSelector(&s); err != nil {
+				return nil, err
+			}
+		default:
+			return path, nil
+		}
+		path = append(path, s)
+	}
+	return path, nil
+}
+
+func (p *jsonPathParser) dotSelector(s *jsonPathSelector) error {
+	if strings.HasPrefix(p.rest(), "*") {
+		// all children, like a selector without name, index or filter
+		p.pos++
+		return nil
+	}
+	start := p.pos
+	for p.pos < len(p.s) && isNameChar(p.s[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return fmt.Errorf("expected a name at position %d", start)
+	}
+	s.name = p.s[start:p.pos]
+	return nil
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c == '-' || c == '@' || c == ':' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// bracketSelector parses the selector after [ up to and including ]
+func (p *jsonPathParser) bracketSelector(s *jsonPathSelector) error {
+	p.skipSpaces()
+	switch {
+	case p.consume("*"):
+	case p.consume("?("):
+		filter, err := p.or()
+		if err != nil {
+			return err
+		}
+		if !p.consume(")") {
+			return fmt.Errorf("expected ) at position %d", p.pos)
+		}
+		s.filter = filter
+	case strings.HasPrefix(p.rest(), "'") || strings.HasPrefix(p.rest(), `"`):
+		name, err := p.quoted()
+		if err != nil {
+			return err
+		}
+		s.name = name
+	default:
+		start := p.pos
+		if p.pos < len(p.s) && p.s[p.pos] == '-' {
+			p.pos++
+		}
+		for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+			p.pos++
+		}
+		i, err := strconv.Atoi(p.s[start:p.pos])
+		if err != nil {
+			return fmt.Errorf("unsupported selector at position %d", start)
+		}
+		s.index = &i
+	}
+	if !p.consume("]") {
+		return fmt.Errorf("expected ] at position %d", p.pos)
+	}
+	return nil
+}
+
+func (p *jsonPathParser) quoted() (string, error) {
+	quote := p.s[p.pos]
+	var b strings.Builder
+	for i := p.pos + 1; i < len(p.s); i++ {
+		switch p.s[i] {
+		case '\\':
+			if i+1 < len(p.s) {
+				i++
+				b.WriteByte(p.s[i])
+			}
+		case quote:
+			p.pos = i + 1
+			return b.String(), nil
+		default:
+			b.WriteByte(p.s[i])
+		}
+	}
+	return "", fmt.Errorf("unterminated string at position %d", p.pos)
+}
+
+// or parses a filter expression of the form: and ('||' and)*
+func (p *jsonPathParser) or() (filterExpression, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("||") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(v interface{}) bool { return l(v) || right(v) }
+	}
+	return left, nil
+}
+
+// and parses a filter expression of the form: unary ('&&' unary)*
+func (p *jsonPathParser) and() (filterExpression, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("&&") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(v interface{}) bool { return l(v) && right(v) }
+	}
+	return left, nil
+}
+
+// unary parses a negation, a parenthesized expression or a comparison
+func (p *jsonPathParser) unary() (filterExpression, error) {
+	if p.consume("!") {
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(v interface{}) bool { return !e(v) }, nil
+	}
+	if p.consume("(") {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, fmt.Errorf("expected ) at position %d", p.pos)
+		}
+		return e, nil
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			right, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			return func(v interface{}) bool {
+				l, lok := left(v)
+				r, rok := right(v)
+				return lok && rok && compare(l, op, r)
+			}, nil
+		}
+	}
+	// existence of a value
+	return func(v interface{}) bool {
+		_, ok := left(v)
+		return ok
+	}, nil
+}
+
+// operand returns the value of a relative path (@) or a literal, and whether it exists
+type operand func(current interface{}) (interface{}, bool)
+
+func (p *jsonPathParser) operand() (operand, error) {
+	p.skipSpaces()
+	rest := p.rest()
+	switch {
+	case strings.HasPrefix(rest, "@"):
+		p.pos++
+		path, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		return func(v interface{}) (interface{}, bool) {
+			values := path.evaluate(v)
+			if len(values) != 1 {
+				return nil, false
+			}
+			return values[0], true
+		}, nil
+	case strings.HasPrefix(rest, "'") || strings.HasPrefix(rest, `"`):
+		s, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		return literal(s), nil
+	case strings.HasPrefix(rest, "true"):
+		p.pos += 4
+		return literal(true), nil
+	case strings.HasPrefix(rest, "false"):
+		p.pos += 5
+		return literal(false), nil
+	case strings.HasPrefix(rest, "null"):
+		p.pos += 4
+		return literal(nil), nil
+	}
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte("+-.eE0123456789", p.s[p.pos]) != -1 {
+		p.pos++
+	}
+	f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		return nil, fmt.Errorf("expected an operand at position %d", start)
+	}
+	return literal(f), nil
+}
+
+func literal(l interface{}) operand {
+	return func(interface{}) (interface{}, bool) { return l, true }
+}
+
+func compare(l interface{}, op string, r interface{}) bool {
+	switch op {
+	case "==":
+		return reflect.DeepEqual(l, r)
+	case "!=":
+		return !reflect.DeepEqual(l, r)
+	}
+	if lf, ok := l.(float64); ok {
+		if rf, ok := r.(float64); ok {
+			return compareOrdered(lf < rf, lf == rf, op)
+		}
+	}
+	if ls, ok := l.(string); ok {
+		if rs, ok := r.(string); ok {
+			return compareOrdered(ls < rs, ls == rs, op)
+		}
+	}
+	return false
+}
+
+func compareOrdered(less, equal bool, op string) bool {
+	switch op {
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	default: // >=
+		return !less
+	}
+}
@@ -0,0 +1,68 @@
+package reference
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestJSONPath checks the evaluation of the supported JSONPath syntax and the rejection of the rest.
+func TestJSONPath(t *testing.T) {
+	var tds interface{}
+	json.Unmarshal([]byte(`[
+		{"id": "urn:a", "title": "lamp", "tag": "x", "version": {"instance": 1}, "properties": {"on": {"type": "boolean"}}},
+		{"id": "urn:b", "title": "sensor", "tag": "y", "version": {"instance": 2}},
+		{"id": "urn:c", "title": "switch", "tag": "x"}
+	]`), &tds)
+
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{"$[?(@.tag=='x')].id", `["urn:a", "urn:c"]`},
+		{`$[?(@.tag == "x" && @.title != 'lamp')].id`, `["urn:c"]`},
+		{"$[?(@.version.instance > 1 || !@.version)].title", `["sensor", "switch"]`},
+		{"$[?(@.properties)].id", `["urn:a"]`},
+		{"$[0]['title']", `["lamp"]`},
+		{"$[-1].id", `["urn:c"]`},
+		{"$[*].version.instance", `[1, 2]`},
+		{"$..type", `["boolean"]`},
+		{"$.missing", `[]`},
+	}
+	for _, c := range cases {
+		path, err := parseJSONPath(c.query)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.query, err)
+			continue
+		}
+		var expected []interface{}
+		json.Unmarshal([]byte(c.expected), &expected)
+		if got := path.evaluate(tds); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %v, got %v", c.query, expected, got)
+		}
+	}
+
+	for _, query := range []string{"*/id", "$[?(@.tag=='x')", "$[1:2]", "$.", "$[?(@.tag=='x)]"} {
+		if _, err := parseJSONPath(query); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+}
+
+// TestMergePatch checks that a created merge patch turns a TD into the other (RFC 7396).
+func TestMergePatch(t *testing.T) {
+	var a, b mapAny
+	json.Unmarshal([]byte(`{"title": "a", "description": "d", "properties": {"on": {"type": "boolean"}, "off": {}}}`), &a)
+	json.Unmarshal([]byte(`{"title": "b", "properties": {"on": {"type": "boolean"}, "level": {"type": "number"}}}`), &b)
+
+	patch := createMergePatch(a, b)
+	var expected mapAny
+	json.Unmarshal([]byte(`{"title": "b", "description": null, "properties": {"off": null, "level": {"type": "number"}}}`), &expected)
+	if !reflect.DeepEqual(patch, expected) {
+		t.Fatalf("Expected patch %v, got %v", expected, patch)
+	}
+
+	if patched := mergePatch(copyJSON(a), patch); !reflect.DeepEqual(patched, b) {
+		t.Fatalf("Expected patched %v, got %v", b, patched)
+	}
+}
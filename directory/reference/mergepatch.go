@@ -0,0 +1,61 @@
+package reference
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// mergePatch applies a JSON merge patch (RFC 7396, Section 2) to a decoded JSON value
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(mapAny)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(mapAny)
+	if !ok {
+		t = mapAny{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// createMergePatch returns the merge patch that changes a into b, nil if they are equal
+func createMergePatch(a, b mapAny) interface{} {
+	patch := mapAny{}
+	for k := range a {
+		if _, found := b[k]; !found {
+			patch[k] = nil
+		}
+	}
+	for k, v := range b {
+		previous, found := a[k]
+		if found && reflect.DeepEqual(previous, v) {
+			continue
+		}
+		previousMap, wasMap := previous.(mapAny)
+		vMap, isMap := v.(mapAny)
+		if found && wasMap && isMap {
+			patch[k] = createMergePatch(previousMap, vMap)
+			continue
+		}
+		patch[k] = v
+	}
+	if len(patch) == 0 {
+		return nil
+	}
+	return patch
+}
+
+// copyJSON returns a deep copy of a decoded JSON value
+func copyJSON(v interface{}) interface{} {
+	b, _ := json.Marshal(v)
+	var c interface{}
+	json.Unmarshal(b, &c)
+	return c
+}
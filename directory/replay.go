@@ -0,0 +1,195 @@
+package directory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// replayTransport is an http.RoundTripper that serves the responses recorded in a HAR file instead of sending requests.
+// Requests are matched by method, path with query and body. Identical requests are served
+// the recorded responses in order, and the last one once they run out.
+type replayTransport struct {
+	sync.Mutex
+	entries map[string][]*harEntry
+	served  map[string]int
+}
+
+// replayed is the sequence of UUIDs generated by the recorded run, nil if not replaying
+var replayed struct {
+	sync.Mutex
+	uuids []string
+	times map[string]time.Time // recorded time of the last response replayed to each test
+}
+
+// timeNow returns the current time. It is replaced by tests that run later than a recording.
+var timeNow = time.Now
+
+// testTime returns the time to check the times in the responses to a test against: the current time or,
+// when replaying, the recorded time of the last response replayed to the test or to its closest parent
+func testTime(t *testing.T) time.Time {
+	replayed.Lock()
+	defer replayed.Unlock()
+	for name := t.Name(); ; {
+		if recorded, found := replayed.times[name]; found {
+			return recorded
+		}
+		i := strings.LastIndex(name, "/")
+		if i == -1 {
+			return timeNow()
+		}
+		name = name[:i]
+	}
+}
+
+// newUUID returns a random UUID or, when replaying, the one generated at the same point of the recorded run
+// so that the requests match the recorded ones
+func newUUID() string {
+	replayed.Lock()
+	defer replayed.Unlock()
+
+	var id string
+	if len(replayed.uuids) > 0 {
+		id, replayed.uuids = replayed.uuids[0], replayed.uuids[1:]
+	} else {
+		id = uuid.NewV4().String()
+	}
+
+	archive.Lock()
+	archive.uuids = append(archive.uuids, id)
+	archive.Unlock()
+	return id
+}
+
+// loadReplay reads a HAR file to replay and returns the transport and the URL of the recorded server
+func loadReplay(filename string) (*replayTransport, string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, "", err
+	}
+	var har harLog
+	err = json.Unmarshal(b, &har)
+	if err != nil {
+		return nil, "", fmt.Errorf("error decoding HAR file: %s", err)
+	}
+
+	t := &replayTransport{
+		entries: make(map[string][]*harEntry),
+		served:  make(map[string]int),
+	}
+	var server string
+	for _, e := range har.Log.Entries {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid URL in HAR file: %s", err)
+		}
+		if server == "" {
+			server = u.Scheme + "://" + u.Host
+		}
+		var body string
+		if e.Request.PostData != nil {
+			body = e.Request.PostData.Text
+		}
+		key := replayKey(e.Request.Method, u.RequestURI(), body)
+		t.entries[key] = append(t.entries[key], e)
+	}
+
+	replayed.Lock()
+	replayed.uuids = har.Log.UUIDs
+	replayed.times = make(map[string]time.Time)
+	replayed.Unlock()
+
+	return t, server, nil
+}
+
+func replayKey(method, path, body string) string {
+	return method + " " + path + "\n" + body
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.GetBody != nil {
+		r, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		body, _ = io.ReadAll(r)
+	}
+	key := replayKey(req.Method, req.URL.RequestURI(), string(body))
+
+	t.Lock()
+	entries := t.entries[key]
+	if len(entries) == 0 {
+		t.Unlock()
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL.RequestURI())
+	}
+	i := t.served[key]
+	if i < len(entries)-1 {
+		t.served[key]++
+	}
+	e := entries[i]
+	t.Unlock()
+
+	if name, ok := req.Context().Value(testNameKey{}).(string); ok {
+		if started, err := time.Parse(time.RFC3339Nano, e.StartedDateTime); err == nil {
+			replayed.Lock()
+			replayed.times[name] = started
+			replayed.Unlock()
+		}
+	}
+
+	if e.Response.Error != "" {
+		return nil, fmt.Errorf("%s", e.Response.Error)
+	}
+
+	res := &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Response.Status, e.Response.StatusText),
+		StatusCode:    e.Response.Status,
+		Proto:         e.Response.HTTPVersion,
+		Header:        make(http.Header),
+		ContentLength: -1,
+		Request:       req,
+	}
+	res.ProtoMajor, res.ProtoMinor, _ = http.ParseHTTPVersion(e.Response.HTTPVersion)
+	for _, h := range e.Response.Headers {
+		res.Header.Add(h.Name, h.Value)
+	}
+
+	content := strings.NewReader(e.Response.Content.Text)
+	if strings.HasPrefix(e.Response.Content.MimeType, "text/event-stream") {
+		// keep the stream open after the recorded events, like the server did
+		stream := &openStream{closed: make(chan struct{})}
+		res.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(content, stream), stream}
+	} else {
+		res.Body = io.NopCloser(content)
+	}
+	return res, nil
+}
+
+// openStream blocks reads until it is closed
+type openStream struct {
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (s *openStream) Read(p []byte) (int, error) {
+	<-s.closed
+	return 0, io.EOF
+}
+
+func (s *openStream) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
@@ -0,0 +1,58 @@
+//go:build !conformance
+// +build !conformance
+
+package directory
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wot-discovery-testing/directory/reference"
+)
+
+// TestReplayRoundTrip records the traffic of a test against the reference directory and replays it
+// later than the registration times of a live run allow.
+func TestReplayRoundTrip(t *testing.T) {
+	restoreHTTPClients(t)
+	hrefs, now := selfDescription.hrefs, timeNow
+	defer func() {
+		selfDescription.hrefs, timeNow = hrefs, now
+		replayed.Lock()
+		replayed.uuids, replayed.times = nil, nil
+		replayed.Unlock()
+	}()
+	selfDescription.hrefs = nil
+
+	server := httptest.NewServer(reference.NewDirectory())
+	defer server.Close()
+	archive.Lock()
+	archive.entries, archive.uuids = nil, nil
+	archive.Unlock()
+	setupHTTPClient(clientConfig{tls: &tls.Config{}})
+
+	registration := func(t *testing.T) {
+		id := "urn:uuid:" + newUUID()
+		createThing(id, mockedTD(id), server.URL, t)
+		td := retrieveThing(id, server.URL, t)
+		testRegistrationInfoCreated(t, td)
+		testRegistrationInfoModified(t, td)
+	}
+	t.Run("record", registration)
+
+	filename := filepath.Join(t.TempDir(), harFile)
+	writeHARFile(filename)
+	transport, _, err := loadReplay(filename)
+	if err != nil {
+		t.Fatalf("Error loading the recording: %s", err)
+	}
+	setupHTTPClient(clientConfig{tls: &tls.Config{}, replay: transport})
+	server.Close()
+
+	timeNow = func() time.Time {
+		return time.Now().Add(2 * time.Hour)
+	}
+	t.Run("replay", registration)
+}
@@ -1,142 +1,312 @@
 package directory
 
 import (
+	"crypto/tls"
 	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 )
 
-const reportFile = "report/tdd-auto.csv"
+// defaultReportDir is where the reports are written, unless set by --reportDir
+const defaultReportDir = "report"
+
+var reportDir = defaultReportDir
+
+// names of the reports in the report directory
+const (
+	reportFile           = "tdd-auto.csv"
+	suiteReportFile      = "tdd-suite.csv"
+	junitReportFile      = "tdd-auto.xml"
+	earlTurtleReportFile = "tdd-auto.ttl"
+	earlJSONLDReportFile = "tdd-auto.jsonld"
+	htmlReportFile       = "tdd-auto.html"
+	jsonReportFile       = "tdd-auto.json"
+)
+
+// report formats
+const (
+	reportFormatCSV        = "csv"
+	reportFormatJUnit      = "junit"
+	reportFormatEARLTurtle = "earl-turtle"
+	reportFormatEARLJSONLD = "earl-jsonld"
+	reportFormatHTML       = "html"
+	reportFormatJSON       = "json"
+)
 
 var header = []string{"ID", "Status", "Comment"}
 
 var results map[string]result
 
+// reportConfig holds the settings of the report writer
+type reportConfig struct {
+	specVersion   string     // version of the embedded assertion catalogs, instead of downloading them
+	templateURL   string     // to download the template from
+	manualURL     string     // to download the manual list from
+	manualResults string     // optional file with results of manual testing
+	waivers       string     // optional file with expected outcomes
+	inlineWaivers [][]string // expected outcomes listed in the config file
+	serverURL     string     // the directory under test
+	tls           *tls.Config
+	formats       []string
+	effective     []configEntry // configuration of the run
+}
+
 type result struct {
 	passed  []string
 	failed  []string
 	skipped []string
+	errored []string // failed after an infrastructure error, e.g. a timeout
+	// details of the subtests, keyed by subtest name
+	details map[string]*subtestDetails
+}
+
+// subtestDetails are the messages logged by a subtest, its elapsed time and the reason for skipping it
+type subtestDetails struct {
+	messages   []string
+	elapsed    time.Duration
+	skipReason string
+}
+
+// reportSources are the assertion catalogs, the results of manual testing and the waivers, which the results are reported with
+type reportSources struct {
+	catalog                      string // source of the assertions
+	assertions, manualAssertions []string
+	manualResults                map[string][]string
+	waivers                      []waiver
+}
+
+// initReportWriter prepares the results and returns a function which writes the reports after all tests.
+// The commit function returns the number of assertions with an unexpected outcome.
+func initReportWriter(config reportConfig) (commit func() (unexpected int)) {
+	sources := loadReportSources(config)
+
+	// prepare the slice so tests can append to it
+	results = make(map[string]result)
+	exchanges = make(map[string][]exchange)
+
+	// keep the messages of tests
+	testEvidence.Lock()
+	testEvidence.details = make(map[string]*subtestDetails)
+	testEvidence.Unlock()
+
+	// return commit function so it can be run after all tests
+	return func() int {
+		addEvidence()
+
+		// parameters of the connection to the directory, if over TLS
+		connection := negotiatedTLSInfo(config.tls)
+		if connection != nil {
+			fmt.Printf("TLS: %s\n", connection)
+		}
+		return writeReports(config, sources, connection)
+	}
 }
 
-func initReportWriter(templateURL, manualURL string) (commit func()) {
-	err := os.MkdirAll("report", 0755)
+// loadReportSources checks the report formats, creates the report directory and loads the sources of the reports
+func loadReportSources(config reportConfig) reportSources {
+	for _, format := range config.formats {
+		switch format {
+		case reportFormatCSV, reportFormatJUnit, reportFormatEARLTurtle, reportFormatEARLJSONLD, reportFormatHTML, reportFormatJSON:
+		default:
+			fmt.Printf("Unknown report format: %s\n", format)
+			os.Exit(1)
+		}
+	}
+
+	err := os.MkdirAll(reportDir, 0755)
 	if err != nil {
 		fmt.Printf("Error creating report directory: %s\n", err)
 		os.Exit(1)
 	}
 
-	assertionsList := loadAssertions(templateURL)
-	manualAssertionsList := loadAssertions(manualURL)
+	// record the source of the assertions
+	var sources reportSources
+	if config.specVersion != "" {
+		sources.catalog = "embedded " + config.specVersion
+		sources.assertions = loadEmbeddedAssertions(config.specVersion, catalogTemplateFile)
+		sources.manualAssertions = loadEmbeddedAssertions(config.specVersion, catalogManualFile)
+	} else {
+		sources.catalog = config.templateURL
+		sources.assertions, err = loadAssertions(config.templateURL)
+		if err == nil {
+			sources.manualAssertions, err = loadAssertions(config.manualURL)
+		}
+		if err != nil {
+			fmt.Printf("Warning: %s. The assertions are reported without a catalog, which checks neither their IDs nor their coverage.\n", err)
+			sources.catalog = catalogNone
+			sources.assertions, sources.manualAssertions = nil, nil
+		}
+	}
+	if config.manualResults != "" {
+		sources.manualResults = loadManualResults(config.manualResults)
+	}
+	if len(config.inlineWaivers) > 0 {
+		sources.waivers = parseWaivers(config.inlineWaivers)
+	}
+	if config.waivers != "" {
+		sources.waivers = append(sources.waivers, loadWaivers(config.waivers)...)
+	}
+	return sources
+}
 
-	// prepare the slice so tests can append to it
-	results = make(map[string]result)
+// writeReports writes the results in the configured formats, with the rollup, coverage and HAR files.
+// It returns the number of assertions with an unexpected outcome.
+func writeReports(config reportConfig, sources reportSources, connection *tlsInfo) (unexpected int) {
+	assertionsList, manualAssertionsList := sources.assertions, sources.manualAssertions
 
-	// return commit function so it can be run after all tests
-	return func() {
-		// Generate auto testing report
-		// convert to csv records (2D slice)
-		var resultsSlice [][]string
-		for id, result := range results {
-			resultsSlice = append(resultsSlice, resultToCSVRecord(id, result))
-		}
-		// sort by id
-		sort.Slice(resultsSlice, func(i, j int) bool {
-			return resultsSlice[i][0] < resultsSlice[j][0]
-		})
-		writeCSVReport(reportFile, resultsSlice)
-
-		// find invalid assertions
-		var invalidAssertions []string
-		for i := range resultsSlice {
-			id := resultsSlice[i][0]
-			if !inSlice(assertionsList, id) {
-				invalidAssertions = append(invalidAssertions, id)
+	// compare with the expected outcomes
+	outcomes := make(map[string]waivedOutcome)
+	for id, result := range results {
+		outcomes[id] = evaluateWaivers(sources.waivers, id, result)
+	}
+
+	// the suite assertions are kept out of the reports of the specification's assertions
+	specResults, suiteResults := splitSuiteResults(results)
+
+	// Generate auto testing report
+	// convert to csv records (2D slice)
+	resultsSlice := csvRecords(specResults, outcomes)
+	// derive the status of parent assertions
+	rollups := rollupResults(assertionsList, specResults)
+	writeRollupReport(reportPath(rollupReportFile), rollups)
+
+	writeHARFile(reportPath(harFile))
+	config.effective = withCatalog(config.effective, sources.catalog)
+	writeConfigReport(reportPath(configReportFile), withTLS(config.effective, connection))
+
+	for _, format := range config.formats {
+		switch format {
+		case reportFormatCSV:
+			writeCSVReport(reportPath(reportFile), resultsSlice)
+			if len(suiteResults) > 0 {
+				writeCSVReport(reportPath(suiteReportFile), csvRecords(suiteResults, outcomes))
 			}
+		case reportFormatJUnit:
+			writeJUnitReport(reportPath(junitReportFile), subtestsFromResults(results))
+		case reportFormatEARLTurtle:
+			writeEARLTurtleReport(reportPath(earlTurtleReportFile), config.serverURL, specResults)
+		case reportFormatEARLJSONLD:
+			writeEARLJSONLDReport(reportPath(earlJSONLDReportFile), config.serverURL, specResults)
+		case reportFormatHTML:
+			writeHTMLReport(reportPath(htmlReportFile), config.serverURL, sources.catalog, connection, config.effective, results, rollups, outcomes)
+		case reportFormatJSON:
+			writeJSONReport(reportPath(jsonReportFile), config.serverURL, sources.catalog, connection, config.effective, results, outcomes)
 		}
-		if len(invalidAssertions) > 0 {
-			fmt.Printf("\nWarning: The following tested assertions do not exist in the list of normative assertions: %v\n\n",
-				invalidAssertions)
+	}
+
+	// find invalid assertions
+	var invalidAssertions []string
+	for i := range resultsSlice {
+		id := resultsSlice[i][0]
+		if assertionsList != nil && !inSlice(assertionsList, id) {
+			invalidAssertions = append(invalidAssertions, id)
 		}
+	}
+	if len(invalidAssertions) > 0 {
+		fmt.Printf("\nWarning: The following tested assertions do not exist in the list of normative assertions: %v\n\n",
+			invalidAssertions)
+	}
 
-		// find tested assertions that expected to done manually
-		var invalidManual []string
-		for i := range resultsSlice {
-			id := resultsSlice[i][0]
-			if inSlice(manualAssertionsList, id) {
-				invalidManual = append(invalidManual, id)
-			}
+	// find tested assertions that expected to done manually
+	var invalidManual []string
+	for i := range resultsSlice {
+		id := resultsSlice[i][0]
+		if inSlice(manualAssertionsList, id) {
+			invalidManual = append(invalidManual, id)
 		}
-		if len(invalidManual) > 0 {
-			fmt.Printf("\nError: The following tested assertions were in the manual list: %v\n\n",
-				invalidManual)
+	}
+	if len(invalidManual) > 0 {
+		fmt.Printf("\nError: The following tested assertions were in the manual list: %v\n\n",
+			invalidManual)
+	}
+
+	// find assertions that are not covered by any test
+	if assertionsList != nil {
+		writeCoverageReport(reportPath(coverageReportFile), assertionsList, manualAssertionsList, specResults)
+	}
+
+	// merge with the results of manual testing
+	if sources.manualResults != nil {
+		writeCombinedReport(reportPath(combinedReportFile), resultsSlice, sources.manualResults, manualAssertionsList)
+	}
+
+	return printWaivedOutcomes(outcomes)
+}
+
+// csvRecords converts results to records of the CSV report with the waivers of their outcomes, sorted by assertion ID
+func csvRecords(results map[string]result, outcomes map[string]waivedOutcome) [][]string {
+	var records [][]string
+	for id, result := range results {
+		record := resultToCSVRecord(id, result)
+		if o := outcomes[id]; o.expected != "" {
+			record[2] += fmt.Sprintf(" waiver:%s %s", o.expected, o.justification)
 		}
+		records = append(records, record)
 	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i][0] < records[j][0]
+	})
+	return records
 }
 
-// loadAssertions returns the list of assertions.
+// loadAssertions returns the list of assertions downloaded from a URL.
 // It will read from a local file.
 // If the local file is not available, it will be downloaded from the source
-func loadAssertions(templateURL string) []string {
+func loadAssertions(templateURL string) ([]string, error) {
 	urlParts := strings.Split(templateURL, "/")
-	templateFile := "report/" + urlParts[len(urlParts)-1]
+	templateFile := reportPath(urlParts[len(urlParts)-1])
 
 	if _, err := os.Stat(templateFile); errors.Is(err, os.ErrNotExist) {
 		fmt.Println("Downloading assertions from", templateURL)
 		resp, err := http.Get(templateURL)
 		if err != nil {
-			fmt.Printf("Error downloading assertions template: %s\n", err)
-			os.Exit(1)
+			return nil, fmt.Errorf("error downloading assertions: %s", err)
 		}
 		defer resp.Body.Close()
-
-		fmt.Println("Saving assertions to", templateFile)
-		file, err := os.Create(templateFile)
+		b, err := io.ReadAll(resp.Body)
 		if err != nil {
-			fmt.Printf("Error creating assertions template file: %s\n", err)
-			os.Exit(1)
+			return nil, fmt.Errorf("error downloading assertions: %s", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("error downloading assertions from %s: %s", templateURL, resp.Status)
 		}
-		defer file.Close()
 
-		_, err = io.Copy(file, resp.Body)
+		fmt.Println("Saving assertions to", templateFile)
+		err = os.WriteFile(templateFile, b, 0644)
 		if err != nil {
-			fmt.Printf("Error copying http response to assertions template file: %s\n", err)
-			os.Exit(1)
+			return nil, fmt.Errorf("error saving assertions: %s", err)
 		}
 	}
 
-	var err error
 	fmt.Println("Reading assertions from", templateFile)
 	file, err := os.Open(templateFile)
 	if err != nil {
-		fmt.Printf("Error opening assertions template file: %s\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("error opening assertions file: %s", err)
 	}
 	defer file.Close()
 
-	records, err := csv.NewReader(file).ReadAll()
-	if err != nil {
-		fmt.Printf("Error reading assertions template file: %s\n", err)
-		os.Exit(1)
-	}
-
-	var assertionIDs []string
-	for _, record := range records {
-		assertionIDs = append(assertionIDs, record[0])
-	}
-
-	return assertionIDs
+	return readAssertions(file), nil
 }
 
 func insertRecord(t *testing.T, name string, assertions []string) {
 	for _, a := range assertions {
 		result := results[a]
-		if t.Failed() {
+		if result.details == nil {
+			result.details = make(map[string]*subtestDetails)
+		}
+		// completed with the test output after all tests
+		result.details[name] = &subtestDetails{}
+		if _, found := infrastructureError(name); found && t.Failed() {
+			result.errored = append(result.errored, name)
+		} else if t.Failed() {
 			result.failed = append(result.failed, name)
 		} else if t.Skipped() {
 			result.skipped = append(result.skipped, name)
@@ -149,7 +319,30 @@ func insertRecord(t *testing.T, name string, assertions []string) {
 
 }
 
+// addEvidence completes the details of the reported subtests with their messages, elapsed time and skip reason
+func addEvidence() {
+	testEvidence.Lock()
+	defer testEvidence.Unlock()
+	for _, r := range results {
+		for name, details := range r.details {
+			if e, found := testEvidence.details[name]; found {
+				*details = *e
+			}
+		}
+	}
+}
+
+// reportPath returns the path of a report in the report directory
+func reportPath(name string) string {
+	return filepath.Join(reportDir, name)
+}
+
 func writeCSVReport(filename string, input [][]string) {
+	writeCSVFile(filename, header, input)
+}
+
+// writeCSVFile writes the header and records to a CSV file
+func writeCSVFile(filename string, header []string, input [][]string) {
 	// prepend the header
 	input = append([][]string{header}, input...)
 
@@ -178,20 +371,29 @@ func writeCSVReport(filename string, input [][]string) {
 	file.Close()
 }
 
-func resultToCSVRecord(assertionID string, r result) []string {
-	var status string
+// resultStatus returns the status of an assertion: fail if any subtest failed,
+// error if any failed after an infrastructure error, null if any was skipped, and pass otherwise
+func resultStatus(r result) string {
 	if len(r.failed) > 0 {
-		status = "fail"
+		return "fail"
+	} else if len(r.errored) > 0 {
+		return "error"
 	} else if len(r.skipped) > 0 {
-		status = "null"
-	} else {
-		status = "pass"
+		return "null"
 	}
+	return "pass"
+}
+
+func resultToCSVRecord(assertionID string, r result) []string {
+	status := resultStatus(r)
 
 	var details []string
 	if len(r.failed) > 0 {
 		details = append(details, fmt.Sprint("failed:", strings.Join(r.failed, " failed:")))
 	}
+	if len(r.errored) > 0 {
+		details = append(details, fmt.Sprint("errored:", strings.Join(r.errored, " errored:")))
+	}
 	if len(r.skipped) > 0 {
 		details = append(details, fmt.Sprint("skipped:", strings.Join(r.skipped, " skipped:")))
 	}
@@ -206,6 +408,62 @@ func resultToCSVRecord(assertionID string, r result) []string {
 	}
 }
 
+// subtest is the outcome of a single Go subtest and the assertions it reported
+type subtest struct {
+	name       string
+	status     string // passed, failed, errored or skipped
+	assertions []string
+	details    subtestDetails
+}
+
+// subtestsFromResults inverts the results to list each reported subtest once, sorted by name
+func subtestsFromResults(results map[string]result) []subtest {
+	subtests := make(map[string]*subtest)
+	add := func(status, name, assertion string) {
+		s, found := subtests[name]
+		if !found {
+			s = &subtest{name: name, status: status}
+			if d := results[assertion].details[name]; d != nil {
+				s.details = *d
+			}
+			subtests[name] = s
+		}
+		s.assertions = append(s.assertions, assertion)
+	}
+	for id, r := range results {
+		for _, name := range r.passed {
+			add("passed", name, id)
+		}
+		for _, name := range r.failed {
+			add("failed", name, id)
+		}
+		for _, name := range r.errored {
+			add("errored", name, id)
+		}
+		for _, name := range r.skipped {
+			add("skipped", name, id)
+		}
+	}
+
+	var subtestsSlice []subtest
+	for _, s := range subtests {
+		sort.Strings(s.assertions)
+		subtestsSlice = append(subtestsSlice, *s)
+	}
+	sort.Slice(subtestsSlice, func(i, j int) bool {
+		return subtestsSlice[i].name < subtestsSlice[j].name
+	})
+	return subtestsSlice
+}
+
+// assertionGroup returns the prefix of the assertion ID, e.g. tdd-things for tdd-things-crud
+func assertionGroup(id string) string {
+	if parts := strings.SplitN(id, "-", 3); len(parts) > 2 {
+		return parts[0] + "-" + parts[1]
+	}
+	return id
+}
+
 // report at the end of tests. Execute with defer statement.
 func report(t *testing.T, assertions ...string) {
 	// if len(assertions) == 0 {
@@ -0,0 +1,85 @@
+package directory
+
+import (
+	"fmt"
+	"sort"
+)
+
+const coverageReportFile = "tdd-coverage.csv"
+
+var coverageHeader = []string{"Group", "ID"}
+
+// groupCoverage counts the assertions of a group
+type groupCoverage struct {
+	name     string
+	total    int
+	tested   int
+	manual   int
+	untested []string
+}
+
+func (g groupCoverage) percentage() float64 {
+	if g.total == 0 {
+		return 0
+	}
+	return float64(g.tested+g.manual) * 100 / float64(g.total)
+}
+
+// writeCoverageReport prints the coverage of the normative assertions per group
+// and writes the assertions that are neither tested automatically nor manually
+func writeCoverageReport(filename string, assertions, manualAssertions []string, results map[string]result) {
+	groups := make(map[string]*groupCoverage)
+	total := groupCoverage{name: "total"}
+
+	for _, id := range assertions {
+		if id == header[0] {
+			// skip the header
+			continue
+		}
+		name := assertionGroup(id)
+		g, found := groups[name]
+		if !found {
+			g = &groupCoverage{name: name}
+			groups[name] = g
+		}
+		g.total++
+		total.total++
+
+		if _, tested := results[id]; tested {
+			g.tested++
+			total.tested++
+		} else if inSlice(manualAssertions, id) {
+			g.manual++
+			total.manual++
+		} else {
+			g.untested = append(g.untested, id)
+			total.untested = append(total.untested, id)
+		}
+	}
+
+	var names []string
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var records [][]string
+	fmt.Printf("\nCoverage of normative assertions (tested, manual, untested):\n")
+	for _, name := range append(names, total.name) {
+		g := &total
+		if name != total.name {
+			g = groups[name]
+		}
+		fmt.Printf("  %-24s %3d %3d %3d  %5.1f%%\n", g.name, g.tested, g.manual, len(g.untested), g.percentage())
+
+		if name != total.name {
+			sort.Strings(g.untested)
+			for _, id := range g.untested {
+				records = append(records, []string{g.name, id})
+			}
+		}
+	}
+	fmt.Printf("Untested assertions are written to %s\n\n", filename)
+
+	writeCSVFile(filename, coverageHeader, records)
+}
@@ -0,0 +1,198 @@
+package directory
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const diffReportFile = "tdd-diff.csv"
+
+var diffHeader = []string{"ID", "Change", "Before", "After", "Comment"}
+
+// changes between two reports
+const (
+	changeNewlyFailing    = "newly-failing"
+	changeNewlyPassing    = "newly-passing"
+	changeNewlySkipped    = "newly-skipped"
+	changeNewlyErroring   = "newly-erroring"
+	changeSubtestsChanged = "subtests-changed"
+	changeRemoved         = "removed"
+)
+
+// reportRecord is the status of an assertion and the subtests covering it, as read from a report
+type reportRecord struct {
+	status   string
+	subtests []string
+}
+
+// reportChange is the difference of an assertion between two reports
+type reportChange struct {
+	id      string
+	change  string
+	before  string
+	after   string
+	comment string
+}
+
+// readReport reads the records of a CSV or JSON report, keyed by assertion ID
+func readReport(filename string) (map[string]reportRecord, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[string]reportRecord)
+	if filepath.Ext(filename) == ".json" {
+		var report jsonReport
+		err = json.Unmarshal(b, &report)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding JSON report: %s", err)
+		}
+		for _, a := range report.Assertions {
+			if a.Suite {
+				// like the CSV report
+				continue
+			}
+			var subtests []string
+			for _, s := range a.Subtests {
+				subtests = append(subtests, s.Name)
+			}
+			sort.Strings(subtests)
+			records[a.ID] = reportRecord{status: a.Status, subtests: subtests}
+		}
+		return records, nil
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(string(b))).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV report: %s", err)
+	}
+	for i, row := range rows {
+		if i == 0 || len(row) < 3 {
+			// skip the header
+			continue
+		}
+		// comment has the form: failed:TestA/x errored:TestB/y skipped:TestC/z passed:TestD/w
+		var subtests []string
+		for _, field := range strings.Fields(row[2]) {
+			parts := strings.SplitN(field, ":", 2)
+			if len(parts) == 2 && subtestStatus[parts[0]] != "" {
+				subtests = append(subtests, parts[1])
+			}
+		}
+		sort.Strings(subtests)
+		records[row[0]] = reportRecord{status: row[1], subtests: subtests}
+	}
+	return records, nil
+}
+
+// diffReports compares the assertions of two reports, sorted by assertion ID
+func diffReports(before, after map[string]reportRecord) []reportChange {
+	ids := make(map[string]bool)
+	for id := range before {
+		ids[id] = true
+	}
+	for id := range after {
+		ids[id] = true
+	}
+
+	var changes []reportChange
+	for id := range ids {
+		b, inBefore := before[id]
+		a, inAfter := after[id]
+		change := reportChange{id: id, before: b.status, after: a.status}
+
+		switch {
+		case !inAfter:
+			change.change = changeRemoved
+		case a.status == b.status:
+			added, removed := subtestsDiff(b.subtests, a.subtests)
+			if len(added) == 0 && len(removed) == 0 {
+				continue
+			}
+			change.change = changeSubtestsChanged
+			var details []string
+			if len(added) > 0 {
+				details = append(details, fmt.Sprint("added:", strings.Join(added, " added:")))
+			}
+			if len(removed) > 0 {
+				details = append(details, fmt.Sprint("removed:", strings.Join(removed, " removed:")))
+			}
+			change.comment = strings.Join(details, " ")
+		case a.status == "fail":
+			change.change = changeNewlyFailing
+		case a.status == "pass":
+			change.change = changeNewlyPassing
+		case a.status == "error":
+			change.change = changeNewlyErroring
+		default:
+			change.change = changeNewlySkipped
+		}
+		if !inBefore && change.comment == "" {
+			change.comment = "new assertion"
+		}
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].id < changes[j].id
+	})
+	return changes
+}
+
+// subtestsDiff returns the subtests that are only in after (added) and only in before (removed)
+func subtestsDiff(before, after []string) (added, removed []string) {
+	for _, s := range after {
+		if !inSlice(before, s) {
+			added = append(added, s)
+		}
+	}
+	for _, s := range before {
+		if !inSlice(after, s) {
+			removed = append(removed, s)
+		}
+	}
+	return added, removed
+}
+
+// writeDiffReport compares two reports, prints and writes the changes and returns the number of regressions.
+// A regression is an assertion that is newly failing.
+func writeDiffReport(filename, beforeFile, afterFile string) (regressions int) {
+	before, err := readReport(beforeFile)
+	if err != nil {
+		fmt.Printf("Error reading report %s: %s\n", beforeFile, err)
+		os.Exit(1)
+	}
+	after, err := readReport(afterFile)
+	if err != nil {
+		fmt.Printf("Error reading report %s: %s\n", afterFile, err)
+		os.Exit(1)
+	}
+
+	changes := diffReports(before, after)
+
+	var records [][]string
+	fmt.Printf("Changes from %s to %s:\n", beforeFile, afterFile)
+	for _, c := range changes {
+		if c.change == changeNewlyFailing {
+			regressions++
+		}
+		records = append(records, []string{c.id, c.change, c.before, c.after, c.comment})
+		fmt.Printf("%-18s %s (%s -> %s) %s\n", c.change, c.id, c.before, c.after, c.comment)
+	}
+	fmt.Printf("%d changes, %d regressions\n", len(changes), regressions)
+
+	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		fmt.Printf("Error creating report directory: %s\n", err)
+		os.Exit(1)
+	}
+	writeCSVFile(filename, diffHeader, records)
+
+	return regressions
+}
@@ -0,0 +1,185 @@
+package directory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Evaluation and Report Language (EARL) 1.0 (https://www.w3.org/TR/EARL10-Schema/)
+const (
+	earlNamespace    = "http://www.w3.org/ns/earl#"
+	dctermsNamespace = "http://purl.org/dc/terms/"
+	doapNamespace    = "http://usefulinc.com/ns/doap#"
+	xsdNamespace     = "http://www.w3.org/2001/XMLSchema#"
+	// assertion IDs are anchors in the specification
+	specNamespace = "https://www.w3.org/TR/wot-discovery/#"
+	earlAssertor  = "https://github.com/farshidtz/wot-discovery-testing"
+)
+
+// earlAssertion is a single assertion result, as reported in EARL
+type earlAssertion struct {
+	id          string
+	outcome     string
+	description string
+	subtests    []string
+}
+
+// earlAssertions converts the results to EARL assertions, sorted by ID
+func earlAssertions(results map[string]result) []earlAssertion {
+	var assertions []earlAssertion
+	for id, r := range results {
+		// same status and comment as the csv report
+		record := resultToCSVRecord(id, r)
+
+		var subtests []string
+		subtests = append(subtests, r.failed...)
+		subtests = append(subtests, r.errored...)
+		subtests = append(subtests, r.skipped...)
+		subtests = append(subtests, r.passed...)
+
+		assertions = append(assertions, earlAssertion{
+			id:          id,
+			outcome:     earlOutcome(record[1]),
+			description: record[2],
+			subtests:    subtests,
+		})
+	}
+	sort.Slice(assertions, func(i, j int) bool {
+		return assertions[i].id < assertions[j].id
+	})
+	return assertions
+}
+
+// earlOutcome maps the report status to an EARL outcome value
+func earlOutcome(status string) string {
+	switch status {
+	case "pass":
+		return "earl:passed"
+	case "fail":
+		return "earl:failed"
+	case "error":
+		return "earl:cantTell"
+	default:
+		return "earl:untested"
+	}
+}
+
+func writeEARLTurtleReport(filename, subject string, results map[string]result) {
+	var b strings.Builder
+	date := time.Now().UTC().Format(time.RFC3339)
+
+	fmt.Fprintf(&b, "@prefix earl: <%s> .\n", earlNamespace)
+	fmt.Fprintf(&b, "@prefix dct: <%s> .\n", dctermsNamespace)
+	fmt.Fprintf(&b, "@prefix doap: <%s> .\n", doapNamespace)
+	fmt.Fprintf(&b, "@prefix xsd: <%s> .\n", xsdNamespace)
+	fmt.Fprintf(&b, "@prefix spec: <%s> .\n\n", specNamespace)
+
+	fmt.Fprintf(&b, "<%s> a earl:Assertor, earl:Software ;\n", earlAssertor)
+	fmt.Fprintf(&b, "\tdoap:name \"WoT Discovery Testing\" .\n\n")
+	fmt.Fprintf(&b, "<%s> a earl:TestSubject .\n", subject)
+
+	for _, a := range earlAssertions(results) {
+		fmt.Fprintf(&b, "\nspec:%s a earl:TestRequirement ;\n", a.id)
+		fmt.Fprintf(&b, "\tdct:identifier %s", turtleString(a.id))
+		for _, s := range a.subtests {
+			fmt.Fprintf(&b, " ;\n\tdct:hasPart [ a earl:TestCase ; dct:title %s ]", turtleString(s))
+		}
+		fmt.Fprintf(&b, " .\n\n")
+
+		fmt.Fprintf(&b, "[] a earl:Assertion ;\n")
+		fmt.Fprintf(&b, "\tearl:assertedBy <%s> ;\n", earlAssertor)
+		fmt.Fprintf(&b, "\tearl:subject <%s> ;\n", subject)
+		fmt.Fprintf(&b, "\tearl:test spec:%s ;\n", a.id)
+		fmt.Fprintf(&b, "\tearl:mode earl:automatic ;\n")
+		fmt.Fprintf(&b, "\tearl:result [\n")
+		fmt.Fprintf(&b, "\t\ta earl:TestResult ;\n")
+		fmt.Fprintf(&b, "\t\tearl:outcome %s ;\n", a.outcome)
+		fmt.Fprintf(&b, "\t\tdct:description %s ;\n", turtleString(a.description))
+		fmt.Fprintf(&b, "\t\tdct:date \"%s\"^^xsd:dateTime\n", date)
+		fmt.Fprintf(&b, "\t] .\n")
+	}
+
+	err := os.WriteFile(filename, []byte(b.String()), 0644)
+	if err != nil {
+		fmt.Printf("Error writing the EARL report: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func writeEARLJSONLDReport(filename, subject string, results map[string]result) {
+	date := time.Now().UTC().Format(time.RFC3339)
+
+	graph := []mapAny{
+		{
+			"@id":       earlAssertor,
+			"@type":     []string{"earl:Assertor", "earl:Software"},
+			"doap:name": "WoT Discovery Testing",
+		},
+		{
+			"@id":   subject,
+			"@type": "earl:TestSubject",
+		},
+	}
+
+	for _, a := range earlAssertions(results) {
+		var parts []mapAny
+		for _, s := range a.subtests {
+			parts = append(parts, mapAny{
+				"@type":     "earl:TestCase",
+				"dct:title": s,
+			})
+		}
+
+		graph = append(graph, mapAny{
+			"@type":           "earl:Assertion",
+			"earl:assertedBy": mapAny{"@id": earlAssertor},
+			"earl:subject":    mapAny{"@id": subject},
+			"earl:test": mapAny{
+				"@id":            "spec:" + a.id,
+				"@type":          "earl:TestRequirement",
+				"dct:identifier": a.id,
+				"dct:hasPart":    parts,
+			},
+			"earl:mode": mapAny{"@id": "earl:automatic"},
+			"earl:result": mapAny{
+				"@type":           "earl:TestResult",
+				"earl:outcome":    mapAny{"@id": a.outcome},
+				"dct:description": a.description,
+				"dct:date":        mapAny{"@value": date, "@type": "xsd:dateTime"},
+			},
+		})
+	}
+
+	document := mapAny{
+		"@context": mapAny{
+			"earl": earlNamespace,
+			"dct":  dctermsNamespace,
+			"doap": doapNamespace,
+			"xsd":  xsdNamespace,
+			"spec": specNamespace,
+		},
+		"@graph": graph,
+	}
+
+	b, err := json.MarshalIndent(document, "", "\t")
+	if err != nil {
+		fmt.Printf("Error encoding the EARL report: %s\n", err)
+		os.Exit(1)
+	}
+
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		fmt.Printf("Error writing the EARL report: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// turtleString returns s as a quoted Turtle string literal
+func turtleString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
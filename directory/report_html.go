@@ -0,0 +1,241 @@
+package directory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HTML report page, self-contained so it can be viewed offline
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>WoT Discovery Testing Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h2 { margin-top: 1.5em; border-bottom: 1px solid #ccc; }
+details { margin: 0.3em 0 0.3em 1em; }
+summary { cursor: pointer; }
+pre { background: #f5f5f5; padding: 0.5em; overflow-x: auto; white-space: pre-wrap; }
+.status { display: inline-block; min-width: 4em; padding: 0 0.3em; border-radius: 3px; text-align: center; font-family: monospace; color: #fff; }
+.pass, .passed { background: #2e7d32; }
+.fail, .failed { background: #c62828; }
+.error, .errored { background: #ef6c00; }
+.null, .skipped { background: #757575; }
+.counts { color: #555; font-size: 0.9em; }
+.warning { color: #c62828; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>WoT Discovery Testing Report</h1>
+<p>Directory: <code>{{.Subject}}</code><br>Assertions: {{.Catalog}}{{if .TLS}}<br>TLS: {{.TLS}}{{end}}<br>Generated: {{.Date}}</p>
+<p class="counts">{{.Pass}} pass, {{.Fail}} fail, {{.Error}} error, {{.Null}} null</p>
+{{if .Config}}<details>
+<summary>Configuration</summary>
+<table>{{range .Config}}<tr><td><code>{{.Name}}</code></td><td><code>{{.Value}}</code></td><td class="counts">{{.Source}}</td></tr>{{end}}</table>
+</details>{{end}}
+{{range .Groups}}{{template "group" .}}{{end}}
+{{if .SuiteGroups}}<h1>Suite assertions</h1>
+<p class="counts">Checked by this test suite, not assertions of the specification. They are not counted above.</p>
+{{range .SuiteGroups}}{{template "group" .}}{{end}}
+{{end}}
+</body>
+</html>
+{{define "group"}}
+<h2>{{.Name}} <span class="counts">({{.Pass}} pass, {{.Fail}} fail, {{.Error}} error, {{.Null}} null)</span></h2>
+{{range .Assertions}}
+<details>
+<summary><span class="status {{.Status}}">{{.Status}}</span> {{.ID}}{{if .Outcome}} <span class="counts">({{.Outcome}})</span>{{end}}{{if .Rollup}} <span class="counts">roll-up:</span> <span class="status {{.Rollup}}">{{.Rollup}}</span>{{end}}{{if .Inconsistent}} <span class="warning">passed while a child assertion failed</span>{{end}}</summary>
+{{if .Children}}<p class="counts">Children: {{.Children}}</p>{{end}}
+{{if .Waiver}}<p class="counts">Waiver: {{.Waiver}}</p>{{end}}
+{{range .Subtests}}
+<details>
+<summary><span class="status {{.Status}}">{{.Status}}</span> {{.Name}} <span class="counts">{{.Elapsed}}</span></summary>
+{{if .Messages}}<h4>Messages</h4>{{range .Messages}}<pre>{{.}}</pre>{{end}}{{end}}
+{{if .HAREntries}}<p class="counts">Traffic: entries {{.HAREntries}} of the HAR file</p>{{end}}
+{{range .Exchanges}}<h4>Request</h4><pre>{{.Request}}</pre><h4>Response</h4><pre>{{.Response}}</pre>{{end}}
+</details>
+{{end}}
+</details>
+{{end}}
+{{end}}`))
+
+type htmlReport struct {
+	Subject                 string
+	Catalog                 string
+	TLS                     *tlsInfo
+	Config                  []configEntry
+	Date                    string
+	Pass, Fail, Error, Null int
+	Groups                  []*htmlGroup
+	SuiteGroups             []*htmlGroup // of the suite assertions, not counted in the totals
+}
+
+type htmlGroup struct {
+	Name                    string
+	Pass, Fail, Error, Null int
+	Assertions              []htmlAssertion
+}
+
+type htmlAssertion struct {
+	ID       string
+	Status   string
+	Outcome  string // compared with the waivers, e.g. expected fail
+	Waiver   string
+	Subtests []htmlSubtest
+	// roll-up of the child assertions
+	Rollup       string
+	Children     string
+	Inconsistent bool
+}
+
+type htmlSubtest struct {
+	Name       string
+	Status     string
+	Elapsed    string
+	Messages   []string
+	Exchanges  []htmlExchange
+	HAREntries string // positions in the HAR file
+}
+
+type htmlExchange struct {
+	Request  string
+	Response string
+}
+
+func writeHTMLReport(filename, subject, catalog string, connection *tlsInfo, config []configEntry, results map[string]result, rollups []rollup, outcomes map[string]waivedOutcome) {
+	page := htmlReport{
+		Subject: subject,
+		Catalog: catalog,
+		TLS:     connection,
+		Config:  config,
+		Date:    time.Now().UTC().Format(time.RFC3339),
+	}
+
+	var ids []string
+	for id := range results {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	rollupIndex := make(map[string]rollup)
+	for _, r := range rollups {
+		rollupIndex[r.id] = r
+	}
+
+	traffic := harEntryIndexes()
+	groups := make(map[string]*htmlGroup)
+	for _, id := range ids {
+		r := results[id]
+		assertion := htmlAssertion{
+			ID:     id,
+			Status: resultStatus(r),
+		}
+		if o := outcomes[id]; o.expected != "" || o.unexpected {
+			assertion.Outcome = o.describe(assertion.Status)
+			assertion.Waiver = o.justification
+		}
+		if rollup, found := rollupIndex[id]; found {
+			assertion.Rollup = rollup.rollup
+			assertion.Children = rollup.childrenComment()
+			assertion.Inconsistent = rollup.inconsistent
+		}
+		for _, s := range []struct {
+			status string
+			names  []string
+		}{{"failed", r.failed}, {"errored", r.errored}, {"skipped", r.skipped}, {"passed", r.passed}} {
+			for _, name := range s.names {
+				subtest := htmlSubtest{Name: name, Status: s.status, HAREntries: strings.Trim(fmt.Sprint(traffic[name]), "[]")}
+				if d := r.details[name]; d != nil {
+					subtest.Messages = d.messages
+					subtest.Elapsed = d.elapsed.String()
+				}
+				for _, e := range exchanges[name] {
+					subtest.Exchanges = append(subtest.Exchanges, htmlExchange{
+						Request:  formatHTTPMessage(e.method+" "+e.url, e.requestHeader, e.requestBody),
+						Response: formatHTTPMessage(e.status, e.responseHeader, e.responseBody),
+					})
+				}
+				assertion.Subtests = append(assertion.Subtests, subtest)
+			}
+		}
+
+		// group by the assertion prefix, e.g. tdd-things
+		groupName := assertionGroup(id)
+		suite := inSlice(suiteAssertions, id)
+		group, found := groups[groupName]
+		if !found {
+			group = &htmlGroup{Name: groupName}
+			groups[groupName] = group
+			if suite {
+				page.SuiteGroups = append(page.SuiteGroups, group)
+			} else {
+				page.Groups = append(page.Groups, group)
+			}
+		}
+		group.Assertions = append(group.Assertions, assertion)
+
+		switch assertion.Status {
+		case "pass":
+			group.Pass++
+		case "fail":
+			group.Fail++
+		case "error":
+			group.Error++
+		default:
+			group.Null++
+		}
+	}
+	for _, group := range page.Groups {
+		page.Pass += group.Pass
+		page.Fail += group.Fail
+		page.Error += group.Error
+		page.Null += group.Null
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		fmt.Printf("Error creating HTML report file: %s\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	err = htmlReportTemplate.Execute(file, page)
+	if err != nil {
+		fmt.Printf("Error writing the HTML report: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// formatHTTPMessage formats the start line, headers and body of an HTTP message
+func formatHTTPMessage(startLine string, header http.Header, body []byte) string {
+	var b strings.Builder
+	b.WriteString(startLine + "\n")
+
+	var keys []string
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			b.WriteString(k + ": " + v + "\n")
+		}
+	}
+
+	if len(body) > 0 {
+		var indented bytes.Buffer
+		if json.Indent(&indented, body, "", "  ") == nil {
+			body = indented.Bytes()
+		}
+		b.WriteString("\n" + string(body))
+	}
+	return b.String()
+}
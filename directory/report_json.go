@@ -0,0 +1,129 @@
+package directory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// jsonReport is the structured report, listing the subtests of each assertion with their details
+type jsonReport struct {
+	Server     string          `json:"server"`
+	Date       string          `json:"date"`
+	Catalog    string          `json:"catalog"` // source of the assertions
+	TLS        *tlsInfo        `json:"tls,omitempty"`
+	Config     []configEntry   `json:"config,omitempty"` // effective configuration of the run
+	Assertions []jsonAssertion `json:"assertions"`
+}
+
+type jsonAssertion struct {
+	ID            string        `json:"id"`
+	Status        string        `json:"status"`
+	Expected      string        `json:"expected,omitempty"` // status expected by a waiver
+	Justification string        `json:"justification,omitempty"`
+	Unexpected    bool          `json:"unexpected"`
+	Suite         bool          `json:"suite,omitempty"` // checked by this test suite, not an assertion of the specification
+	Subtests      []jsonSubtest `json:"subtests"`
+}
+
+type jsonSubtest struct {
+	Name       string   `json:"name"`
+	Status     string   `json:"status"`
+	Elapsed    float64  `json:"elapsed"` // seconds
+	Messages   []string `json:"messages,omitempty"`
+	SkipReason string   `json:"skipReason,omitempty"`
+	HAREntries []int    `json:"harEntries,omitempty"` // positions of the exchanges of the subtest in the HAR file
+}
+
+func writeJSONReport(filename, subject, catalog string, connection *tlsInfo, config []configEntry, results map[string]result, outcomes map[string]waivedOutcome) {
+	report := jsonReport{
+		Server:     subject,
+		Date:       time.Now().UTC().Format(time.RFC3339),
+		Catalog:    catalog,
+		TLS:        connection,
+		Config:     config,
+		Assertions: []jsonAssertion{},
+	}
+
+	traffic := harEntryIndexes()
+	for id, r := range results {
+		assertion := jsonAssertion{
+			ID:            id,
+			Status:        resultStatus(r),
+			Expected:      outcomes[id].expected,
+			Justification: outcomes[id].justification,
+			Unexpected:    outcomes[id].unexpected,
+			Suite:         inSlice(suiteAssertions, id),
+		}
+		for _, s := range []struct {
+			status string
+			names  []string
+		}{{"failed", r.failed}, {"errored", r.errored}, {"skipped", r.skipped}, {"passed", r.passed}} {
+			for _, name := range s.names {
+				subtest := jsonSubtest{Name: name, Status: s.status, HAREntries: traffic[name]}
+				if d := r.details[name]; d != nil {
+					subtest.Elapsed = d.elapsed.Seconds()
+					subtest.Messages = d.messages
+					subtest.SkipReason = d.skipReason
+				}
+				assertion.Subtests = append(assertion.Subtests, subtest)
+			}
+		}
+		report.Assertions = append(report.Assertions, assertion)
+	}
+	sort.Slice(report.Assertions, func(i, j int) bool {
+		return report.Assertions[i].ID < report.Assertions[j].ID
+	})
+
+	b, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		fmt.Printf("Error encoding the JSON report: %s\n", err)
+		os.Exit(1)
+	}
+
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		fmt.Printf("Error writing the JSON report: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// loadJSONResults sets the results from a JSON report, e.g. to write the reports of a previous run again
+func loadJSONResults(filename string) (jsonReport, error) {
+	var report jsonReport
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return report, err
+	}
+	err = json.Unmarshal(b, &report)
+	if err != nil {
+		return report, fmt.Errorf("error decoding JSON report: %s", err)
+	}
+
+	results = make(map[string]result)
+	exchanges = make(map[string][]exchange)
+	for _, a := range report.Assertions {
+		r := result{details: make(map[string]*subtestDetails)}
+		for _, s := range a.Subtests {
+			switch s.Status {
+			case "failed":
+				r.failed = append(r.failed, s.Name)
+			case "errored":
+				r.errored = append(r.errored, s.Name)
+			case "skipped":
+				r.skipped = append(r.skipped, s.Name)
+			default:
+				r.passed = append(r.passed, s.Name)
+			}
+			r.details[s.Name] = &subtestDetails{
+				messages:   s.Messages,
+				elapsed:    time.Duration(s.Elapsed * float64(time.Second)),
+				skipReason: s.SkipReason,
+			}
+		}
+		results[a.ID] = r
+	}
+	return report, nil
+}
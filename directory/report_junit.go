@@ -0,0 +1,115 @@
+package directory
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// JUnit XML elements, as consumed by common CI tools
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	ClassName  string          `xml:"classname,attr"`
+	Time       float64         `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitMessage   `xml:"failure,omitempty"`
+	Error      *junitMessage   `xml:"error,omitempty"`
+	Skipped    *junitMessage   `xml:"skipped,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnitReport writes one testsuite per top-level test and one testcase per reported subtest
+func writeJUnitReport(filename string, subtests []subtest) {
+	var suites junitTestSuites
+	suiteIndex := make(map[string]int)
+
+	for _, s := range subtests {
+		// split TestName/sub_test into suite and case names
+		suiteName, caseName := s.name, s.name
+		if i := strings.Index(s.name, "/"); i != -1 {
+			suiteName, caseName = s.name[:i], s.name[i+1:]
+		}
+
+		i, found := suiteIndex[suiteName]
+		if !found {
+			suites.Suites = append(suites.Suites, junitTestSuite{Name: suiteName})
+			i = len(suites.Suites) - 1
+			suiteIndex[suiteName] = i
+		}
+		suite := &suites.Suites[i]
+
+		testCase := junitTestCase{
+			Name:      caseName,
+			ClassName: suiteName,
+			Time:      s.details.elapsed.Seconds(),
+			Properties: []junitProperty{
+				{Name: "assertions", Value: strings.Join(s.assertions, " ")},
+			},
+		}
+		switch s.status {
+		case "failed":
+			message := "subtest failed"
+			if n := len(s.details.messages); n > 0 {
+				message = s.details.messages[n-1]
+			}
+			testCase.Failure = &junitMessage{Message: message, Text: strings.Join(s.details.messages, "\n")}
+			suite.Failures++
+		case "errored":
+			message, _ := infrastructureError(s.name)
+			testCase.Error = &junitMessage{Message: message, Text: strings.Join(s.details.messages, "\n")}
+			suite.Errors++
+		case "skipped":
+			testCase.Skipped = &junitMessage{Message: s.details.skipReason}
+			suite.Skipped++
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	for _, suite := range suites.Suites {
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+	}
+
+	b, err := xml.MarshalIndent(suites, "", "\t")
+	if err != nil {
+		fmt.Printf("Error encoding the JUnit report: %s\n", err)
+		os.Exit(1)
+	}
+
+	err = os.WriteFile(filename, append([]byte(xml.Header), b...), 0644)
+	if err != nil {
+		fmt.Printf("Error writing the JUnit report: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,101 @@
+package directory
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+)
+
+const combinedReportFile = "tdd-combined.csv"
+
+// loadManualResults reads the verdicts of manually tested assertions, keyed by assertion ID.
+// The file has the same ID, Status and Comment columns as the auto testing report.
+func loadManualResults(filename string) map[string][]string {
+	fmt.Println("Reading manual results from", filename)
+	file, err := os.Open(filename)
+	if err != nil {
+		fmt.Printf("Error opening manual results file: %s\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		fmt.Printf("Error reading manual results file: %s\n", err)
+		os.Exit(1)
+	}
+
+	manualResults := make(map[string][]string)
+	for _, record := range records {
+		if record[0] == header[0] {
+			// skip the header
+			continue
+		}
+		// pad missing columns
+		for len(record) < len(header) {
+			record = append(record, "")
+		}
+		manualResults[record[0]] = record[:len(header)]
+	}
+	return manualResults
+}
+
+// writeCombinedReport merges the auto testing records with the manual results.
+// Auto testing results take precedence over conflicting manual results.
+// Manual assertions without a verdict are added with the null status.
+func writeCombinedReport(filename string, autoRecords [][]string, manualResults map[string][]string, manualAssertions []string) {
+	var combined [][]string
+	tested := make(map[string]bool)
+	for _, record := range autoRecords {
+		combined = append(combined, record)
+		tested[record[0]] = true
+	}
+
+	var conflicts, unlisted []string
+	for id, record := range manualResults {
+		if tested[id] {
+			conflicts = append(conflicts, id)
+			continue
+		}
+		if !inSlice(manualAssertions, id) {
+			unlisted = append(unlisted, id)
+		}
+		combined = append(combined, record)
+	}
+
+	var missing []string
+	for _, id := range manualAssertions {
+		if id == header[0] || tested[id] {
+			continue
+		}
+		if _, found := manualResults[id]; !found {
+			missing = append(missing, id)
+			combined = append(combined, []string{id, "null", "no manual result"})
+			tested[id] = true // listed once
+		}
+	}
+
+	sort.Strings(conflicts)
+	sort.Strings(unlisted)
+	if len(conflicts) > 0 {
+		fmt.Printf("\nError: The following assertions have both auto and manual results, the auto results are kept: %v\n\n",
+			conflicts)
+	}
+	if len(unlisted) > 0 {
+		fmt.Printf("\nWarning: The following manual results are for assertions not in the manual list: %v\n\n",
+			unlisted)
+	}
+	if len(missing) > 0 {
+		fmt.Printf("\nWarning: The following manual assertions have no recorded result: %v\n\n",
+			missing)
+	}
+
+	// sort by id
+	sort.Slice(combined, func(i, j int) bool {
+		return combined[i][0] < combined[j][0]
+	})
+	writeCSVReport(filename, combined)
+}
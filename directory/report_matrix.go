@@ -0,0 +1,122 @@
+package directory
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// names of the reports of a run against several targets, in the report directory
+const (
+	matrixReportFile     = "tdd-matrix.csv"
+	matrixHTMLReportFile = "tdd-matrix.html"
+)
+
+// matrixRow is the status of an assertion for each target, in the order of the targets.
+// The status is empty if the assertion was not reported for a target.
+type matrixRow struct {
+	ID       string
+	Statuses []string
+	Passing  int // number of targets that pass the assertion
+}
+
+// assertionMatrix returns the statuses of the assertions of the catalogs and those reported for any target,
+// sorted by assertion ID
+func assertionMatrix(names []string, assertions []string, reports map[string]map[string]reportRecord) []matrixRow {
+	ids := make(map[string]bool)
+	for _, id := range assertions {
+		if id != header[0] {
+			ids[id] = true
+		}
+	}
+	for _, records := range reports {
+		for id := range records {
+			ids[id] = true
+		}
+	}
+
+	var rows []matrixRow
+	for id := range ids {
+		row := matrixRow{ID: id}
+		for _, name := range names {
+			status := reports[name][id].status
+			if status == "pass" {
+				row.Passing++
+			}
+			row.Statuses = append(row.Statuses, status)
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].ID < rows[j].ID
+	})
+	return rows
+}
+
+func writeMatrixReport(filename string, names []string, rows []matrixRow) {
+	header := append(append([]string{"ID"}, names...), "Passing")
+	var records [][]string
+	for _, row := range rows {
+		records = append(records, append(append([]string{row.ID}, row.Statuses...), strconv.Itoa(row.Passing)))
+	}
+	writeCSVFile(filename, header, records)
+}
+
+// HTML matrix page, with the styles of the HTML report
+var htmlMatrixTemplate = template.Must(template.New("matrix").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>WoT Discovery Implementation Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+th, td { padding: 0.2em 0.5em; border-bottom: 1px solid #eee; text-align: left; }
+.status { display: inline-block; min-width: 4em; padding: 0 0.3em; border-radius: 3px; text-align: center; font-family: monospace; color: #fff; }
+.pass { background: #2e7d32; }
+.fail { background: #c62828; }
+.error { background: #ef6c00; }
+.null { background: #757575; }
+.counts { color: #555; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>WoT Discovery Implementation Report</h1>
+<p>Assertions: {{.Catalog}}<br>Generated: {{.Date}}</p>
+<table>
+<tr><th>Assertion</th>{{range .Names}}<th>{{.}}</th>{{end}}<th>Passing</th></tr>
+{{range .Rows}}<tr><td><code>{{.ID}}</code></td>{{range .Statuses}}<td>{{if .}}<span class="status {{.}}">{{.}}</span>{{else}}<span class="counts">not tested</span>{{end}}</td>{{end}}<td class="counts">{{.Passing}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type htmlMatrix struct {
+	Catalog string
+	Date    string
+	Names   []string
+	Rows    []matrixRow
+}
+
+func writeMatrixHTMLReport(filename, catalog string, names []string, rows []matrixRow) {
+	file, err := os.Create(filename)
+	if err != nil {
+		fmt.Printf("Error creating HTML matrix file: %s\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	err = htmlMatrixTemplate.Execute(file, htmlMatrix{
+		Catalog: catalog,
+		Date:    time.Now().UTC().Format(time.RFC3339),
+		Names:   names,
+		Rows:    rows,
+	})
+	if err != nil {
+		fmt.Printf("Error writing the HTML matrix: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,143 @@
+package directory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const rollupReportFile = "tdd-rollup.csv"
+
+var rollupHeader = []string{"ID", "Status", "Rollup", "Comment"}
+
+// rollup is the status of a parent assertion derived from its children.
+// An assertion is the parent of the assertions in the catalog that extend its ID,
+// e.g. tdd-things-list-pagination is the parent of tdd-things-list-pagination-limit.
+type rollup struct {
+	id           string
+	status       string // status of the parent's own tests, empty if not tested
+	rollup       string // status of the parent and all its descendants
+	children     map[string]string
+	inconsistent bool // parent passes while a descendant fails
+}
+
+// assertionParent returns the closest assertion in the catalog which has an ID that is a prefix of the given ID
+func assertionParent(id string, catalog []string) string {
+	parts := strings.Split(id, "-")
+	for i := len(parts) - 1; i > 0; i-- {
+		parent := strings.Join(parts[:i], "-")
+		if inSlice(catalog, parent) {
+			return parent
+		}
+	}
+	return ""
+}
+
+// rollupResults derives the status of each tested parent assertion from its children, sorted by ID
+func rollupResults(catalog []string, results map[string]result) []rollup {
+	children := make(map[string][]string)
+	for _, id := range catalog {
+		if parent := assertionParent(id, catalog); parent != "" {
+			children[parent] = append(children[parent], id)
+		}
+	}
+
+	// status of an assertion and its descendants, empty if none was tested
+	derived := make(map[string]string)
+	var derive func(id string) string
+	derive = func(id string) string {
+		if status, found := derived[id]; found {
+			return status
+		}
+		var statuses []string
+		if r, tested := results[id]; tested {
+			statuses = append(statuses, resultStatus(r))
+		}
+		for _, child := range children[id] {
+			if status := derive(child); status != "" {
+				statuses = append(statuses, status)
+			}
+		}
+		derived[id] = combineStatuses(statuses)
+		return derived[id]
+	}
+
+	var rollups []rollup
+	for parent, ids := range children {
+		r := rollup{
+			id:       parent,
+			rollup:   derive(parent),
+			children: make(map[string]string),
+		}
+		if result, tested := results[parent]; tested {
+			r.status = resultStatus(result)
+		}
+		var childStatuses []string
+		for _, child := range ids {
+			if status := derive(child); status != "" {
+				r.children[child] = status
+				childStatuses = append(childStatuses, status)
+			}
+		}
+		if len(r.children) == 0 {
+			// no tested children
+			continue
+		}
+		r.inconsistent = r.status == "pass" && combineStatuses(childStatuses) == "fail"
+		rollups = append(rollups, r)
+	}
+
+	sort.Slice(rollups, func(i, j int) bool {
+		return rollups[i].id < rollups[j].id
+	})
+	return rollups
+}
+
+// combineStatuses returns fail if any status is fail, error if any is error, null if any is null, and pass otherwise.
+// It returns empty for no statuses.
+func combineStatuses(statuses []string) string {
+	if len(statuses) == 0 {
+		return ""
+	}
+	if inSlice(statuses, "fail") {
+		return "fail"
+	} else if inSlice(statuses, "error") {
+		return "error"
+	} else if inSlice(statuses, "null") {
+		return "null"
+	}
+	return "pass"
+}
+
+// childrenComment lists the children with their status, e.g. fail:tdd-a pass:tdd-b
+func (r rollup) childrenComment() string {
+	var ids []string
+	for id := range r.children {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var details []string
+	for _, id := range ids {
+		details = append(details, r.children[id]+":"+id)
+	}
+	return strings.Join(details, " ")
+}
+
+// writeRollupReport prints the parents that pass while a child fails and writes the roll-up of all parents
+func writeRollupReport(filename string, rollups []rollup) {
+	var records [][]string
+	var inconsistent []string
+	for _, r := range rollups {
+		records = append(records, []string{r.id, r.status, r.rollup, r.childrenComment()})
+		if r.inconsistent {
+			inconsistent = append(inconsistent, r.id)
+		}
+	}
+	if len(inconsistent) > 0 {
+		fmt.Printf("\nWarning: The following assertions passed while some of their child assertions failed: %v\n\n",
+			inconsistent)
+	}
+
+	writeCSVFile(filename, rollupHeader, records)
+}
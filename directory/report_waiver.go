@@ -0,0 +1,167 @@
+package directory
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var waiverHeader = []string{"Pattern", "Expected", "Justification"}
+
+// waiver is the expected status of the assertions or subtests that match a pattern.
+// Patterns are regular expressions matching whole assertion IDs (e.g. tdd-search-xpath.*)
+// or subtest names (e.g. TestSPARQL/federated_search_using_GET).
+type waiver struct {
+	pattern       *regexp.Regexp
+	expected      string // pass, fail or null
+	justification string
+}
+
+// waivedOutcome is the status of an assertion compared with the waivers
+type waivedOutcome struct {
+	expected      string // expected status, empty if not waived
+	justification string
+	unexpected    bool
+}
+
+// subtestStatus maps the subtest statuses to the assertion statuses
+var subtestStatus = map[string]string{"passed": "pass", "failed": "fail", "errored": "error", "skipped": "null"}
+
+// loadWaivers reads the waivers from a CSV file with Pattern, Expected and Justification columns
+func loadWaivers(filename string) []waiver {
+	fmt.Println("Reading waivers from", filename)
+	file, err := os.Open(filename)
+	if err != nil {
+		fmt.Printf("Error opening waivers file: %s\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = len(waiverHeader)
+	records, err := reader.ReadAll()
+	if err != nil {
+		fmt.Printf("Error reading waivers file: %s\n", err)
+		os.Exit(1)
+	}
+	if len(records) > 0 && records[0][0] == waiverHeader[0] {
+		// skip the header
+		records = records[1:]
+	}
+	return parseWaivers(records)
+}
+
+// parseWaivers returns the waivers of records with a pattern, expected status and justification
+func parseWaivers(records [][]string) []waiver {
+	var waivers []waiver
+	for _, record := range records {
+		pattern, err := regexp.Compile("^(?:" + record[0] + ")$")
+		if err != nil {
+			fmt.Printf("Error parsing waiver pattern %s: %s\n", record[0], err)
+			os.Exit(1)
+		}
+		switch record[1] {
+		case "pass", "fail", "error", "null":
+		default:
+			fmt.Printf("Invalid expected status for waiver %s: %s. Expected pass, fail, error or null.\n", record[0], record[1])
+			os.Exit(1)
+		}
+		waivers = append(waivers, waiver{
+			pattern:       pattern,
+			expected:      record[1],
+			justification: record[2],
+		})
+	}
+	return waivers
+}
+
+// matchWaiver returns the first waiver that matches the assertion ID or subtest name
+func matchWaiver(waivers []waiver, name string) *waiver {
+	for i := range waivers {
+		if waivers[i].pattern.MatchString(name) {
+			return &waivers[i]
+		}
+	}
+	return nil
+}
+
+// evaluateWaivers compares the status of an assertion with the waivers of the assertion or its subtests.
+// Without waivers, only a failure or an error is unexpected.
+func evaluateWaivers(waivers []waiver, id string, r result) waivedOutcome {
+	status := resultStatus(r)
+
+	if w := matchWaiver(waivers, id); w != nil {
+		return waivedOutcome{
+			expected:      w.expected,
+			justification: w.justification,
+			unexpected:    status != w.expected,
+		}
+	}
+
+	var outcome waivedOutcome
+	var expectedStatuses, justifications []string
+	for _, s := range []struct {
+		status string
+		names  []string
+	}{{"failed", r.failed}, {"errored", r.errored}, {"skipped", r.skipped}, {"passed", r.passed}} {
+		for _, name := range s.names {
+			actual := subtestStatus[s.status]
+			w := matchWaiver(waivers, name)
+			if w == nil {
+				expectedStatuses = append(expectedStatuses, actual)
+				if actual == "fail" || actual == "error" {
+					outcome.unexpected = true
+				}
+				continue
+			}
+			expectedStatuses = append(expectedStatuses, w.expected)
+			if actual != w.expected {
+				outcome.unexpected = true
+			}
+			if !inSlice(justifications, w.justification) {
+				justifications = append(justifications, w.justification)
+			}
+		}
+	}
+	if len(justifications) > 0 {
+		outcome.expected = combineStatuses(expectedStatuses)
+		outcome.justification = strings.Join(justifications, "; ")
+	}
+	return outcome
+}
+
+// describe returns a short description such as "expected fail" or "unexpected pass"
+func (o waivedOutcome) describe(status string) string {
+	if o.unexpected {
+		return "unexpected " + status
+	}
+	if o.expected != "" {
+		return "expected " + status
+	}
+	return status
+}
+
+// printWaivedOutcomes prints the waived and unexpected outcomes and returns the number of unexpected ones
+func printWaivedOutcomes(outcomes map[string]waivedOutcome) (unexpected int) {
+	var waived, unexpectedIDs []string
+	for id, o := range outcomes {
+		if o.unexpected {
+			unexpectedIDs = append(unexpectedIDs, id)
+		} else if o.expected != "" {
+			waived = append(waived, id)
+		}
+	}
+	sort.Strings(waived)
+	sort.Strings(unexpectedIDs)
+
+	if len(waived) > 0 {
+		fmt.Printf("\nThe following assertions had the expected outcome according to the waivers: %v\n\n", waived)
+	}
+	if len(unexpectedIDs) > 0 {
+		fmt.Printf("\nThe following assertions had an unexpected outcome: %v\n\n", unexpectedIDs)
+	}
+	return len(unexpectedIDs)
+}
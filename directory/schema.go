@@ -0,0 +1,75 @@
+package directory
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// defaultTDSchemaURL is the JSON Schema of TDs from https://github.com/w3c/wot-thing-description/tree/main/validation
+const defaultTDSchemaURL = "https://raw.githubusercontent.com/w3c/wot-thing-description/main/validation/td-json-schema-validation.json"
+
+// loadTDSchema compiles the JSON Schema of TDs from a URL or a file
+func loadTDSchema(location string) (*jsonschema.Schema, error) {
+	var b []byte
+	var err error
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		b, err = downloadSchema(location)
+	} else {
+		b, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	compiler := jsonschema.NewCompiler()
+	err = compiler.AddResource(location, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return compiler.Compile(location)
+}
+
+func downloadSchema(url string) ([]byte, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got status %s", res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// validateTD returns the violations of the schema by a TD, decoded from JSON, as the locations in the TD with their errors
+func validateTD(schema *jsonschema.Schema, td any) []string {
+	err := schema.Validate(td)
+	if err == nil {
+		return nil
+	}
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []string{err.Error()}
+	}
+
+	var problems []string
+	var leaves func(e *jsonschema.ValidationError)
+	leaves = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: %s", "/"+strings.TrimPrefix(e.InstanceLocation, "/"), e.Message))
+		}
+		for _, c := range e.Causes {
+			leaves(c)
+		}
+	}
+	leaves(ve)
+	sort.Strings(problems)
+	return problems
+}
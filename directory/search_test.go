@@ -1,26 +1,20 @@
 package directory
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"net/url"
 	"testing"
-
-	uuid "github.com/satori/go.uuid"
 )
 
 func TestJSONPath(t *testing.T) {
-	if !testJSONPath {
-		t.Skip("Not enabled.")
-	}
+	requireCapability(t, capabilityJSONPath)
 
-	t.Run("filter", func(t *testing.T) {
-		tag := uuid.NewV4().String()
+	runSubtest(t, "filter", func(t *testing.T) {
+		tag := newUUID()
 		var createdTD []mapAny
 		for i := 0; i < 3; i++ {
-			id := "urn:uuid:" + uuid.NewV4().String()
+			id := "urn:uuid:" + newUUID()
 			td := mockedTD(id)
 			// tag the TDs to find later
 			td["tag"] = tag
@@ -30,7 +24,7 @@ func TestJSONPath(t *testing.T) {
 
 		var response *http.Response
 
-		t.Run("submit request", func(t *testing.T) {
+		runSubtest(t, "submit request", func(t *testing.T) {
 			defer report(t,
 				"tdd-search-jsonpath",
 				"tdd-search-jsonpath-method",
@@ -38,9 +32,9 @@ func TestJSONPath(t *testing.T) {
 			)
 
 			// submit the request
-			res, err := http.Get(serverURL + fmt.Sprintf("/search/jsonpath?query=$[?(@.tag=='%s')]", tag))
+			res, err := httpGet(searchURL(serverURL, "jsonpath", http.MethodGet, fmt.Sprintf("$[?(@.tag=='%s')]", tag)), t)
 			if err != nil {
-				t.Fatalf("Error getting TDs: %s", err)
+				fatalf(t, "Error getting TDs: %s", err)
 			}
 			// defer res.Body.Close()
 			response = res
@@ -48,13 +42,13 @@ func TestJSONPath(t *testing.T) {
 
 		body := httpReadBody(response, t)
 
-		t.Run("status code", func(t *testing.T) {
+		runSubtest(t, "status code", func(t *testing.T) {
 			defer report(t, "tdd-search-jsonpath-response")
 
 			assertStatusCode(t, response, http.StatusOK, body)
 		})
 
-		t.Run("content type", func(t *testing.T) {
+		runSubtest(t, "content type", func(t *testing.T) {
 			defer report(t,
 				// "tdd-things-default-representation", // skip non-normative search
 				"tdd-search-jsonpath-response")
@@ -62,17 +56,17 @@ func TestJSONPath(t *testing.T) {
 			assertContentMediaType(t, response, MediaTypeJSON)
 		})
 
-		t.Run("payload", func(t *testing.T) {
+		runSubtest(t, "payload", func(t *testing.T) {
 			defer report(t, "tdd-search-jsonpath-response")
 
 			var filterredTDs []mapAny
 			err := json.Unmarshal(body, &filterredTDs)
 			if err != nil {
-				t.Fatalf("Error decoding page: %s", err)
+				fatalf(t, "Error decoding page: %s", err)
 			}
 
 			if len(createdTD) != len(filterredTDs) {
-				t.Fatalf("Filtering returned %d TDs, expected %d", len(filterredTDs), len(createdTD))
+				fatalf(t, "Filtering returned %d TDs, expected %d", len(filterredTDs), len(createdTD))
 			}
 
 			createdTDsMap := make(map[string]mapAny)
@@ -85,7 +79,7 @@ func TestJSONPath(t *testing.T) {
 			for _, filterredTD := range filterredTDs {
 				id := getID(t, filterredTD)
 				if _, found := createdTDsMap[id]; !found {
-					t.Fatalf("Result does not include the TD with id: %s", id)
+					fatalf(t, "Result does not include the TD with id: %s", id)
 				}
 
 				// remove system-generated attributes
@@ -96,25 +90,25 @@ func TestJSONPath(t *testing.T) {
 		})
 	})
 
-	t.Run("reject bad query", func(t *testing.T) {
+	runSubtest(t, "reject bad query", func(t *testing.T) {
 		var response *http.Response
 
-		t.Run("submit request", func(t *testing.T) {
+		runSubtest(t, "submit request", func(t *testing.T) {
 			defer report(t,
 				"tdd-search-jsonpath",
 				"tdd-search-jsonpath-method",
 				"tdd-search-jsonpath-parameter",
 			)
 
-			res, err := http.Get(serverURL + "/search/jsonpath?query=*/id")
+			res, err := httpGet(searchURL(serverURL, "jsonpath", http.MethodGet, "*/id"), t)
 			if err != nil {
-				t.Fatalf("Error getting TDs: %s", err)
+				fatalf(t, "Error getting TDs: %s", err)
 			}
 			defer res.Body.Close()
 			response = res
 		})
 
-		t.Run("status code", func(t *testing.T) {
+		runSubtest(t, "status code", func(t *testing.T) {
 			defer report(t, "tdd-search-jsonpath-response")
 
 			assertStatusCode(t, response, http.StatusBadRequest, nil)
@@ -123,15 +117,13 @@ func TestJSONPath(t *testing.T) {
 }
 
 func TestXPath(t *testing.T) {
-	if !testXPath {
-		t.Skip("Not enabled.")
-	}
+	requireCapability(t, capabilityXPath)
 
-	t.Run("filter", func(t *testing.T) {
-		tag := uuid.NewV4().String()
+	runSubtest(t, "filter", func(t *testing.T) {
+		tag := newUUID()
 		var createdTD []mapAny
 		for i := 0; i < 3; i++ {
-			id := "urn:uuid:" + uuid.NewV4().String()
+			id := "urn:uuid:" + newUUID()
 			td := mockedTD(id)
 			// tag the TDs to find later
 			td["tag"] = tag
@@ -141,7 +133,7 @@ func TestXPath(t *testing.T) {
 
 		var response *http.Response
 
-		t.Run("submit request", func(t *testing.T) {
+		runSubtest(t, "submit request", func(t *testing.T) {
 			defer report(t,
 				"tdd-search-xpath",
 				"tdd-search-xpath-method",
@@ -149,9 +141,9 @@ func TestXPath(t *testing.T) {
 			)
 
 			// submit the request
-			res, err := http.Get(serverURL + fmt.Sprintf("/search/xpath?query=*[tag='%s']", tag))
+			res, err := httpGet(searchURL(serverURL, "xpath", http.MethodGet, fmt.Sprintf("*[tag='%s']", tag)), t)
 			if err != nil {
-				t.Fatalf("Error getting TDs: %s", err)
+				fatalf(t, "Error getting TDs: %s", err)
 			}
 			// defer res.Body.Close()
 			response = res
@@ -159,13 +151,13 @@ func TestXPath(t *testing.T) {
 
 		body := httpReadBody(response, t)
 
-		t.Run("status code", func(t *testing.T) {
+		runSubtest(t, "status code", func(t *testing.T) {
 			defer report(t, "tdd-search-xpath-response")
 
 			assertStatusCode(t, response, http.StatusOK, body)
 		})
 
-		t.Run("content type", func(t *testing.T) {
+		runSubtest(t, "content type", func(t *testing.T) {
 			defer report(t,
 				// "tdd-things-default-representation", // skip non-normative search
 				"tdd-search-xpath-response")
@@ -173,17 +165,17 @@ func TestXPath(t *testing.T) {
 			assertContentMediaType(t, response, MediaTypeJSON)
 		})
 
-		t.Run("payload", func(t *testing.T) {
+		runSubtest(t, "payload", func(t *testing.T) {
 			defer report(t, "tdd-search-xpath-response")
 
 			var filterredTDs []mapAny
 			err := json.Unmarshal(body, &filterredTDs)
 			if err != nil {
-				t.Fatalf("Error decoding page: %s", err)
+				fatalf(t, "Error decoding page: %s", err)
 			}
 
 			if len(createdTD) != len(filterredTDs) {
-				t.Fatalf("Filtering returned %d TDs, expected %d", len(filterredTDs), len(createdTD))
+				fatalf(t, "Filtering returned %d TDs, expected %d", len(filterredTDs), len(createdTD))
 			}
 
 			createdTDsMap := make(map[string]mapAny)
@@ -196,7 +188,7 @@ func TestXPath(t *testing.T) {
 			for _, filterredTD := range filterredTDs {
 				id := getID(t, filterredTD)
 				if _, found := createdTDsMap[id]; !found {
-					t.Fatalf("Result does not include the TD with id: %s", id)
+					fatalf(t, "Result does not include the TD with id: %s", id)
 				}
 
 				// remove system-generated attributes
@@ -207,25 +199,25 @@ func TestXPath(t *testing.T) {
 		})
 	})
 
-	t.Run("reject bad query", func(t *testing.T) {
+	runSubtest(t, "reject bad query", func(t *testing.T) {
 		var response *http.Response
 
-		t.Run("submit request", func(t *testing.T) {
+		runSubtest(t, "submit request", func(t *testing.T) {
 			defer report(t,
 				"tdd-search-xpath",
 				"tdd-search-xpath-method",
 				"tdd-search-xpath-parameter",
 			)
 
-			res, err := http.Get(serverURL + "/search/xpath?query=$[:].id")
+			res, err := httpGet(searchURL(serverURL, "xpath", http.MethodGet, "$[:].id"), t)
 			if err != nil {
-				t.Fatalf("Error getting TDs: %s", err)
+				fatalf(t, "Error getting TDs: %s", err)
 			}
 			defer res.Body.Close()
 			response = res
 		})
 
-		t.Run("status code", func(t *testing.T) {
+		runSubtest(t, "status code", func(t *testing.T) {
 			defer report(t, "tdd-search-xpath-response")
 
 			assertStatusCode(t, response, http.StatusBadRequest, nil)
@@ -234,6 +226,7 @@ func TestXPath(t *testing.T) {
 }
 
 func TestSPARQL(t *testing.T) {
+	requireCapability(t, capabilitySPARQL)
 
 	const query = `select * { ?s ?p ?o }limit 5`
 	const federatedQuery = `select * {
@@ -244,7 +237,7 @@ func TestSPARQL(t *testing.T) {
 
 	var expectedResult = sparqlResultsSample()
 
-	t.Run("search using GET", func(t *testing.T) {
+	runSubtest(t, "search using GET", func(t *testing.T) {
 		defer report(t,
 			"tdd-search-sparql",
 			"tdd-search-sparql-method-get",
@@ -252,9 +245,9 @@ func TestSPARQL(t *testing.T) {
 		)
 
 		// submit GET request
-		res, err := http.Get(serverURL + "/search/sparql?query=" + url.QueryEscape(query))
+		res, err := httpGet(searchURL(serverURL, "sparql", http.MethodGet, query), t)
 		if err != nil {
-			t.Fatalf("Error solving query SPARQL: %s", err)
+			fatalf(t, "Error solving query SPARQL: %s", err)
 		}
 		body := httpReadBody(res, t)
 
@@ -263,16 +256,16 @@ func TestSPARQL(t *testing.T) {
 		var responseMap mapAny
 		err = json.Unmarshal(body, &responseMap)
 		if err != nil {
-			t.Fatalf("Error decoding response: %s", err)
+			fatalf(t, "Error decoding response: %s", err)
 		}
 
-		t.Log(responseMap)
+		logf(t, "%v", responseMap)
 		delete(responseMap, "results")
 
 		assertEqualTitle(t, expectedResult, responseMap)
 	})
 
-	t.Run("search using POST", func(t *testing.T) {
+	runSubtest(t, "search using POST", func(t *testing.T) {
 		defer report(t,
 			"tdd-search-sparql",
 			"tdd-search-sparql-method-post",
@@ -280,11 +273,11 @@ func TestSPARQL(t *testing.T) {
 		)
 
 		// submit POST request
-		res, err := http.Post(serverURL+"/search/sparql",
+		res, err := httpPost(searchURL(serverURL, "sparql", http.MethodPost, ""),
 			"application/sparql-query",
-			bytes.NewReader([]byte(query)))
+			[]byte(query), t)
 		if err != nil {
-			t.Fatalf("Error solving query SPARQL: %s", err)
+			fatalf(t, "Error solving query SPARQL: %s", err)
 		}
 		body := httpReadBody(res, t)
 
@@ -293,16 +286,17 @@ func TestSPARQL(t *testing.T) {
 		var responseMap mapAny
 		err = json.Unmarshal(body, &responseMap)
 		if err != nil {
-			t.Fatalf("Error decoding response: %s", err)
+			fatalf(t, "Error decoding response: %s", err)
 		}
 
-		t.Log(responseMap)
+		logf(t, "%v", responseMap)
 		delete(responseMap, "results")
 
 		assertEqualTitle(t, expectedResult, responseMap)
 	})
 
-	t.Run("federated search using GET", func(t *testing.T) {
+	runSubtest(t, "federated search using GET", func(t *testing.T) {
+		requireCapability(t, capabilitySPARQLFederation)
 		defer report(t,
 			"tdd-search-sparql",
 			"tdd-search-sparql-method-get",
@@ -311,9 +305,9 @@ func TestSPARQL(t *testing.T) {
 		)
 
 		// submit GET request
-		res, err := http.Get(serverURL + "/search/sparql?query=" + url.QueryEscape(federatedQuery))
+		res, err := httpGet(searchURL(serverURL, "sparql", http.MethodGet, federatedQuery), t)
 		if err != nil {
-			t.Fatalf("Error solving query SPARQL: %s", err)
+			fatalf(t, "Error solving query SPARQL: %s", err)
 		}
 		body := httpReadBody(res, t)
 
@@ -322,21 +316,21 @@ func TestSPARQL(t *testing.T) {
 		var responseMap mapAny
 		err = json.Unmarshal(body, &responseMap)
 		if err != nil {
-			t.Fatalf("Error decoding response: %s", err)
+			fatalf(t, "Error decoding response: %s", err)
 		}
 
-		t.Log(responseMap)
+		logf(t, "%v", responseMap)
 		delete(responseMap, "results")
 
 		assertEqualTitle(t, expectedResult, responseMap)
 	})
 
-	t.Run("HEAD", func(t *testing.T) {
+	runSubtest(t, "HEAD", func(t *testing.T) {
 		defer report(t, "tdd-http-head")
 
-		res, err := httpRequest(http.MethodHead, serverURL+"/search/sparql?query="+url.QueryEscape(query), "", nil)
+		res, err := httpRequest(http.MethodHead, searchURL(serverURL, "sparql", http.MethodHead, query), "", nil, t)
 		if err != nil {
-			t.Fatalf("Error solving query SPARQL: %s", err)
+			fatalf(t, "Error solving query SPARQL: %s", err)
 		}
 		body := httpReadBody(res, t)
 		assertStatusCode(t, res, http.StatusOK, body)
@@ -0,0 +1,211 @@
+//go:build !conformance
+// +build !conformance
+
+package directory
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestProtectedOperations checks the operations found to require credentials in directory TDs.
+func TestProtectedOperations(t *testing.T) {
+	base, url := selfDescription.base, selfDescription.url
+	defer func() { selfDescription.base, selfDescription.url = base, url }()
+	selfDescription.base, selfDescription.url = "", "http://localhost:8081/.well-known/wot"
+
+	definitions := mapAny{
+		"nosec_sc":  mapAny{"scheme": "nosec"},
+		"basic_sc":  mapAny{"scheme": "basic"},
+		"bearer_sc": mapAny{"scheme": "bearer"},
+		"combo_sc":  mapAny{"scheme": "combo", "oneOf": []any{"basic_sc", "bearer_sc"}},
+	}
+	td := mapAny{
+		"securityDefinitions": definitions,
+		"security":            "nosec_sc",
+		"properties": mapAny{
+			"things": mapAny{"forms": []any{mapAny{"href": "/things"}}},
+		},
+		"actions": mapAny{
+			"createThing": mapAny{"forms": []any{
+				mapAny{"href": "/things/{id}", "htv:methodName": "PUT", "security": "combo_sc"},
+				mapAny{"href": "things/{id}", "htv:methodName": "PATCH", "security": []any{"bearer_sc"}},
+			}},
+		},
+		"events": mapAny{
+			"thingCreated": mapAny{"forms": []any{mapAny{"href": "/events/thing_created", "security": "nosec_sc"}}},
+		},
+	}
+
+	operations, err := protectedOperations(td)
+	if err != nil {
+		t.Fatalf("Error finding protected operations: %s", err)
+	}
+	expected := []protectedOperation{
+		{
+			name:        "createThing form 0",
+			affordance:  "createThing",
+			method:      http.MethodPut,
+			href:        "http://localhost:8081/things/{id}",
+			contentType: MediaTypeThingDescription,
+			schemes:     []mapAny{definitions["basic_sc"].(mapAny), definitions["bearer_sc"].(mapAny)},
+		},
+		{
+			name:        "createThing form 1",
+			affordance:  "createThing",
+			method:      http.MethodPatch,
+			href:        "http://localhost:8081/.well-known/things/{id}",
+			contentType: MediaTypeMergePatch,
+			schemes:     []mapAny{definitions["bearer_sc"].(mapAny)},
+		},
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Fatalf("Expected %v, got %v", expected, operations)
+	}
+
+	// the security of the TD applies to forms without their own
+	td["security"] = "basic_sc"
+	operations, err = protectedOperations(td)
+	if err != nil {
+		t.Fatalf("Error finding protected operations: %s", err)
+	}
+	if len(operations) != 3 || operations[2].name != "things" || operations[2].method != http.MethodGet {
+		t.Fatalf("Expected things to be protected by the security of the TD, got %v", operations)
+	}
+
+	td["security"] = "undefined_sc"
+	if _, err := protectedOperations(td); err == nil {
+		t.Fatalf("Expected an error for an undefined security definition")
+	}
+}
+
+// TestSecuritySchemes checks the resolution of security definitions.
+func TestSecuritySchemes(t *testing.T) {
+	basic, bearer, apikey := mapAny{"scheme": "basic"}, mapAny{"scheme": "bearer"}, mapAny{"scheme": "apikey"}
+	definitions := mapAny{
+		"basic_sc":  basic,
+		"bearer_sc": bearer,
+		"apikey_sc": apikey,
+		"one_sc":    mapAny{"scheme": "combo", "oneOf": []any{"basic_sc", "bearer_sc"}},
+		"all_sc":    mapAny{"scheme": "combo", "allOf": []any{"apikey_sc", "one_sc"}},
+		"bad_sc":    mapAny{"scheme": "combo", "oneOf": []any{"missing_sc"}},
+	}
+
+	cases := []struct {
+		name     string
+		security any
+		expected []mapAny
+		err      bool
+	}{
+		{"string", "basic_sc", []mapAny{basic}, false},
+		{"array", []any{"basic_sc", "apikey_sc"}, []mapAny{basic, apikey}, false},
+		{"combo oneOf", "one_sc", []mapAny{basic, bearer}, false},
+		{"nested combo allOf", "all_sc", []mapAny{apikey, basic, bearer}, false},
+		{"none", nil, nil, false},
+		{"undefined", "missing_sc", nil, true},
+		{"undefined in combo", "bad_sc", nil, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			schemes, err := securitySchemes(c.security, definitions)
+			if c.err {
+				if err == nil {
+					t.Fatalf("Expected an error, got %v", schemes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error resolving schemes: %s", err)
+			}
+			if !reflect.DeepEqual(schemes, c.expected) {
+				t.Fatalf("Expected %v, got %v", c.expected, schemes)
+			}
+		})
+	}
+}
+
+// TestWrongCredentials checks the made-up credentials sent for each security scheme.
+func TestWrongCredentials(t *testing.T) {
+	cases := []struct {
+		name    string
+		schemes []mapAny
+		check   func(req *http.Request) bool
+	}{
+		{"basic", []mapAny{{"scheme": "basic"}}, func(req *http.Request) bool {
+			_, password, ok := req.BasicAuth()
+			return ok && strings.HasPrefix(password, "wrong-")
+		}},
+		{"bearer", []mapAny{{"scheme": "bearer"}}, func(req *http.Request) bool {
+			return strings.HasPrefix(req.Header.Get("Authorization"), "Bearer wrong-")
+		}},
+		{"oauth2", []mapAny{{"scheme": "oauth2"}}, func(req *http.Request) bool {
+			return strings.HasPrefix(req.Header.Get("Authorization"), "Bearer wrong-")
+		}},
+		{"apikey in header", []mapAny{{"scheme": "apikey", "in": "header", "name": "X-Api-Key"}}, func(req *http.Request) bool {
+			return strings.HasPrefix(req.Header.Get("X-Api-Key"), "wrong-")
+		}},
+		{"apikey in query", []mapAny{{"scheme": "apikey", "in": "query", "name": "key"}}, func(req *http.Request) bool {
+			return strings.HasPrefix(req.URL.Query().Get("key"), "wrong-") && req.URL.Query().Get("limit") == "1"
+		}},
+		{"apikey in cookie", []mapAny{{"scheme": "apikey", "in": "cookie", "name": "session"}}, func(req *http.Request) bool {
+			cookie, err := req.Cookie("session")
+			return err == nil && strings.HasPrefix(cookie.Value, "wrong-")
+		}},
+		{"first supported scheme", []mapAny{{"scheme": "apikey"}, {"scheme": "digest"}, {"scheme": "basic"}}, func(req *http.Request) bool {
+			_, _, ok := req.BasicAuth()
+			return ok
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			credentials := wrongCredentials(c.schemes)
+			if credentials == nil {
+				t.Fatalf("Expected credentials for %v", c.schemes)
+			}
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost:8081/things?limit=1", nil)
+			credentials(req)
+			if !c.check(req) {
+				t.Fatalf("Unexpected credentials: %v %s", req.Header, req.URL)
+			}
+		})
+	}
+
+	if wrongCredentials([]mapAny{{"scheme": "nosec"}, {"scheme": "apikey", "in": "body", "name": "key"}}) != nil {
+		t.Fatalf("Expected no credentials for schemes without a supported location")
+	}
+}
+
+// TestAuthenticateChallenge checks the parsing of WWW-Authenticate challenges.
+func TestAuthenticateChallenge(t *testing.T) {
+	basic, bearer := []mapAny{{"scheme": "basic"}}, []mapAny{{"scheme": "bearer"}}
+	cases := []struct {
+		name    string
+		values  []string
+		schemes []mapAny
+		valid   bool
+	}{
+		{"scheme only", []string{"Basic"}, basic, true},
+		{"case-insensitive", []string{`basic realm="wot"`}, basic, true},
+		{"auth-params", []string{`Bearer realm="wot", error="invalid_token"`}, bearer, true},
+		{"several challenges", []string{`Basic realm="wot", Bearer realm="wot"`}, bearer, true},
+		{"several headers", []string{`Basic realm="wot"`, `Bearer`}, bearer, true},
+		{"oauth2 as bearer", []string{`Bearer scope="read"`}, []mapAny{{"scheme": "oauth2"}}, true},
+		{"comma in quoted string", []string{`Digest realm="a, Bearer b", nonce="x"`}, bearer, false},
+		{"other scheme", []string{`Basic realm="wot"`}, bearer, false},
+		{"no header", nil, basic, false},
+		{"no standard challenge", []string{"ApiKey"}, []mapAny{{"scheme": "apikey"}}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := checkAuthenticateChallenge(c.values, c.schemes)
+			if c.valid && err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if !c.valid && err == nil {
+				t.Fatalf("Expected an error for %v", c.values)
+			}
+		})
+	}
+}
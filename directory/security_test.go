@@ -0,0 +1,382 @@
+package directory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// protectedOperation is a form of the directory TD whose security requires credentials
+type protectedOperation struct {
+	name        string // of the affordance, with the form index if it has several forms
+	affordance  string
+	method      string
+	href        string
+	contentType string
+	schemes     []mapAny // the security definitions that apply to the form
+}
+
+// searchQueries are valid queries for the search affordances, so that only the credentials are missing
+var searchQueries = map[string]string{
+	affordanceSearchJSONPath: "$",
+	affordanceSearchXPath:    "*",
+	affordanceSearchSPARQL:   "ASK {}",
+}
+
+func TestSecurity(t *testing.T) {
+	if selfDescription.td == nil {
+		skipf(t, "No directory TD at %s to read the security definitions from", selfDescription.url)
+	}
+	if strings.HasPrefix(serverURL, "coap") {
+		skipf(t, "Security is not supported over CoAP")
+	}
+	operations, err := protectedOperations(selfDescription.td)
+	if err != nil {
+		fatalf(t, "Invalid security in the directory TD: %s", err)
+	}
+	if len(operations) == 0 {
+		skipf(t, "The directory TD declares no security for its operations")
+	}
+
+	for _, op := range operations {
+		op := op
+		runSubtest(t, op.name, func(t *testing.T) {
+
+			runSubtest(t, "without credentials", func(t *testing.T) {
+				res, err := securityRequest(t, op, nil)
+				if err != nil {
+					fatalf(t, "Error requesting: %s", err)
+				}
+				defer res.Body.Close()
+				body := httpReadBody(res, t)
+
+				runSubtest(t, "status", func(t *testing.T) {
+					defer report(t, "tdd-security-enforced")
+					assertStatusCode(t, res, http.StatusUnauthorized, body)
+				})
+
+				runSubtest(t, "challenge", func(t *testing.T) {
+					defer report(t, "tdd-security-challenge")
+					assertAuthenticateChallenge(t, res, op.schemes)
+				})
+
+				runSubtest(t, "response", func(t *testing.T) {
+					defer report(t, "tdd-http-error-response")
+					if len(body) == 0 {
+						fatalf(t, "Expected an error response body, got none")
+					}
+					assertErrorResponse(t, res, body)
+				})
+			})
+
+			runSubtest(t, "wrong credentials", func(t *testing.T) {
+				credentials := wrongCredentials(op.schemes)
+				if credentials == nil {
+					skipf(t, "No scheme of %s to send wrong credentials for", op.name)
+				}
+				res, err := securityRequest(t, op, credentials)
+				if err != nil {
+					fatalf(t, "Error requesting: %s", err)
+				}
+				defer res.Body.Close()
+				body := httpReadBody(res, t)
+
+				runSubtest(t, "status", func(t *testing.T) {
+					defer report(t, "tdd-security-credentials")
+					recordExchange(t, res, body)
+					if res.StatusCode != http.StatusUnauthorized && res.StatusCode != http.StatusForbidden {
+						logf(t, "Body: %s", prettifyJSON(body))
+						fatalf(t, "Expected status %d or %d, got: %d", http.StatusUnauthorized, http.StatusForbidden, res.StatusCode)
+					}
+				})
+
+				runSubtest(t, "response", func(t *testing.T) {
+					defer report(t, "tdd-http-error-response")
+					if len(body) == 0 {
+						fatalf(t, "Expected an error response body, got none")
+					}
+					assertErrorResponse(t, res, body)
+				})
+			})
+		})
+	}
+}
+
+// protectedOperations returns the forms of the TD that require credentials, using the security of
+// the form if set, or the security of the TD
+func protectedOperations(td mapAny) ([]protectedOperation, error) {
+	definitions, _ := td["securityDefinitions"].(mapAny)
+	base := selfDescription.base
+	if base == "" {
+		base = selfDescription.url
+	}
+
+	var operations []protectedOperation
+	for _, kind := range []string{"properties", "actions", "events"} {
+		affordances, _ := td[kind].(mapAny)
+		for name, a := range affordances {
+			affordance, _ := a.(mapAny)
+			forms, _ := affordance["forms"].([]any)
+			for i, f := range forms {
+				form, _ := f.(mapAny)
+				href, _ := form["href"].(string)
+				if href == "" {
+					continue
+				}
+				security, found := form["security"]
+				if !found {
+					security = td["security"]
+				}
+				schemes, err := securitySchemes(security, definitions)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s", name, err)
+				}
+				if !requiresCredentials(schemes) {
+					continue
+				}
+
+				op := protectedOperation{
+					name:        name,
+					affordance:  name,
+					method:      formMethod(kind, form),
+					href:        resolveReference(base, href),
+					contentType: MediaTypeThingDescription,
+					schemes:     schemes,
+				}
+				if len(forms) > 1 {
+					op.name = fmt.Sprintf("%s form %d", name, i)
+				}
+				if contentType, ok := form["contentType"].(string); ok && op.method != http.MethodGet {
+					op.contentType = contentType
+				}
+				if op.method == http.MethodPatch {
+					op.contentType = MediaTypeMergePatch
+				}
+				operations = append(operations, op)
+			}
+		}
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		return operations[i].name < operations[j].name
+	})
+	return operations, nil
+}
+
+// securitySchemes resolves the names of security definitions, including those combined by combo schemes
+func securitySchemes(security any, definitions mapAny) ([]mapAny, error) {
+	var names []string
+	switch s := security.(type) {
+	case string:
+		names = []string{s}
+	case []any:
+		for _, name := range s {
+			if n, ok := name.(string); ok {
+				names = append(names, n)
+			}
+		}
+	}
+
+	var schemes []mapAny
+	for _, name := range names {
+		definition, ok := definitions[name].(mapAny)
+		if !ok {
+			return nil, fmt.Errorf("undefined security definition %s", name)
+		}
+		if definition["scheme"] == "combo" {
+			combined := definition["oneOf"]
+			if combined == nil {
+				combined = definition["allOf"]
+			}
+			c, err := securitySchemes(combined, definitions)
+			if err != nil {
+				return nil, err
+			}
+			schemes = append(schemes, c...)
+			continue
+		}
+		schemes = append(schemes, definition)
+	}
+	return schemes, nil
+}
+
+func requiresCredentials(schemes []mapAny) bool {
+	for _, scheme := range schemes {
+		if scheme["scheme"] != "nosec" {
+			return true
+		}
+	}
+	return false
+}
+
+// securityRequest sends a request for the operation without the configured credentials,
+// with the wrong credentials set by the given function, if any
+func securityRequest(t *testing.T, op protectedOperation, credentials func(*http.Request)) (*http.Response, error) {
+	id := "urn:uuid:" + newUUID()
+	variables := map[string]string{"id": id}
+	if query, found := searchQueries[op.affordance]; found {
+		variables["query"] = query
+	}
+
+	var b []byte
+	if op.method != http.MethodGet && op.method != http.MethodDelete {
+		b, _ = json.Marshal(mockedTD(id))
+	}
+	req, err := http.NewRequestWithContext(testContext(t), op.method, expandURITemplate(op.href, variables), bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > 0 {
+		req.Header.Set("Content-Type", op.contentType)
+	}
+
+	client := *unauthenticatedClient
+	// the API keys may be set as extra headers
+	if h, ok := client.Transport.(*headerTransport); ok {
+		header := h.header.Clone()
+		for _, scheme := range op.schemes {
+			if scheme["scheme"] == "apikey" && scheme["in"] == "header" {
+				if name, ok := scheme["name"].(string); ok {
+					header.Del(name)
+				}
+			}
+		}
+		client.Transport = &headerTransport{base: h.base, header: header}
+	}
+	if credentials != nil {
+		credentials(req)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		recordInfrastructureError(t, err)
+		return nil, err
+	}
+	return res, nil
+}
+
+// wrongCredentials returns a function that sets made-up credentials for the first scheme that supports it, nil if none does
+func wrongCredentials(schemes []mapAny) func(*http.Request) {
+	wrong := "wrong-" + newUUID()
+	for _, scheme := range schemes {
+		switch scheme["scheme"] {
+		case "basic":
+			return func(req *http.Request) {
+				req.SetBasicAuth("wot-discovery-testing", wrong)
+			}
+		case "bearer", "oauth2":
+			return func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+wrong)
+			}
+		case "apikey":
+			name, _ := scheme["name"].(string)
+			if name == "" {
+				continue
+			}
+			switch scheme["in"] {
+			case "header":
+				return func(req *http.Request) {
+					req.Header.Set(name, wrong)
+				}
+			case "query":
+				return func(req *http.Request) {
+					query := req.URL.Query()
+					query.Set(name, wrong)
+					req.URL.RawQuery = query.Encode()
+				}
+			case "cookie":
+				return func(req *http.Request) {
+					req.AddCookie(&http.Cookie{Name: name, Value: url.QueryEscape(wrong)})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// authenticationSchemes are the schemes of the challenges for the security schemes of TDs
+var authenticationSchemes = map[string]string{
+	"basic":  "Basic",
+	"digest": "Digest",
+	"bearer": "Bearer",
+	"oauth2": "Bearer",
+}
+
+// assertAuthenticateChallenge checks that an unauthorized response has a challenge (RFC 7235, Section 3.1)
+// for one of the security schemes, if they have a standard challenge
+func assertAuthenticateChallenge(t *testing.T, res *http.Response, schemes []mapAny) {
+	t.Helper()
+	if res == nil {
+		fatalf(t, "previous errors")
+	}
+	recordExchange(t, res, nil)
+	err := checkAuthenticateChallenge(res.Header.Values("WWW-Authenticate"), schemes)
+	if err != nil {
+		fatalf(t, "%s", err)
+	}
+}
+
+// checkAuthenticateChallenge checks that the values of WWW-Authenticate headers have a challenge for one of the security schemes,
+// if they have a standard challenge
+func checkAuthenticateChallenge(values []string, schemes []mapAny) error {
+	if len(values) == 0 {
+		return fmt.Errorf("expected a WWW-Authenticate header, got none")
+	}
+	var expected []string
+	for _, scheme := range schemes {
+		if s, found := authenticationSchemes[fmt.Sprint(scheme["scheme"])]; found {
+			expected = append(expected, s)
+		}
+	}
+	if len(expected) == 0 {
+		return nil
+	}
+	for _, e := range expected {
+		for _, c := range challengeSchemes(values) {
+			if strings.EqualFold(e, c) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("expected a challenge for %s, got: %s", strings.Join(expected, " or "), strings.Join(values, ", "))
+}
+
+// challengeSchemes returns the authentication schemes of the challenges in WWW-Authenticate header values
+func challengeSchemes(values []string) []string {
+	var schemes []string
+	for _, v := range values {
+		for _, item := range splitHeaderList(v) {
+			// a challenge starts with its scheme, and an auth-param with its name followed by "="
+			fields := strings.Fields(item)
+			if len(fields) == 0 || strings.Contains(fields[0], "=") || (len(fields) > 1 && strings.HasPrefix(fields[1], "=")) {
+				continue
+			}
+			schemes = append(schemes, fields[0])
+		}
+	}
+	return schemes
+}
+
+// splitHeaderList splits a header value at the commas that are not in quoted strings
+func splitHeaderList(value string) []string {
+	var items []string
+	quoted, escaped := false, false
+	start := 0
+	for i, c := range value {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			items = append(items, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, value[start:])
+}
@@ -0,0 +1,248 @@
+package directory
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/wot-discovery-testing/directory/reference"
+)
+
+var (
+	serverURL              string
+	capabilityList         string
+	testJSONPath           bool // deprecated by capabilityList
+	testXPath              bool // deprecated by capabilityList
+	specVersion            string
+	templateURL, manualURL string
+	manualResultsFile      string
+	waiversFile            string
+	reportFormats          string
+	diffFrom, diffTo       string
+	auth                   authConfig
+	oauth2Scopes           string
+	tlsClient              tlsConfig
+	replayFile             string
+	headers                = make(headerFlag)
+	requestTimeout         time.Duration
+	testTimeout            time.Duration
+	retries                int
+	directoryTD            string
+	mutationNames          string
+	targetList             string
+)
+
+// invocation is the command which runs the tests and its arguments, to run them again with mutations
+var invocation struct {
+	command []string
+	args    []string
+}
+
+// suiteFlags are the flags of a test run, which can also be set by the config file and environment variables
+var suiteFlags = flag.NewFlagSet("suite", flag.ContinueOnError)
+
+// registerFlags defines the flags of a test run in the given set
+func registerFlags(fs *flag.FlagSet) {
+	suiteFlags.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+}
+
+func init() {
+	fs := suiteFlags
+	fs.StringVar(&configFile, "config", "", "YAML or JSON file with the configuration of the run. Flags override its values and environment variables override the flags, e.g. WOT_TDD_SERVER for --server")
+	fs.StringVar(&capabilityList, "capabilities", capabilitiesAuto, "Comma-separated list of the capabilities of the directory to test: things-crud, pagination, jsonpath, xpath, sparql, sparql-federation, notifications, notifications-diff, expiry. With auto, the others are detected")
+	fs.BoolVar(&testJSONPath, "testJSONPath", false, "Deprecated: use --capabilities=auto,jsonpath")
+	fs.BoolVar(&testXPath, "testXPath", false, "Deprecated: use --capabilities=auto,xpath")
+	fs.StringVar(&serverURL, "server", "", "Base URL of the directory service. If not set, an in-memory reference directory is tested")
+	fs.StringVar(&specVersion, "specVersion", "", "Spec version of the embedded assertion catalogs to use, instead of downloading them from --templateURL and --manualURL")
+	fs.StringVar(&templateURL, "templateURL", defaultTemplateURL, "URL to download assertions template")
+	fs.StringVar(&manualURL, "manualURL", defaultManualURL, "URL to download template for assertions that are tested manually")
+	fs.StringVar(&manualResultsFile, "manualResults", "", "CSV file with results of manual testing, to be merged into a combined report")
+	fs.StringVar(&waiversFile, "waivers", "", "CSV file with expected outcomes of assertions or subtests. If set, the process exits with an error on unexpected outcomes")
+	fs.StringVar(&reportFormats, "reportFormats", reportFormatCSV, "Comma-separated list of report formats: csv, junit, earl-turtle, earl-jsonld, html, json")
+	fs.StringVar(&diffFrom, "diffFrom", "", "Previous report (CSV or JSON) to compare with the one given by --diffTo, instead of running the tests")
+	fs.StringVar(&diffTo, "diffTo", "", "Report (CSV or JSON) to compare with the one given by --diffFrom. Defaults to tdd-auto.csv in the report directory")
+	fs.StringVar(&reportDir, "reportDir", defaultReportDir, "Directory to write the reports to")
+	fs.StringVar(&auth.scheme, "auth", authNone, "Authentication scheme for requests to the directory: none, basic, bearer, oauth2")
+	fs.StringVar(&auth.username, "authUsername", "", "Username for basic authentication")
+	fs.StringVar(&auth.password, "authPassword", "", "Password for basic authentication")
+	fs.StringVar(&auth.token, "authToken", "", "Token for bearer authentication")
+	fs.StringVar(&auth.tokenURL, "oauth2TokenURL", "", "Token endpoint for OAuth2 client credentials authentication")
+	fs.StringVar(&auth.clientID, "oauth2ClientID", "", "Client ID for OAuth2 client credentials authentication")
+	fs.StringVar(&auth.clientSecret, "oauth2ClientSecret", "", "Client secret for OAuth2 client credentials authentication")
+	fs.StringVar(&oauth2Scopes, "oauth2Scopes", "", "Comma-separated list of scopes to request with OAuth2 client credentials authentication")
+	fs.StringVar(&tlsClient.caFile, "tlsCA", "", "PEM file with the CA certificates to trust, instead of the system ones")
+	fs.StringVar(&tlsClient.certFile, "tlsCert", "", "PEM file with the client certificate for mutual TLS")
+	fs.StringVar(&tlsClient.keyFile, "tlsKey", "", "PEM file with the key of the client certificate")
+	fs.StringVar(&tlsClient.serverName, "tlsServerName", "", "Server name for SNI and certificate verification, instead of the host of the server URL")
+	fs.StringVar(&tlsClient.minVersion, "tlsMinVersion", "", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	fs.StringVar(&replayFile, "replay", "", "HAR file with recorded traffic to serve the responses from, instead of sending requests to the server")
+	fs.Var(headers, "header", "Header to add to every request, in the form \"Name: value\". Can be repeated")
+	fs.DurationVar(&requestTimeout, "requestTimeout", 30*time.Second, "Timeout of each request, excluding event streams. Zero for no timeout")
+	fs.DurationVar(&testTimeout, "testTimeout", 5*time.Minute, "Timeout of the requests of each top-level test, from its first request. Zero for no timeout")
+	fs.IntVar(&retries, "retries", 0, "Number of times to send a request again after a connection error, e.g. connection refused or reset")
+	fs.StringVar(&directoryTD, "directoryTD", "", "URL of the TD of the directory, describing its API. Defaults to /.well-known/wot on the host of the server")
+	fs.StringVar(&targetList, "targets", "", "Comma-separated list of directories to test in the form name=URL, e.g. a=http://localhost:8081,b=http://localhost:8082. Writes the reports of each to a subdirectory of the report directory and an assertion matrix of all")
+	fs.StringVar(&mutationNames, "mutations", "", "Comma-separated list of mutations to inject into the responses of the directory, or all. Runs the tests without and with each mutation, instead of once")
+}
+
+// runSuite prepares the tests from the parsed flags, runs them with the given function and writes the reports.
+// It returns the exit code of the process.
+func runSuite(runTests func() (passed bool)) int {
+	err := applyConfig(flag.CommandLine)
+	if err != nil {
+		fmt.Printf("Error reading the configuration: %s\n", err)
+		return 1
+	}
+	config := effectiveConfig()
+	printConfig(config)
+
+	if diffFrom != "" {
+		if diffTo == "" {
+			diffTo = reportPath(reportFile)
+		}
+		regressions := writeDiffReport(reportPath(diffReportFile), diffFrom, diffTo)
+		if regressions > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	targets, err := selectedTargets()
+	if err != nil {
+		fmt.Printf("Error reading the targets: %s\n", err)
+		return 1
+	}
+	if len(targets) > 0 {
+		if replayFile != "" || mutationNames != "" {
+			fmt.Println("Testing several targets cannot be combined with recorded traffic or mutation testing")
+			return 1
+		}
+		return runTargets(targets, reportConfig{
+			specVersion: specVersion,
+			templateURL: templateURL,
+			manualURL:   manualURL,
+			effective:   config,
+			formats:     strings.Split(reportFormats, ","),
+		})
+	}
+
+	var replay *replayTransport
+	if replayFile != "" {
+		var recordedServer string
+		var err error
+		replay, recordedServer, err = loadReplay(replayFile)
+		if err != nil {
+			fmt.Printf("Error loading recorded traffic: %s\n", err)
+			return 1
+		}
+		if serverURL == "" {
+			serverURL = recordedServer
+		}
+		fmt.Printf("Replaying recorded traffic from %s\n", replayFile)
+	}
+
+	inMemory := serverURL == ""
+	if inMemory {
+		// check the suite itself
+		server := httptest.NewServer(reference.NewDirectory())
+		defer server.Close()
+		serverURL = server.URL
+		fmt.Println("Server URL is not set, testing the in-memory reference directory.")
+	}
+	_, err = url.Parse(serverURL)
+	if err != nil {
+		fmt.Printf("Error parsing server URL: %s", err)
+		return 1
+	}
+	fmt.Printf("Server URL: %s\n", serverURL)
+
+	if oauth2Scopes != "" {
+		auth.scopes = strings.Split(oauth2Scopes, ",")
+	}
+	authenticator, err := newAuthenticator(auth)
+	if err != nil {
+		fmt.Printf("Error setting up authentication: %s\n", err)
+		return 1
+	}
+	tlsClientConfig, err := newTLSConfig(tlsClient)
+	if err != nil {
+		fmt.Printf("Error setting up TLS: %s\n", err)
+		return 1
+	}
+
+	if mutationNames != "" {
+		if replay != nil || strings.HasPrefix(serverURL, "coap") {
+			fmt.Println("Mutation testing needs an HTTP server, not recorded traffic or CoAP")
+			return 1
+		}
+		if runMutationTesting(strings.Split(mutationNames, ","), serverURL, inMemory, tlsClientConfig) > 0 {
+			return 1
+		}
+		return 0
+	}
+	client := clientConfig{
+		auth:    authenticator,
+		tls:     tlsClientConfig,
+		headers: http.Header(headers),
+		timeout: requestTimeout,
+		retries: retries,
+	}
+	if replay != nil {
+		// the recorded responses do not need credentials
+		client.auth = nil
+		client.replay = replay
+	}
+	setupHTTPClient(client)
+	testDeadlines.timeout = testTimeout
+
+	// resolve the endpoints from the self-description of the directory
+	if directoryTD == "" {
+		directoryTD = selfDescriptionURL(serverURL)
+	}
+	err = discoverEndpoints(directoryTD)
+	if err != nil {
+		fmt.Printf("Warning: Could not get the directory TD from %s: %s. Using the default paths.\n", directoryTD, err)
+	} else {
+		fmt.Printf("Directory TD: %s, describing %d affordances\n", directoryTD, len(selfDescription.hrefs))
+	}
+
+	// select the tests to run
+	err = selectCapabilities(withDeprecatedCapabilities(capabilityList), serverURL)
+	if err != nil {
+		fmt.Printf("Error selecting capabilities: %s\n", err)
+		return 1
+	}
+	printCapabilities()
+
+	writeReport := initReportWriter(reportConfig{
+		specVersion:   specVersion,
+		templateURL:   templateURL,
+		manualURL:     manualURL,
+		manualResults: manualResultsFile,
+		waivers:       waiversFile,
+		inlineWaivers: configWaivers,
+		effective:     config,
+		serverURL:     serverURL,
+		tls:           tlsClientConfig,
+		formats:       strings.Split(reportFormats, ","),
+	})
+
+	passed := runTests()
+
+	unexpected := writeReport()
+
+	if !passed {
+		fmt.Println("Some tests failed, but the reporting is complete.")
+	}
+	if (waiversFile != "" || len(configWaivers) > 0) && unexpected > 0 {
+		fmt.Printf("%d assertions had an unexpected outcome.\n", unexpected)
+		return 1
+	}
+	return 0
+}
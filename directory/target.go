@@ -0,0 +1,176 @@
+package directory
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// target is a directory tested in a run against several directories, e.g. the implementations of a W3C implementation report
+type target struct {
+	Name          string   `yaml:"name"`
+	Server        string   `yaml:"server"`
+	DirectoryTD   string   `yaml:"directoryTD"`
+	Capabilities  []string `yaml:"capabilities"`
+	Headers       []string `yaml:"headers"`
+	ManualResults string   `yaml:"manualResults"`
+}
+
+// configTargets are the targets listed in the config file
+var configTargets []target
+
+// targetNameRegexp matches the names of targets, which are also the names of their report directories
+var targetNameRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// targetOutputFile is the output of the tests of a target, in its report directory
+const targetOutputFile = "output.txt"
+
+// targetExcludedFlags are not passed on to the runs of the targets, which set them for each target
+var targetExcludedFlags = []string{"config", "targets", "server", "directoryTD", "capabilities", "header", "manualResults", "reportFormats", "reportDir", "mutations", "diffFrom", "diffTo", "replay"}
+
+// parseTargets parses a comma-separated list of targets in the form name=URL
+func parseTargets(list string) ([]target, error) {
+	var targets []target
+	for _, item := range strings.Split(list, ",") {
+		nameURL := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(nameURL) != 2 {
+			return nil, fmt.Errorf("invalid target %s, expected name=URL", item)
+		}
+		targets = append(targets, target{Name: nameURL[0], Server: nameURL[1]})
+	}
+	return targets, checkTargets(targets)
+}
+
+// checkTargets checks that the targets have a server and unique names that can be used as directory names
+func checkTargets(targets []target) error {
+	names := make(map[string]bool)
+	for _, t := range targets {
+		if !targetNameRegexp.MatchString(t.Name) {
+			return fmt.Errorf("invalid target name %q, expected letters, digits, dots, dashes and underscores", t.Name)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("duplicate target name %s", t.Name)
+		}
+		names[t.Name] = true
+		if t.Server == "" {
+			return fmt.Errorf("target %s has no server", t.Name)
+		}
+	}
+	return nil
+}
+
+// selectedTargets returns the targets given by --targets, which override those of the config file
+func selectedTargets() ([]target, error) {
+	if targetList != "" {
+		return parseTargets(targetList)
+	}
+	return configTargets, checkTargets(configTargets)
+}
+
+// runTargets runs the tests against each target in a new process, so that each has its own results,
+// writes the reports of each target in a subdirectory named after it and the matrix of the assertions of all targets.
+// It returns the exit code of the process: 1 if the tests of a target could not be run or had unexpected outcomes.
+func runTargets(targets []target, config reportConfig) int {
+	sources := loadReportSources(config)
+	formats := config.formats
+	if !inSlice(formats, reportFormatCSV) {
+		// the matrix is read from the CSV reports
+		formats = append(formats, reportFormatCSV)
+	}
+
+	code := 0
+	reports := make(map[string]map[string]reportRecord)
+	var names []string
+	for _, t := range targets {
+		names = append(names, t.Name)
+		fmt.Printf("Running the tests against %s at %s\n", t.Name, t.Server)
+		dir := reportPath(t.Name)
+		err := runTarget(t, dir, formats)
+		if err != nil {
+			fmt.Printf("Error testing %s: %s\n", t.Name, err)
+			code = 1
+		}
+		// with the results of manual testing, if any
+		filename := filepath.Join(dir, combinedReportFile)
+		if _, err := os.Stat(filename); err != nil {
+			filename = filepath.Join(dir, reportFile)
+		}
+		records, err := readReport(filename)
+		if err != nil {
+			fmt.Printf("Error reading the report of %s: %s\n", t.Name, err)
+			code = 1
+			continue
+		}
+		reports[t.Name] = records
+		fmt.Printf("%s: %s\n", t.Name, statusCounts(records))
+	}
+
+	matrix := assertionMatrix(names, append(sources.assertions, sources.manualAssertions...), reports)
+	writeMatrixReport(reportPath(matrixReportFile), names, matrix)
+	if inSlice(config.formats, reportFormatHTML) {
+		writeMatrixHTMLReport(reportPath(matrixHTMLReportFile), sources.catalog, names, matrix)
+	}
+	writeConfigReport(reportPath(configReportFile), config.effective)
+	return code
+}
+
+// runTarget runs the tests against a target in a new process, which writes its reports to the given directory.
+// The settings of the target are passed as environment variables, which override the flags and the config file.
+func runTarget(t target, dir string, formats []string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(invocation.command[0], append(invocation.command[1:], runArgs(invocation.args, targetExcludedFlags)...)...)
+	cmd.Env = append(runEnv(os.Environ(), targetExcludedFlags),
+		envVariable("server")+"="+t.Server,
+		envVariable("reportDir")+"="+absDir,
+		envVariable("reportFormats")+"="+strings.Join(formats, ","),
+		envVariable("header")+"="+strings.Join(append(headerLines(), t.Headers...), "\n"),
+	)
+	if t.DirectoryTD != "" {
+		cmd.Env = append(cmd.Env, envVariable("directoryTD")+"="+t.DirectoryTD)
+	}
+	if len(t.Capabilities) > 0 {
+		cmd.Env = append(cmd.Env, envVariable("capabilities")+"="+strings.Join(t.Capabilities, ","))
+	} else if capabilityList != capabilitiesAuto {
+		cmd.Env = append(cmd.Env, envVariable("capabilities")+"="+capabilityList)
+	}
+	if t.ManualResults != "" {
+		cmd.Env = append(cmd.Env, envVariable("manualResults")+"="+t.ManualResults)
+	}
+
+	output, err := cmd.CombinedOutput()
+	os.WriteFile(filepath.Join(dir, targetOutputFile), output, 0644)
+	if err != nil {
+		return fmt.Errorf("tests exited with %s, see %s", err, filepath.Join(dir, targetOutputFile))
+	}
+	return nil
+}
+
+// statusCounts describes the number of assertions of each status in a report
+func statusCounts(records map[string]reportRecord) string {
+	counts := make(map[string]int)
+	for _, r := range records {
+		counts[r.status]++
+	}
+	var statuses []string
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	var parts []string
+	for _, status := range statuses {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[status], status))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,45 @@
+//go:build !conformance
+// +build !conformance
+
+package directory
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseTargets checks the targets given by --targets.
+func TestParseTargets(t *testing.T) {
+	targets, err := parseTargets("a=http://localhost:8081, b.2=http://localhost:8082/td?x=1")
+	if err != nil {
+		t.Fatalf("Error parsing targets: %s", err)
+	}
+	expected := []target{{Name: "a", Server: "http://localhost:8081"}, {Name: "b.2", Server: "http://localhost:8082/td?x=1"}}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Fatalf("Expected %v, got %v", expected, targets)
+	}
+
+	for _, list := range []string{"http://localhost:8081", "a=http://localhost:8081,a=http://localhost:8082", "../a=http://localhost:8081", "a="} {
+		if _, err := parseTargets(list); err == nil {
+			t.Errorf("Expected an error for %s", list)
+		}
+	}
+}
+
+// TestAssertionMatrix checks the statuses of the assertions of several targets.
+func TestAssertionMatrix(t *testing.T) {
+	reports := map[string]map[string]reportRecord{
+		"a": {"tdd-things-crud": {status: "pass"}, "tdd-search-jsonpath": {status: "fail"}},
+		"b": {"tdd-things-crud": {status: "pass"}, "tdd-notification": {status: "pass"}},
+	}
+	rows := assertionMatrix([]string{"a", "b"}, []string{"ID", "tdd-things-crud", "tdd-search-xpath"}, reports)
+	expected := []matrixRow{
+		{ID: "tdd-notification", Statuses: []string{"", "pass"}, Passing: 1},
+		{ID: "tdd-search-jsonpath", Statuses: []string{"fail", ""}},
+		{ID: "tdd-search-xpath", Statuses: []string{"", ""}},
+		{ID: "tdd-things-crud", Statuses: []string{"pass", "pass"}, Passing: 2},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("Expected %v, got %v", expected, rows)
+	}
+}
@@ -1,25 +1,23 @@
 package directory
 
 import (
-	"bytes"
 	"encoding/json"
 	"net/http"
 	"net/url"
 	"strings"
 	"testing"
 	"time"
-
-	uuid "github.com/satori/go.uuid"
 )
 
 func TestCreateAnonymousThing(t *testing.T) {
+	requireCapability(t, capabilityThingsCRUD)
 
 	td := mockedTD("") // without ID
 	b, _ := json.Marshal(td)
 
 	var response *http.Response
 
-	t.Run("submit request", func(t *testing.T) {
+	runSubtest(t, "submit request", func(t *testing.T) {
 		defer report(t,
 			"tdd-things-crud",
 			"tdd-things-crudl",
@@ -27,9 +25,9 @@ func TestCreateAnonymousThing(t *testing.T) {
 			"tdd-things-create-anonymous-contenttype")
 
 		// submit POST request
-		res, err := http.Post(serverURL+"/things", MediaTypeThingDescription, bytes.NewReader(b))
+		res, err := httpPost(thingsURL(serverURL, affordanceCreateAnonymous), MediaTypeThingDescription, b, t)
 		if err != nil {
-			t.Fatalf("Error posting: %s", err)
+			fatalf(t, "Error posting: %s", err)
 		}
 		response = res
 		// defer res.Body.Close()
@@ -37,13 +35,13 @@ func TestCreateAnonymousThing(t *testing.T) {
 
 	body := httpReadBody(response, t)
 
-	t.Run("status code", func(t *testing.T) {
+	runSubtest(t, "status code", func(t *testing.T) {
 		defer report(t, "tdd-things-create-anonymous-td-resp")
 		assertStatusCode(t, response, http.StatusCreated, body)
 	})
 
 	var systemGeneratedID string
-	t.Run("location header", func(t *testing.T) {
+	runSubtest(t, "location header", func(t *testing.T) {
 		defer report(t,
 			"tdd-things-create-anonymous-td-resp",
 			"tdd-anonymous-td-local-uuid",
@@ -52,22 +50,22 @@ func TestCreateAnonymousThing(t *testing.T) {
 		// Check if system-generated id is in response
 		location, err := response.Location()
 		if err != nil {
-			t.Fatalf(err.Error())
+			fatalf(t, err.Error())
 		}
 		systemGeneratedID = location.String()
 		if systemGeneratedID == "" {
-			t.Fatalf("System-generated ID not in response. Got location header: %s", location)
+			fatalf(t, "System-generated ID not in response. Got location header: %s", location)
 		}
 		_, err = url.ParseRequestURI(systemGeneratedID)
 		if err != nil {
-			t.Fatalf("System-generated ID not in a valid URI. Got: %s", location)
+			fatalf(t, "System-generated ID not in a valid URI. Got: %s", location)
 		}
 		if !strings.Contains(systemGeneratedID, "urn:uuid:") {
-			t.Fatalf("System-generated ID doesn't have URN UUID scheme. Got: %s", location)
+			fatalf(t, "System-generated ID doesn't have URN UUID scheme. Got: %s", location)
 		}
 	})
 
-	t.Run("registration info", func(t *testing.T) {
+	runSubtest(t, "registration info", func(t *testing.T) {
 		defer report(t, "tdd-anonymous-td-identifier")
 
 		// retrieve the stored TD
@@ -77,52 +75,52 @@ func TestCreateAnonymousThing(t *testing.T) {
 	})
 
 	// reject PUT of anonymous TD
-	t.Run("reject PUT", func(t *testing.T) {
+	runSubtest(t, "reject PUT", func(t *testing.T) {
 		defer report(t, "tdd-things-create-known-vs-anonymous")
 
 		td := mockedTD("") // no id
 		b, _ := json.Marshal(td)
 
 		// submit PUT request
-		res, err := httpPut(serverURL+"/things", MediaTypeThingDescription, b)
+		res, err := httpPut(thingsURL(serverURL, affordanceThings), MediaTypeThingDescription, b, t)
 		if err != nil {
-			t.Fatalf("Error putting: %s", err)
+			fatalf(t, "Error putting: %s", err)
 		}
 		defer res.Body.Close()
 
-		if res.StatusCode < 400 && res.StatusCode >= 500 {
-			t.Fatalf("Anonymous TD submission with PUT not rejected. Got status: %d", res.StatusCode)
+		if res.StatusCode < 400 || res.StatusCode >= 500 {
+			fatalf(t, "Anonymous TD submission with PUT not rejected. Got status: %d", res.StatusCode)
 		}
 	})
 
-	t.Run("reject invalid", func(t *testing.T) {
+	runSubtest(t, "reject invalid", func(t *testing.T) {
 		td := mockedTD("")  // no id
 		delete(td, "title") // remove the mandatory field
 
 		b, _ := json.Marshal(td)
 
 		// submit POST request
-		res, err := http.Post(serverURL+"/things", MediaTypeThingDescription, bytes.NewReader(b))
+		res, err := httpPost(thingsURL(serverURL, affordanceCreateAnonymous), MediaTypeThingDescription, b, t)
 		if err != nil {
-			t.Fatalf("Error posting: %s", err)
+			fatalf(t, "Error posting: %s", err)
 		}
 		defer res.Body.Close()
 
 		body = httpReadBody(res, t)
 
-		t.Run("status", func(t *testing.T) {
+		runSubtest(t, "status", func(t *testing.T) {
 			defer report(t, "tdd-validation-syntactic")
 
 			assertStatusCode(t, res, http.StatusBadRequest, nil)
 		})
 
-		t.Run("response", func(t *testing.T) {
+		runSubtest(t, "response", func(t *testing.T) {
 			defer report(t, "tdd-http-error-response")
 
 			assertErrorResponse(t, res, body)
 		})
 
-		t.Run("validation", func(t *testing.T) {
+		runSubtest(t, "validation", func(t *testing.T) {
 			defer report(t,
 				"tdd-validation-result",
 				"tdd-validation-response",
@@ -134,23 +132,24 @@ func TestCreateAnonymousThing(t *testing.T) {
 }
 
 func TestCreateThing(t *testing.T) {
+	requireCapability(t, capabilityThingsCRUD)
 
-	id := "urn:uuid:" + uuid.NewV4().String()
+	id := "urn:uuid:" + newUUID()
 	td := mockedTD(id)
 	b, _ := json.Marshal(td)
 
 	var response *http.Response
 
-	t.Run("request", func(t *testing.T) {
+	runSubtest(t, "request", func(t *testing.T) {
 		defer report(t,
 			"tdd-things-crud",
 			"tdd-things-crudl",
 			"tdd-things-create-known-td")
 
 		// submit PUT request
-		res, err := httpPut(serverURL+"/things/"+id, MediaTypeThingDescription, b)
+		res, err := httpPut(thingURL(serverURL, id, affordanceCreateThing), MediaTypeThingDescription, b, t)
 		if err != nil {
-			t.Fatalf("Error posting: %s", err)
+			fatalf(t, "Error posting: %s", err)
 		}
 		response = res
 		// defer res.Body.Close()
@@ -158,39 +157,39 @@ func TestCreateThing(t *testing.T) {
 
 	body := httpReadBody(response, t)
 
-	t.Run("status code", func(t *testing.T) {
+	runSubtest(t, "status code", func(t *testing.T) {
 		defer report(t, "tdd-things-create-known-td-resp")
 		assertStatusCode(t, response, http.StatusCreated, body)
 	})
 
-	t.Run("reject invalid", func(t *testing.T) {
-		id := "urn:uuid:" + uuid.NewV4().String()
+	runSubtest(t, "reject invalid", func(t *testing.T) {
+		id := "urn:uuid:" + newUUID()
 		td := mockedTD(id)
 		delete(td, "title") // remove the mandatory field
 
 		b, _ := json.Marshal(td)
 
 		// submit PUT request
-		res, err := httpPut(serverURL+"/things/"+id, MediaTypeThingDescription, b)
+		res, err := httpPut(thingURL(serverURL, id, affordanceCreateThing), MediaTypeThingDescription, b, t)
 		if err != nil {
-			t.Fatalf("Error putting: %s", err)
+			fatalf(t, "Error putting: %s", err)
 		}
 		defer res.Body.Close()
 
 		body = httpReadBody(res, t)
 
-		t.Run("status", func(t *testing.T) {
+		runSubtest(t, "status", func(t *testing.T) {
 			defer report(t, "tdd-validation-syntactic")
 
 			assertStatusCode(t, res, http.StatusBadRequest, body)
 		})
 
-		t.Run("response", func(t *testing.T) {
+		runSubtest(t, "response", func(t *testing.T) {
 			defer report(t, "tdd-http-error-response")
 			assertErrorResponse(t, res, body)
 		})
 
-		t.Run("validation", func(t *testing.T) {
+		runSubtest(t, "validation", func(t *testing.T) {
 			defer report(t, "tdd-validation-result", "tdd-validation-response")
 			assertValidationResponse(t, res, body)
 		})
@@ -198,15 +197,16 @@ func TestCreateThing(t *testing.T) {
 }
 
 func TestRetrieveThing(t *testing.T) {
+	requireCapability(t, capabilityThingsCRUD)
 
 	// add a new TD
-	id := "urn:uuid:" + uuid.NewV4().String()
+	id := "urn:uuid:" + newUUID()
 	td := mockedTD(id)
 	createThing(id, td, serverURL, t)
 
 	var response *http.Response
 
-	t.Run("submit request", func(t *testing.T) {
+	runSubtest(t, "submit request", func(t *testing.T) {
 		defer report(t,
 			"tdd-things-crud",
 			"tdd-things-crudl",
@@ -214,9 +214,9 @@ func TestRetrieveThing(t *testing.T) {
 		)
 
 		// submit GET request
-		res, err := http.Get(serverURL + "/things/" + id)
+		res, err := httpGet(thingURL(serverURL, id, affordanceRetrieveThing), t)
 		if err != nil {
-			t.Fatalf("Error getting TD: %s", err)
+			fatalf(t, "Error getting TD: %s", err)
 		}
 		response = res
 		// defer res.Body.Close()
@@ -224,25 +224,25 @@ func TestRetrieveThing(t *testing.T) {
 
 	body := httpReadBody(response, t)
 
-	t.Run("status code", func(t *testing.T) {
+	runSubtest(t, "status code", func(t *testing.T) {
 		defer report(t, "tdd-things-retrieve-resp")
 		assertStatusCode(t, response, http.StatusOK, body)
 	})
 
-	t.Run("content type", func(t *testing.T) {
+	runSubtest(t, "content type", func(t *testing.T) {
 		defer report(t,
 			"tdd-things-default-representation",
 			"tdd-things-retrieve-resp")
 		assertContentMediaType(t, response, MediaTypeThingDescription)
 	})
 
-	t.Run("payload", func(t *testing.T) {
+	runSubtest(t, "payload", func(t *testing.T) {
 		defer report(t, "tdd-things-retrieve")
 
 		var retrievedTD mapAny
 		err := json.Unmarshal(body, &retrievedTD)
 		if err != nil {
-			t.Fatalf("Error decoding body: %s", err)
+			fatalf(t, "Error decoding body: %s", err)
 		}
 
 		// remove system-generated attributes
@@ -251,7 +251,7 @@ func TestRetrieveThing(t *testing.T) {
 		assertEqualTitle(t, td, retrievedTD)
 	})
 
-	t.Run("registrationInfo created", func(t *testing.T) {
+	runSubtest(t, "registrationInfo created", func(t *testing.T) {
 		defer report(t, "tdd-registrationinfo-vocab-created")
 		// retrieve the stored TD
 		storedTD := retrieveThing(id, serverURL, t)
@@ -259,7 +259,7 @@ func TestRetrieveThing(t *testing.T) {
 		testRegistrationInfoCreated(t, storedTD)
 	})
 
-	t.Run("registrationInfo modified", func(t *testing.T) {
+	runSubtest(t, "registrationInfo modified", func(t *testing.T) {
 		defer report(t, "tdd-registrationinfo-vocab-modified")
 		// retrieve the stored TD
 		storedTD := retrieveThing(id, serverURL, t)
@@ -273,12 +273,12 @@ func TestRetrieveThing(t *testing.T) {
 	// 	t.Skipf( "Tested under TestCreateAnonymousThing")
 	// })
 
-	t.Run("HEAD", func(t *testing.T) {
+	runSubtest(t, "HEAD", func(t *testing.T) {
 		defer report(t, "tdd-http-head")
 
-		res, err := httpRequest(http.MethodHead, serverURL+"/things/"+id, "", nil)
+		res, err := httpRequest(http.MethodHead, thingURL(serverURL, id, affordanceRetrieveThing), "", nil, t)
 		if err != nil {
-			t.Fatalf("Error making HEAD request: %s", err)
+			fatalf(t, "Error making HEAD request: %s", err)
 		}
 		body := httpReadBody(res, t)
 		assertStatusCode(t, res, http.StatusOK, body)
@@ -286,9 +286,10 @@ func TestRetrieveThing(t *testing.T) {
 }
 
 func TestUpdateThing(t *testing.T) {
+	requireCapability(t, capabilityThingsCRUD)
 
 	// add a new TD
-	id := "urn:uuid:" + uuid.NewV4().String()
+	id := "urn:uuid:" + newUUID()
 	td := mockedTD(id)
 	createThing(id, td, serverURL, t)
 
@@ -298,7 +299,7 @@ func TestUpdateThing(t *testing.T) {
 
 	var response *http.Response
 
-	t.Run("submit request", func(t *testing.T) {
+	runSubtest(t, "submit request", func(t *testing.T) {
 		defer report(t,
 			"tdd-things-crud",
 			"tdd-things-crudl",
@@ -307,9 +308,9 @@ func TestUpdateThing(t *testing.T) {
 		)
 
 		// submit PUT request
-		res, err := httpPut(serverURL+"/things/"+id, MediaTypeThingDescription, b)
+		res, err := httpPut(thingURL(serverURL, id, affordanceUpdateThing), MediaTypeThingDescription, b, t)
 		if err != nil {
-			t.Fatalf("Error putting TD: %s", err)
+			fatalf(t, "Error putting TD: %s", err)
 		}
 		response = res
 		// defer res.Body.Close()
@@ -317,12 +318,12 @@ func TestUpdateThing(t *testing.T) {
 
 	body := httpReadBody(response, t)
 
-	t.Run("status code", func(t *testing.T) {
+	runSubtest(t, "status code", func(t *testing.T) {
 		defer report(t, "tdd-things-update-resp")
 		assertStatusCode(t, response, http.StatusNoContent, body)
 	})
 
-	t.Run("payload", func(t *testing.T) {
+	runSubtest(t, "payload", func(t *testing.T) {
 		defer report(t, "tdd-things-update")
 
 		// retrieve the stored TD
@@ -335,33 +336,33 @@ func TestUpdateThing(t *testing.T) {
 		assertEqualTitle(t, td, storedTD)
 	})
 
-	t.Run("reject invalid", func(t *testing.T) {
+	runSubtest(t, "reject invalid", func(t *testing.T) {
 		delete(td, "title") // remove the mandatory field
 
 		b, _ := json.Marshal(td)
 
 		// submit PUT request
-		res, err := httpPut(serverURL+"/things/"+id, MediaTypeThingDescription, b)
+		res, err := httpPut(thingURL(serverURL, id, affordanceUpdateThing), MediaTypeThingDescription, b, t)
 		if err != nil {
-			t.Fatalf("Error putting: %s", err)
+			fatalf(t, "Error putting: %s", err)
 		}
 		defer res.Body.Close()
 
 		body := httpReadBody(res, t)
 
-		t.Run("status", func(t *testing.T) {
+		runSubtest(t, "status", func(t *testing.T) {
 			defer report(t, "tdd-validation-syntactic")
 
 			assertStatusCode(t, res, http.StatusBadRequest, body)
 		})
 
-		t.Run("response", func(t *testing.T) {
+		runSubtest(t, "response", func(t *testing.T) {
 			defer report(t, "tdd-http-error-response")
 
 			assertErrorResponse(t, res, body)
 		})
 
-		t.Run("validation", func(t *testing.T) {
+		runSubtest(t, "validation", func(t *testing.T) {
 			defer report(t, "tdd-validation-result", "tdd-validation-response")
 			assertValidationResponse(t, res, body)
 		})
@@ -369,6 +370,8 @@ func TestUpdateThing(t *testing.T) {
 }
 
 func TestPatch(t *testing.T) {
+	requireCapability(t, capabilityThingsCRUD)
+
 	var (
 		requestAssertions = []string{
 			"tdd-things-update-partial",
@@ -382,9 +385,9 @@ func TestPatch(t *testing.T) {
 		}
 	)
 
-	t.Run("replace title", func(t *testing.T) {
+	runSubtest(t, "replace title", func(t *testing.T) {
 		// add a new TD
-		id := "urn:uuid:" + uuid.NewV4().String()
+		id := "urn:uuid:" + newUUID()
 		td := mockedTD(id)
 		createThing(id, td, serverURL, t)
 
@@ -393,13 +396,13 @@ func TestPatch(t *testing.T) {
 
 		var response *http.Response
 
-		t.Run("submit request", func(t *testing.T) {
+		runSubtest(t, "submit request", func(t *testing.T) {
 			defer report(t, requestAssertions...)
 
 			// submit PATCH request
-			res, err := httpPatch(serverURL+"/things/"+id, MediaTypeMergePatch, []byte(jsonTD))
+			res, err := httpPatch(thingURL(serverURL, id, affordancePartialUpdate), MediaTypeMergePatch, []byte(jsonTD), t)
 			if err != nil {
-				t.Fatalf("Error patching TD: %s", err)
+				fatalf(t, "Error patching TD: %s", err)
 			}
 			// defer res.Body.Close()
 			response = res
@@ -407,13 +410,13 @@ func TestPatch(t *testing.T) {
 
 		body := httpReadBody(response, t)
 
-		t.Run("status code", func(t *testing.T) {
+		runSubtest(t, "status code", func(t *testing.T) {
 			defer report(t, statusAssertions...)
 
 			assertStatusCode(t, response, http.StatusNoContent, body)
 		})
 
-		t.Run("result", func(t *testing.T) {
+		runSubtest(t, "result", func(t *testing.T) {
 			defer report(t, resultAssertions...)
 
 			// retrieve the changed TD
@@ -429,9 +432,9 @@ func TestPatch(t *testing.T) {
 		})
 	})
 
-	t.Run("remove description", func(t *testing.T) {
+	runSubtest(t, "remove description", func(t *testing.T) {
 		// add a new TD
-		id := "urn:uuid:" + uuid.NewV4().String()
+		id := "urn:uuid:" + newUUID()
 		td := mockedTD(id)
 		td["description"] = "this is a test descr"
 		createThing(id, td, serverURL, t)
@@ -441,13 +444,13 @@ func TestPatch(t *testing.T) {
 
 		var response *http.Response
 
-		t.Run("submit request", func(t *testing.T) {
+		runSubtest(t, "submit request", func(t *testing.T) {
 			defer report(t, requestAssertions...)
 
 			// submit PATCH request
-			res, err := httpPatch(serverURL+"/things/"+id, MediaTypeMergePatch, []byte(jsonTD))
+			res, err := httpPatch(thingURL(serverURL, id, affordancePartialUpdate), MediaTypeMergePatch, []byte(jsonTD), t)
 			if err != nil {
-				t.Fatalf("Error patching TD: %s", err)
+				fatalf(t, "Error patching TD: %s", err)
 			}
 			// defer res.Body.Close()
 			response = res
@@ -455,12 +458,12 @@ func TestPatch(t *testing.T) {
 
 		body := httpReadBody(response, t)
 
-		t.Run("status code", func(t *testing.T) {
+		runSubtest(t, "status code", func(t *testing.T) {
 			defer report(t, statusAssertions...)
 			assertStatusCode(t, response, http.StatusNoContent, body)
 		})
 
-		t.Run("result", func(t *testing.T) {
+		runSubtest(t, "result", func(t *testing.T) {
 			defer report(t, resultAssertions...)
 
 			// retrieve the changed TD
@@ -476,9 +479,9 @@ func TestPatch(t *testing.T) {
 		})
 	})
 
-	t.Run("update properties", func(t *testing.T) {
+	runSubtest(t, "update properties", func(t *testing.T) {
 		// add a new TD
-		id := "urn:uuid:" + uuid.NewV4().String()
+		id := "urn:uuid:" + newUUID()
 		td := mockedTD(id)
 		td["properties"] = map[string]interface{}{
 			"status": map[string]interface{}{
@@ -494,13 +497,13 @@ func TestPatch(t *testing.T) {
 
 		var response *http.Response
 
-		t.Run("submit request", func(t *testing.T) {
+		runSubtest(t, "submit request", func(t *testing.T) {
 			defer report(t, requestAssertions...)
 
 			// submit PATCH request
-			res, err := httpPatch(serverURL+"/things/"+id, MediaTypeMergePatch, []byte(jsonTD))
+			res, err := httpPatch(thingURL(serverURL, id, affordancePartialUpdate), MediaTypeMergePatch, []byte(jsonTD), t)
 			if err != nil {
-				t.Fatalf("Error patching TD: %s", err)
+				fatalf(t, "Error patching TD: %s", err)
 			}
 			// defer res.Body.Close()
 			response = res
@@ -508,13 +511,13 @@ func TestPatch(t *testing.T) {
 
 		body := httpReadBody(response, t)
 
-		t.Run("status code", func(t *testing.T) {
+		runSubtest(t, "status code", func(t *testing.T) {
 			defer report(t, statusAssertions...)
 
 			assertStatusCode(t, response, http.StatusNoContent, body)
 		})
 
-		t.Run("result", func(t *testing.T) {
+		runSubtest(t, "result", func(t *testing.T) {
 			defer report(t, resultAssertions...)
 
 			// retrieve the changed TD
@@ -541,9 +544,9 @@ func TestPatch(t *testing.T) {
 		})
 	})
 
-	t.Run("replace array", func(t *testing.T) {
+	runSubtest(t, "replace array", func(t *testing.T) {
 		// add a new TD
-		id := "urn:uuid:" + uuid.NewV4().String()
+		id := "urn:uuid:" + newUUID()
 		td := mockedTD(id)
 		td["properties"] = map[string]interface{}{
 			"status": map[string]interface{}{
@@ -562,13 +565,13 @@ func TestPatch(t *testing.T) {
 
 		var response *http.Response
 
-		t.Run("submit request", func(t *testing.T) {
+		runSubtest(t, "submit request", func(t *testing.T) {
 			defer report(t, requestAssertions...)
 
 			// submit PATCH request
-			res, err := httpPatch(serverURL+"/things/"+id, MediaTypeMergePatch, []byte(jsonTD))
+			res, err := httpPatch(thingURL(serverURL, id, affordancePartialUpdate), MediaTypeMergePatch, []byte(jsonTD), t)
 			if err != nil {
-				t.Fatalf("Error patching TD: %s", err)
+				fatalf(t, "Error patching TD: %s", err)
 			}
 			// defer res.Body.Close()
 			response = res
@@ -576,13 +579,13 @@ func TestPatch(t *testing.T) {
 
 		body := httpReadBody(response, t)
 
-		t.Run("status code", func(t *testing.T) {
+		runSubtest(t, "status code", func(t *testing.T) {
 			defer report(t, statusAssertions...)
 
 			assertStatusCode(t, response, http.StatusNoContent, body)
 		})
 
-		t.Run("result", func(t *testing.T) {
+		runSubtest(t, "result", func(t *testing.T) {
 			defer report(t, resultAssertions...)
 
 			// retrieve the changed TD
@@ -605,9 +608,9 @@ func TestPatch(t *testing.T) {
 		})
 	})
 
-	t.Run("reject invalid", func(t *testing.T) {
+	runSubtest(t, "reject invalid", func(t *testing.T) {
 		// add a new TD
-		id := "urn:uuid:" + uuid.NewV4().String()
+		id := "urn:uuid:" + newUUID()
 		td := mockedTD(id)
 		createThing(id, td, serverURL, t)
 
@@ -615,27 +618,27 @@ func TestPatch(t *testing.T) {
 		jsonTD := `{"title": null}`
 
 		// submit PATCH request
-		res, err := httpPatch(serverURL+"/things/"+id, MediaTypeMergePatch, []byte(jsonTD))
+		res, err := httpPatch(thingURL(serverURL, id, affordancePartialUpdate), MediaTypeMergePatch, []byte(jsonTD), t)
 		if err != nil {
-			t.Fatalf("Error patching TD: %s", err)
+			fatalf(t, "Error patching TD: %s", err)
 		}
 		defer res.Body.Close()
 
 		body := httpReadBody(res, t)
 
-		t.Run("status", func(t *testing.T) {
+		runSubtest(t, "status", func(t *testing.T) {
 			defer report(t, "tdd-validation-syntactic")
 
 			assertStatusCode(t, res, http.StatusBadRequest, body)
 		})
 
-		t.Run("response", func(t *testing.T) {
+		runSubtest(t, "response", func(t *testing.T) {
 			defer report(t, "tdd-http-error-response")
 
 			assertErrorResponse(t, res, body)
 		})
 
-		t.Run("validation", func(t *testing.T) {
+		runSubtest(t, "validation", func(t *testing.T) {
 			defer report(t, "tdd-validation-result", "tdd-validation-response")
 
 			assertValidationResponse(t, res, body)
@@ -644,24 +647,25 @@ func TestPatch(t *testing.T) {
 }
 
 func TestDelete(t *testing.T) {
+	requireCapability(t, capabilityThingsCRUD)
 
 	// add a new TD
-	id := "urn:uuid:" + uuid.NewV4().String()
+	id := "urn:uuid:" + newUUID()
 	td := mockedTD(id)
 	createThing(id, td, serverURL, t)
 
 	var response *http.Response
 
-	t.Run("submit request", func(t *testing.T) {
+	runSubtest(t, "submit request", func(t *testing.T) {
 		defer report(t,
 			"tdd-things-crud",
 			"tdd-things-crudl",
 			"tdd-things-delete")
 
 		// submit DELETE request
-		res, err := httpDelete(serverURL + "/things/" + id)
+		res, err := httpDelete(thingURL(serverURL, id, affordanceDeleteThing), t)
 		if err != nil {
-			t.Fatalf("Error deleting TD: %s", err)
+			fatalf(t, "Error deleting TD: %s", err)
 		}
 		// defer res.Body.Close()
 		response = res
@@ -669,7 +673,7 @@ func TestDelete(t *testing.T) {
 
 	body := httpReadBody(response, t)
 
-	t.Run("status code", func(t *testing.T) {
+	runSubtest(t, "status code", func(t *testing.T) {
 		defer report(t, "tdd-things-delete-resp")
 
 		assertStatusCode(t, response, http.StatusNoContent, body)
@@ -677,12 +681,13 @@ func TestDelete(t *testing.T) {
 }
 
 func TestListThings(t *testing.T) {
+	requireCapability(t, capabilityThingsCRUD)
 
 	var response *http.Response
 	var body []byte
 
-	tag := uuid.NewV4().String()
-	t.Run("submit request", func(t *testing.T) {
+	tag := newUUID()
+	runSubtest(t, "submit request", func(t *testing.T) {
 		defer report(t,
 			"tdd-things-list-only",
 			"tdd-things-crudl",
@@ -690,52 +695,52 @@ func TestListThings(t *testing.T) {
 		)
 
 		for i := 0; i < 3; i++ {
-			id := "urn:uuid:" + uuid.NewV4().String()
+			id := "urn:uuid:" + newUUID()
 			td := mockedTD(id)
 			// tag the TDs to find later
 			td["tag"] = tag
 			createThing(id, td, serverURL, t)
 		}
 
-		res, err := http.Get(serverURL + "/things")
+		res, err := httpGet(thingsURL(serverURL, affordanceThings), t)
 		if err != nil {
-			t.Fatalf("Error getting list of TDs: %s", err)
+			fatalf(t, "Error getting list of TDs: %s", err)
 		}
 		// defer res.Body.Close()
 		body = httpReadBody(res, t)
 		response = res
 	})
 
-	t.Run("status code", func(t *testing.T) {
+	runSubtest(t, "status code", func(t *testing.T) {
 		defer report(t, "tdd-things-list-method")
 
 		assertStatusCode(t, response, http.StatusOK, body)
 	})
 
-	t.Run("content type", func(t *testing.T) {
+	runSubtest(t, "content type", func(t *testing.T) {
 		defer report(t,
 			"tdd-things-default-representation",
 			"tdd-things-list-resp")
 		assertContentMediaType(t, response, MediaTypeJSONLD)
 	})
 
-	t.Run("payload", func(t *testing.T) {
+	runSubtest(t, "payload", func(t *testing.T) {
 		defer report(t, "tdd-things-list-resp")
 
 		var collection []mapAny
 		err := json.Unmarshal(body, &collection)
 		if err != nil {
-			t.Fatalf("Error decoding page: %s", err)
+			fatalf(t, "Error decoding page: %s", err)
 		}
 
 		if len(collection) == 0 {
-			t.Fatalf("Unexpected empty collection.")
+			fatalf(t, "Unexpected empty collection.")
 		}
 
 		var listedTDs []mapAny
 		for _, td := range collection {
 			if td["title"] == nil || td["title"].(string) == "" {
-				t.Fatalf("Object in array may not be a TD: no mandatory title. Body:\n%s", marshalPrettyJSON(td))
+				fatalf(t, "Object in array may not be a TD: no mandatory title. Body:\n%s", marshalPrettyJSON(td))
 			}
 			if td["tag"] != nil && td["tag"].(string) == tag {
 				listedTDs = append(listedTDs, td)
@@ -743,58 +748,58 @@ func TestListThings(t *testing.T) {
 		}
 
 		if len(listedTDs) != 3 {
-			t.Fatalf("Unexpected items in collection: %d. Expected 3 with tag: %s", len(listedTDs), tag)
+			fatalf(t, "Unexpected items in collection: %d. Expected 3 with tag: %s", len(listedTDs), tag)
 		}
 	})
 
-	t.Run("registrationInfo created", func(t *testing.T) {
+	runSubtest(t, "registrationInfo created", func(t *testing.T) {
 		defer report(t, "tdd-registrationinfo-vocab-created")
 
 		var collection []mapAny
 		err := json.Unmarshal(body, &collection)
 		if err != nil {
-			t.Fatalf("Error decoding page: %s", err)
+			fatalf(t, "Error decoding page: %s", err)
 		}
 
 		if len(collection) == 0 {
-			t.Fatalf("Unexpected empty collection.")
+			fatalf(t, "Unexpected empty collection.")
 		}
 
 		// just test the first TD
 		testRegistrationInfoCreated(t, collection[0])
 	})
 
-	t.Run("registrationInfo modified", func(t *testing.T) {
+	runSubtest(t, "registrationInfo modified", func(t *testing.T) {
 		defer report(t, "tdd-registrationinfo-vocab-modified")
 
 		var collection []mapAny
 		err := json.Unmarshal(body, &collection)
 		if err != nil {
-			t.Fatalf("Error decoding page: %s", err)
+			fatalf(t, "Error decoding page: %s", err)
 		}
 
 		if len(collection) == 0 {
-			t.Fatalf("Unexpected empty collection.")
+			fatalf(t, "Unexpected empty collection.")
 		}
 
 		// just test the first TD
 		testRegistrationInfoCreated(t, collection[0])
 	})
 
-	t.Run("anonymous td id", func(t *testing.T) {
+	runSubtest(t, "anonymous td id", func(t *testing.T) {
 		defer report(t, "tdd-anonymous-td-identifier")
 
 		// add an anonymous TD
 		createdTD := mockedTD("") // no id
 		// tag the TDs to find later
-		tag2 := uuid.NewV4().String()
+		tag2 := newUUID()
 		createdTD["tag"] = tag2
 		createThing("", createdTD, serverURL, t)
 
 		// submit the request
-		res, err := http.Get(serverURL + "/things")
+		res, err := httpGet(thingsURL(serverURL, affordanceThings), t)
 		if err != nil {
-			t.Fatalf("Error getting list of TDs: %s", err)
+			fatalf(t, "Error getting list of TDs: %s", err)
 		}
 		defer res.Body.Close()
 
@@ -803,11 +808,11 @@ func TestListThings(t *testing.T) {
 		var collection []mapAny
 		err = json.Unmarshal(body, &collection)
 		if err != nil {
-			t.Fatalf("Error decoding page: %s", err)
+			fatalf(t, "Error decoding page: %s", err)
 		}
 
 		if len(collection) == 0 {
-			t.Fatalf("Unexpected empty collection.")
+			fatalf(t, "Unexpected empty collection.")
 		}
 
 		var found bool
@@ -820,32 +825,75 @@ func TestListThings(t *testing.T) {
 			}
 		}
 		if !found {
-			t.Fatalf("Could not find the created anonymous TD with tag: %s", tag2)
+			fatalf(t, "Could not find the created anonymous TD with tag: %s", tag2)
 		}
 	})
 
-	// t.Run("pagination", func(t *testing.T) {
-	// 	defer report(t,
-	// 		"tdd-things-list-pagination",
-	// 		"tdd-things-list-pagination-limit",
-	// 		"tdd-things-list-pagination-header-nextlink",
-	// 		"tdd-things-list-pagination-header-nextlink-attr",
-	// 		"tdd-things-list-pagination-header-canonicallink",
-	// 		"tdd-things-list-pagination-order-default",
-	// 		"tdd-things-list-pagination-order",
-	// 		"tdd-things-list-pagination-order-unsupported",
-	// 		"tdd-things-list-pagination-order-nextlink",
-	// 	)
+	runSubtest(t, "pagination", func(t *testing.T) {
+		requireCapability(t, capabilityPagination)
+		defer report(t,
+			"tdd-things-list-pagination",
+			"tdd-things-list-pagination-limit",
+			"tdd-things-list-pagination-header-nextlink",
+		)
+		// "tdd-things-list-pagination-header-nextlink-attr",
+		// "tdd-things-list-pagination-header-canonicallink",
+		// "tdd-things-list-pagination-order-default",
+		// "tdd-things-list-pagination-order",
+		// "tdd-things-list-pagination-order-unsupported",
+		// "tdd-things-list-pagination-order-nextlink",
+
+		// at least three TDs were created, so there is a next page
+		res, err := httpGet(thingsPageURL(serverURL, 2), t)
+		if err != nil {
+			fatalf(t, "Error getting the first page: %s", err)
+		}
+		body := httpReadBody(res, t)
+		assertStatusCode(t, res, http.StatusOK, body)
 
-	// 	t.Skipf("TODO")
-	// })
+		var page []mapAny
+		err = json.Unmarshal(body, &page)
+		if err != nil {
+			fatalf(t, "Error decoding page: %s", err)
+		}
+		if len(page) != 2 {
+			fatalf(t, "Unexpected items in the page with limit 2: %d", len(page))
+		}
+
+		next := linkTarget(res, "next")
+		if next == "" {
+			fatalf(t, "No Link header with the next page. Got: %v", res.Header.Values("Link"))
+		}
+		res, err = httpGet(next, t)
+		if err != nil {
+			fatalf(t, "Error getting the next page: %s", err)
+		}
+		body = httpReadBody(res, t)
+		assertStatusCode(t, res, http.StatusOK, body)
 
-	t.Run("HEAD", func(t *testing.T) {
+		var nextPage []mapAny
+		err = json.Unmarshal(body, &nextPage)
+		if err != nil {
+			fatalf(t, "Error decoding the next page: %s", err)
+		}
+		if len(nextPage) == 0 || len(nextPage) > 2 {
+			fatalf(t, "Unexpected items in the next page with limit 2: %d", len(nextPage))
+		}
+		for _, td := range nextPage {
+			for _, previous := range page {
+				if getID(t, td) == getID(t, previous) {
+					fatalf(t, "TD %s is in both pages", getID(t, td))
+				}
+			}
+		}
+	})
+
+	runSubtest(t, "HEAD", func(t *testing.T) {
 		defer report(t, "tdd-http-head")
 
-		res, err := httpRequest(http.MethodHead, serverURL+"/things", "", nil)
+		res, err := httpRequest(http.MethodHead, thingsURL(serverURL, affordanceThings), "", nil, t)
 		if err != nil {
-			t.Fatalf("Error making HEAD request: %s", err)
+			fatalf(t, "Error making HEAD request: %s", err)
 		}
 		body := httpReadBody(res, t)
 		assertStatusCode(t, res, http.StatusOK, body)
@@ -853,25 +901,29 @@ func TestListThings(t *testing.T) {
 
 }
 
+// clockSkew is the difference tolerated between the clocks of the directory and the test suite,
+// e.g. for a registration time that is slightly in the future
+const clockSkew = 5 * time.Second
+
 func testRegistrationInfoCreated(t *testing.T, td mapAny) {
 	// defer report(t, "tdd-registrationinfo-vocab-created")
 
 	regInfo, ok := td["registration"].(mapAny)
 	if !ok {
-		t.Fatalf("invalid or missing registration object: %v", td["registration"])
+		fatalf(t, "invalid or missing registration object: %v", td["registration"])
 	}
 
 	createdStr, ok := regInfo["created"].(string)
 	if !ok {
-		t.Fatalf("invalid or missing registration.created: %v", regInfo["created"])
+		fatalf(t, "invalid or missing registration.created: %v", regInfo["created"])
 	}
 	created, err := time.Parse(time.RFC3339, createdStr)
 	if err != nil {
-		t.Fatalf("invalid registration.created format: %s", err)
+		fatalf(t, "invalid registration.created format: %s", err)
 	}
-	age := time.Since(created)
-	if age < 0 && age > time.Minute {
-		t.Fatalf("registration.created is in future or too old: %s", created)
+	age := testTime(t).Sub(created)
+	if age < -clockSkew || age > time.Minute {
+		fatalf(t, "registration.created is in future or too old: %s", created)
 	}
 }
 
@@ -880,37 +932,46 @@ func testRegistrationInfoModified(t *testing.T, td mapAny) {
 
 	regInfo, ok := td["registration"].(mapAny)
 	if !ok {
-		t.Fatalf("invalid or missing registration object: %v", td["registration"])
+		fatalf(t, "invalid or missing registration object: %v", td["registration"])
 	}
 
 	modifiedStr, ok := regInfo["modified"].(string)
 	if !ok {
-		t.Fatalf("invalid or missing registration.modified: %v", regInfo["modified"])
+		fatalf(t, "invalid or missing registration.modified: %v", regInfo["modified"])
 	}
 	modified, err := time.Parse(time.RFC3339, modifiedStr)
 	if err != nil {
-		t.Fatalf("invalid registration.modified format: %s", err)
+		fatalf(t, "invalid registration.modified format: %s", err)
 	}
-	age := time.Since(modified)
-	if age < 0 && age > time.Minute {
-		t.Fatalf("registration.modified is in future or too old: %s", modified)
+	age := testTime(t).Sub(modified)
+	if age < -clockSkew || age > time.Minute {
+		fatalf(t, "registration.modified is in future or too old: %s", modified)
 	}
 }
 
-// t.Run("expires", func(t *testing.T) {
-// 	defer report(t, "tdd-registrationinfo-vocab-expires")
-
-// 	t.Skipf("TODO")
-// })
-
-// t.Run("ttl", func(t *testing.T) {
-// 	defer report(t, "tdd-registrationinfo-vocab-ttl")
-
-// 	t.Skipf("TODO")
-// })
-
 // t.Run("retrieved", func(t *testing.T) {
 // 	defer report(t, "tdd-registrationinfo-vocab-retrieved")
 
 // 	t.Skipf("TODO")
 // })
+
+// linkTarget returns the absolute URL of the Link header with the given relation type (RFC 8288), if any
+func linkTarget(res *http.Response, rel string) string {
+	for _, header := range res.Header.Values("Link") {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			for _, param := range parts[1:] {
+				nameValue := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(nameValue) == 2 && nameValue[0] == "rel" && strings.Trim(nameValue[1], `"`) == rel {
+					u, err := res.Request.URL.Parse(target)
+					if err != nil {
+						return target
+					}
+					return u.String()
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,146 @@
+package directory
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+type tlsConfig struct {
+	caFile     string // PEM bundle of CAs to trust instead of the system ones
+	certFile   string // PEM client certificate for mutual TLS
+	keyFile    string
+	serverName string // overrides the name used for SNI and certificate verification
+	minVersion string // 1.0, 1.1, 1.2 or 1.3
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsVersionName returns the name of a TLS version, e.g. TLS 1.3
+func tlsVersionName(version uint16) string {
+	for name, v := range tlsVersions {
+		if v == version {
+			return "TLS " + name
+		}
+	}
+	return fmt.Sprintf("0x%04X", version)
+}
+
+// newTLSConfig returns the TLS configuration of the clients
+func newTLSConfig(config tlsConfig) (*tls.Config, error) {
+	c := &tls.Config{
+		ServerName: config.serverName,
+	}
+
+	if config.minVersion != "" {
+		version, found := tlsVersions[config.minVersion]
+		if !found {
+			return nil, fmt.Errorf("unknown TLS version: %s", config.minVersion)
+		}
+		c.MinVersion = version
+	}
+
+	if config.caFile != "" {
+		pem, err := os.ReadFile(config.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA bundle: %s", err)
+		}
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle: %s", config.caFile)
+		}
+	}
+
+	if config.certFile != "" || config.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.certFile, config.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %s", err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	return c, nil
+}
+
+// negotiatedTLS is the state of the first TLS connection to the directory, nil if none
+var negotiatedTLS struct {
+	sync.Mutex
+	state *tls.ConnectionState
+}
+
+// recordTLS returns a copy of the configuration that records the state of the first connection
+func recordTLS(c *tls.Config) *tls.Config {
+	c = c.Clone()
+	c.VerifyConnection = func(state tls.ConnectionState) error {
+		negotiatedTLS.Lock()
+		defer negotiatedTLS.Unlock()
+		if negotiatedTLS.state == nil {
+			negotiatedTLS.state = &state
+		}
+		return nil
+	}
+	return c
+}
+
+// tlsInfo are the negotiated TLS parameters, as written in the reports
+type tlsInfo struct {
+	Version           string `json:"version"`
+	CipherSuite       string `json:"cipherSuite"`
+	ServerName        string `json:"serverName,omitempty"`
+	Protocol          string `json:"protocol,omitempty"` // negotiated with ALPN
+	ServerCertificate string `json:"serverCertificate,omitempty"`
+	Issuer            string `json:"issuer,omitempty"`
+	ClientCertificate string `json:"clientCertificate,omitempty"` // subject of the certificate presented for mutual TLS
+}
+
+// negotiatedTLSInfo returns the parameters of the first TLS connection to the directory, nil if none
+func negotiatedTLSInfo(c *tls.Config) *tlsInfo {
+	negotiatedTLS.Lock()
+	defer negotiatedTLS.Unlock()
+	state := negotiatedTLS.state
+	if state == nil {
+		return nil
+	}
+
+	info := &tlsInfo{
+		Version:     tlsVersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ServerName:  state.ServerName,
+		Protocol:    state.NegotiatedProtocol,
+	}
+	if len(state.PeerCertificates) > 0 {
+		info.ServerCertificate = state.PeerCertificates[0].Subject.String()
+		info.Issuer = state.PeerCertificates[0].Issuer.String()
+	}
+	if c != nil && len(c.Certificates) > 0 {
+		if cert, err := x509.ParseCertificate(c.Certificates[0].Certificate[0]); err == nil {
+			info.ClientCertificate = cert.Subject.String()
+		}
+	}
+	return info
+}
+
+func (i *tlsInfo) String() string {
+	parts := []string{i.Version, i.CipherSuite}
+	if i.Protocol != "" {
+		parts = append(parts, "protocol "+i.Protocol)
+	}
+	if i.ServerName != "" {
+		parts = append(parts, "server name "+i.ServerName)
+	}
+	if i.ServerCertificate != "" {
+		parts = append(parts, fmt.Sprintf("server certificate %q issued by %q", i.ServerCertificate, i.Issuer))
+	}
+	if i.ClientCertificate != "" {
+		parts = append(parts, fmt.Sprintf("client certificate %q", i.ClientCertificate))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -13,6 +13,20 @@ import (
 	"gopkg.in/cenkalti/backoff.v1"
 )
 
+const (
+	MediaTypeJSON             = "application/json"
+	MediaTypeJSONLD           = "application/ld+json"
+	MediaTypeThingDescription = "application/td+json"
+	MediaTypeMergePatch       = "application/merge-patch+json"
+)
+
+// TD event types
+const (
+	EventTypeCreate = "thing_created"
+	EventTypeUpdate = "thing_updated"
+	EventTypeDelete = "thing_deleted"
+)
+
 type any = interface{}
 type mapAny = map[string]any
 
@@ -36,25 +50,25 @@ func mockedTD(id string) mapAny {
 // retrieveThing is a helper function to support tests unrelated to retrieval of a TD
 func retrieveThing(id, serverURL string, t *testing.T) mapAny {
 	t.Helper()
-	res, err := http.Get(serverURL + "/things/" + id)
+	res, err := httpGet(thingURL(serverURL, id, affordanceRetrieveThing), t)
 	if err != nil {
-		t.Fatalf("Error getting TD: %s", err)
+		fatalf(t, "Error getting TD: %s", err)
 	}
 	defer res.Body.Close()
 
 	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		t.Fatalf("Error reading response body: %s", err)
+		fatalf(t, "Error reading response body: %s", err)
 	}
 
 	if res.StatusCode != http.StatusOK {
-		t.Fatalf("Error retrieving test data: %d: %s", res.StatusCode, b)
+		fatalf(t, "Error retrieving test data: %d: %s", res.StatusCode, b)
 	}
 
 	var retrievedTD mapAny
 	err = json.Unmarshal(b, &retrievedTD)
 	if err != nil {
-		t.Fatalf("Error decoding body: %s. Body:\n%s", err, b)
+		fatalf(t, "Error decoding body: %s. Body:\n%s", err, b)
 	}
 	return retrievedTD
 }
@@ -67,25 +81,25 @@ func createThing(id string, td mapAny, serverURL string, t *testing.T) {
 	var res *http.Response
 	var err error
 	if id == "" { // anonymous TD
-		res, err = http.Post(serverURL+"/things", MediaTypeThingDescription, bytes.NewReader(b))
+		res, err = httpPost(thingsURL(serverURL, affordanceCreateAnonymous), MediaTypeThingDescription, b, t)
 		if err != nil {
-			t.Fatalf("Error posting: %s", err)
+			fatalf(t, "Error posting: %s", err)
 		}
 	} else {
-		res, err = httpPut(serverURL+"/things/"+id, MediaTypeThingDescription, b)
+		res, err = httpPut(thingURL(serverURL, id, affordanceCreateThing), MediaTypeThingDescription, b, t)
 		if err != nil {
-			t.Fatalf("Error posting: %s", err)
+			fatalf(t, "Error posting: %s", err)
 		}
 	}
 	defer res.Body.Close()
 
 	b, err = ioutil.ReadAll(res.Body)
 	if err != nil {
-		t.Fatalf("Error reading response body: %s", err)
+		fatalf(t, "Error reading response body: %s", err)
 	}
 
 	if res.StatusCode != http.StatusCreated {
-		t.Fatalf("Error creating test data: %d: %s", res.StatusCode, b)
+		fatalf(t, "Error creating test data: %d: %s", res.StatusCode, b)
 	}
 
 	// storedTD := retrieveThing(id, serverURL, t)
@@ -103,20 +117,20 @@ func updateThing(id string, td mapAny, serverURL string, t *testing.T) {
 	var res *http.Response
 	var err error
 
-	res, err = httpPut(serverURL+"/things/"+id, MediaTypeThingDescription, b)
+	res, err = httpPut(thingURL(serverURL, id, affordanceUpdateThing), MediaTypeThingDescription, b, t)
 	if err != nil {
-		t.Fatalf("Error updateing: %s", err)
+		fatalf(t, "Error updateing: %s", err)
 	}
 
 	defer res.Body.Close()
 
 	b, err = ioutil.ReadAll(res.Body)
 	if err != nil {
-		t.Fatalf("Error reading response body: %s", err)
+		fatalf(t, "Error reading response body: %s", err)
 	}
 
 	if res.StatusCode != http.StatusNoContent {
-		t.Fatalf("Error updating test data: %d: %s", res.StatusCode, b)
+		fatalf(t, "Error updating test data: %d: %s", res.StatusCode, b)
 	}
 
 }
@@ -127,78 +141,88 @@ func deleteThing(id string, serverURL string, t *testing.T) {
 	var res *http.Response
 	var err error
 
-	res, err = httpDelete(serverURL + "/things/" + id)
+	res, err = httpDelete(thingURL(serverURL, id, affordanceDeleteThing), t)
 	if err != nil {
-		t.Fatalf("Error updateing: %s", err)
+		fatalf(t, "Error updateing: %s", err)
 	}
 
 	defer res.Body.Close()
 
 	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		t.Fatalf("Error reading response body: %s", err)
+		fatalf(t, "Error reading response body: %s", err)
 	}
 
 	if res.StatusCode != http.StatusNoContent {
-		t.Fatalf("Error updating test data: %d: %s", res.StatusCode, b)
+		fatalf(t, "Error updating test data: %d: %s", res.StatusCode, b)
 	}
 }
 
 // retrieveAllThings is a helper function to support tests unrelated to retrieval of all TDs
 func retrieveAllThings(serverURL string, t *testing.T) []mapAny {
 	t.Helper()
-	res, err := http.Get(serverURL + "/things")
+	res, err := httpGet(thingsURL(serverURL, affordanceThings), t)
 	if err != nil {
-		t.Fatalf("Error getting TD: %s", err)
+		fatalf(t, "Error getting TD: %s", err)
 	}
 	defer res.Body.Close()
 
 	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		t.Fatalf("Error reading response body: %s", err)
+		fatalf(t, "Error reading response body: %s", err)
 	}
 
 	if res.StatusCode != http.StatusOK {
-		t.Fatalf("Error retrieving test data: %d: %s", res.StatusCode, b)
+		fatalf(t, "Error retrieving test data: %d: %s", res.StatusCode, b)
 	}
 
 	var retrievedTDs []mapAny
 	err = json.Unmarshal(b, &retrievedTDs)
 	if err != nil {
-		t.Fatalf("Error decoding body: %s", err)
+		fatalf(t, "Error decoding body: %s", err)
 	}
 	return retrievedTDs
 }
 
 func assertEqualTitle(t *testing.T, expectedTD, retrievedTD mapAny) {
 	if expectedTD["title"] != retrievedTD["title"] {
-		t.Fatalf("Expected TD with title: %v, Got: %v",
+		fatalf(t, "Expected TD with title: %v, Got: %v",
 			expectedTD["title"], retrievedTD["title"])
 	}
 }
 
-func httpPut(url, contentType string, b []byte) (*http.Response, error) {
-	return httpRequest(http.MethodPut, url, contentType, b)
+func httpGet(url string, t *testing.T) (*http.Response, error) {
+	return httpRequest(http.MethodGet, url, "", nil, t)
+}
+
+func httpPost(url, contentType string, b []byte, t *testing.T) (*http.Response, error) {
+	return httpRequest(http.MethodPost, url, contentType, b, t)
+}
+
+func httpPut(url, contentType string, b []byte, t *testing.T) (*http.Response, error) {
+	return httpRequest(http.MethodPut, url, contentType, b, t)
 }
 
-func httpPatch(url, contentType string, b []byte) (*http.Response, error) {
-	return httpRequest(http.MethodPatch, url, contentType, b)
+func httpPatch(url, contentType string, b []byte, t *testing.T) (*http.Response, error) {
+	return httpRequest(http.MethodPatch, url, contentType, b, t)
 }
 
-func httpDelete(url string) (*http.Response, error) {
-	return httpRequest(http.MethodDelete, url, "", nil)
+func httpDelete(url string, t *testing.T) (*http.Response, error) {
+	return httpRequest(http.MethodDelete, url, "", nil, t)
 }
 
-func httpRequest(method, url, contentType string, b []byte) (*http.Response, error) {
-	req, err := http.NewRequest(method, url, bytes.NewReader(b))
+// httpRequest sends a request on behalf of the test, which is recorded in the HAR file
+func httpRequest(method, url, contentType string, b []byte, t *testing.T) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(testContext(t), method, url, bytes.NewReader(b))
 	if err != nil {
 		return nil, err
 	}
 	if contentType != "" {
 		req.Header.Add("Content-Type", contentType)
 	}
-	res, err := http.DefaultClient.Do(req)
+	res, err := httpClient.Do(req)
 	if err != nil {
+		recordInfrastructureError(t, err)
 		return nil, err
 	}
 	return res, nil
@@ -212,7 +236,7 @@ func httpReadBody(res *http.Response, t *testing.T) []byte {
 
 	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		t.Fatalf("Error reading response body: %s", err)
+		fatalf(t, "Error reading response body: %s", err)
 	}
 	return b
 }
@@ -231,30 +255,32 @@ func prettifyJSON(in []byte) []byte {
 func assertStatusCode(t *testing.T, res *http.Response, expected int, body []byte) {
 	t.Helper()
 	if res == nil {
-		t.Fatalf("previous errors")
+		fatalf(t, "previous errors")
 	}
+	recordExchange(t, res, body)
 	got := res.StatusCode
 	if got != expected {
 		body = prettifyJSON(body)
 		if len(body) > 0 {
-			t.Logf("Body: %s", body)
+			logf(t, "Body: %s", body)
 		}
-		t.Fatalf("Expected status %d, got: %d", expected, got)
+		fatalf(t, "Expected status %d, got: %d", expected, got)
 	}
 }
 
 func assertContentMediaType(t *testing.T, res *http.Response, expected string) {
 	t.Helper()
 	if res == nil {
-		t.Fatalf("previous errors")
+		fatalf(t, "previous errors")
 	}
+	recordExchange(t, res, nil)
 	got := res.Header.Get("Content-Type")
 	mediaType, _, err := mime.ParseMediaType(got)
 	if err != nil {
-		t.Fatalf("Error parsing content media type: %s", err)
+		fatalf(t, "Error parsing content media type: %s", err)
 	}
 	if mediaType != expected {
-		t.Fatalf("Expected Content-Type: %s, got %s", expected, got)
+		fatalf(t, "Expected Content-Type: %s, got %s", expected, got)
 	}
 }
 
@@ -267,7 +293,7 @@ func getID(t *testing.T, td mapAny) string {
 		}
 	}
 	if id == "" {
-		t.Fatalf("No ID in TD: %s", marshalPrettyJSON(td))
+		fatalf(t, "No ID in TD: %s", marshalPrettyJSON(td))
 	}
 	return id
 }
@@ -283,6 +309,8 @@ func (e *httpError) Error() string {
 func subscribeEvent(t *testing.T, url string, eventCh chan *sse.Event, errCh chan error) *sse.Client {
 	t.Helper()
 	client := sse.NewClient(url)
+	// authenticate like all other requests
+	client.Connection = streamClient
 	client.ResponseValidator = func(c *sse.Client, resp *http.Response) error {
 		if resp.StatusCode != http.StatusOK {
 			err := &httpError{message: "request failed", code: resp.StatusCode}
@@ -291,8 +319,11 @@ func subscribeEvent(t *testing.T, url string, eventCh chan *sse.Event, errCh cha
 		return nil
 	}
 	go func() {
-		err := client.SubscribeChanRaw(eventCh)
+		err := client.SubscribeChanRawWithContext(testContext(t), eventCh)
 		if err != nil {
+			if _, ok := err.(*httpError); !ok {
+				recordInfrastructureError(t, err)
+			}
 			errCh <- err
 		}
 	}()